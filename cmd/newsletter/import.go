@@ -0,0 +1,117 @@
+// Copyright © 2022 Luan Guimarães Lacerda
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/importer"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import the subscribers of a project from a file",
+	Long: `import the subscribers of a project from a CSV or JSON lines file, or from
+the exports of Mailchimp, Substack or Buttondown, printing the import when
+it finishes`,
+	Run: runImport,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	// --format is the log format of every command, so the format of the
+	// file is chosen by --from
+	importCmd.Flags().Int64("project", 0, "id of the project receiving the subscribers")
+	importCmd.Flags().String("file", "", "file to import, exports can be the zip archives of the services")
+	importCmd.Flags().String("from", string(importer.FormatCSV), "format of the file [csv, jsonl, mailchimp, substack, buttondown]")
+	importCmd.Flags().Bool("dry-run", false, "report what the import would do without changing the project")
+	importCmd.Flags().Bool("consented", false, "don't send the welcome email, the subscribers already confirmed elsewhere")
+	importCmd.Flags().Bool("update-existing", false, "merge the rows into the subscriptions that already exist")
+	importCmd.Flags().StringSlice("tags", nil, "tags added to every imported subscription")
+	importCmd.MarkFlagRequired("project")
+	importCmd.MarkFlagRequired("file")
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+
+	projectID, _ := flags.GetInt64("project")
+	file, _ := flags.GetString("file")
+	from, _ := flags.GetString("from")
+
+	i := importer.New()
+	i.Format = importer.Format(from)
+	i.Options.DryRun, _ = flags.GetBool("dry-run")
+	i.Options.Consented, _ = flags.GetBool("consented")
+	i.Options.UpdateExisting, _ = flags.GetBool("update-existing")
+	i.Options.Tags, _ = flags.GetStringSlice("tags")
+
+	_log := log.L.With(zap.Int64("project_id", projectID), zap.String("file", file))
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		_log.Fatal("failed reading the file", zap.Error(err))
+	}
+
+	if err := i.Validate(data); err != nil {
+		_log.Fatal("invalid import", zap.Error(err))
+	}
+
+	initDB(cmd, args)
+
+	p, err := project.NewProjects().Get(projectID)
+	if err != nil {
+		_log.Fatal("failed getting the project", zap.Error(err))
+	}
+
+	if p == nil {
+		_log.Fatal("project not found")
+	}
+
+	// the import runs right here, the import scheduler of the server only
+	// runs the waiting ones
+	i.Status = importer.Running
+	if err := importer.NewProjectImports(projectID).Add(i, data); err != nil {
+		_log.Fatal("failed adding the import", zap.Error(err))
+	}
+
+	if err := i.Run(); err != nil {
+		_log.Fatal("failed running the import", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(i); err != nil {
+		_log.Fatal("failed printing the import", zap.Error(err))
+	}
+
+	if i.Status == importer.Failed {
+		os.Exit(1)
+	}
+}
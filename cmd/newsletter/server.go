@@ -20,8 +20,11 @@ import (
 	"github.com/statictask/newsletter/internal/database"
 	"github.com/statictask/newsletter/internal/log"
 	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/publisher"
 	"github.com/statictask/newsletter/pkg/scheduler"
+	"github.com/statictask/newsletter/pkg/scrapper"
 	"github.com/statictask/newsletter/pkg/server"
+	"github.com/statictask/newsletter/pkg/subscription"
 	"go.uber.org/zap"
 )
 
@@ -37,18 +40,41 @@ func init() {
 	rootCmd.AddCommand(serverCmd)
 
 	serverCmd.Flags().String("bind", "", "server bind address")
+	serverCmd.Flags().Bool("dry-run", false, "run the pipelines without sending nor recording emails, logging them unless EMAIL_PROVIDER is file")
 }
 
 func startServer(cmd *cobra.Command, args []string) {
 	log.L.Info("initializing system")
 
+	initDryRun(cmd, args)
 	initDB(cmd, args)
+	initBackfills(cmd, args)
 	initSchedulers(cmd, args)
 	initServer(cmd, args)
 
 	log.L.Info("finished")
 }
 
+func initDryRun(cmd *cobra.Command, args []string) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		log.L.Fatal("option --dry-run is invalid", zap.Error(err))
+	}
+
+	if !dryRun {
+		return
+	}
+
+	config.C.DryRun = true
+
+	// only the sinks are kept, every real provider is replaced
+	if config.C.EmailProvider != publisher.ProviderFile {
+		config.C.EmailProvider = publisher.ProviderLog
+	}
+
+	log.L.Warn("dry run enabled, emails won't be sent nor recorded", zap.String("provider", config.C.EmailProvider))
+}
+
 func initDB(cmd *cobra.Command, args []string) {
 	database.Init()
 
@@ -59,6 +85,18 @@ func initDB(cmd *cobra.Command, args []string) {
 	log.L.Info("successfully connected to postgres!")
 }
 
+// initBackfills updates the rows stored before a change that needs the
+// application to migrate them
+func initBackfills(cmd *cobra.Command, args []string) {
+	if err := scrapper.SanitizeLegacyItems(); err != nil {
+		log.L.Error("failed sanitizing legacy post items", zap.Error(err))
+	}
+
+	if err := subscription.NormalizeLegacyEmails(); err != nil {
+		log.L.Error("failed normalizing legacy subscription emails", zap.Error(err))
+	}
+}
+
 func initSchedulers(cmd *cobra.Command, args []string) {
 	ps := scheduler.NewPipelineScheduler()
 	ps.Start()
@@ -66,11 +104,17 @@ func initSchedulers(cmd *cobra.Command, args []string) {
 	ts := scheduler.NewTaskScheduler()
 	ts.Start()
 
+	cs := scheduler.NewCampaignScheduler()
+	cs.Start()
+
 	ss := scheduler.NewScrapperJobScheduler()
 	ss.Start()
 
 	sm := scheduler.NewPublisherJobScheduler()
 	sm.Start()
+
+	is := scheduler.NewImportScheduler()
+	is.Start()
 }
 
 func initServer(cmd *cobra.Command, args []string) {
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE projects
+	DROP COLUMN IF EXISTS email_layout;
+
+ALTER TABLE email_templates
+	DROP COLUMN IF EXISTS revision,
+	DROP COLUMN IF EXISTS format;
+
+DROP TYPE IF EXISTS template_format_t;
+
+COMMIT;
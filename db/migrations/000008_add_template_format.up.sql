@@ -0,0 +1,16 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE template_format_t AS ENUM ('html', 'markdown', 'mjml');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+ALTER TABLE email_templates
+	ADD COLUMN IF NOT EXISTS format template_format_t NOT NULL DEFAULT 'html',
+	ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE projects
+	ADD COLUMN IF NOT EXISTS email_layout TEXT NOT NULL DEFAULT '';
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE projects
+	DROP COLUMN IF EXISTS settings;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE projects
+	ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+COMMIT;
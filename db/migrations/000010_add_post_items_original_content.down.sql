@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE post_items
+	DROP COLUMN IF EXISTS original_content;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE post_items
+	ADD COLUMN IF NOT EXISTS original_content TEXT NOT NULL DEFAULT '';
+
+COMMIT;
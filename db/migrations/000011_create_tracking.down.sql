@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE IF EXISTS tracking_events;
+DROP TABLE IF EXISTS tracking_links;
+DROP TYPE IF EXISTS tracking_event_t;
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS tracking_opt_out;
+
+COMMIT;
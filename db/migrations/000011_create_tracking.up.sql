@@ -0,0 +1,39 @@
+BEGIN;
+
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS tracking_opt_out BOOLEAN NOT NULL DEFAULT false;
+
+DO $$ BEGIN
+	CREATE TYPE tracking_event_t AS ENUM ('open', 'click');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS tracking_links (
+	tracking_link_id SERIAL PRIMARY KEY,
+	post_id INTEGER REFERENCES posts (post_id) ON DELETE CASCADE NOT NULL,
+	url TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (post_id, url)
+);
+
+SELECT db_manage_updated_at('tracking_links');
+
+CREATE TABLE IF NOT EXISTS tracking_events (
+	tracking_event_id SERIAL PRIMARY KEY,
+	event_type tracking_event_t NOT NULL,
+	post_id INTEGER REFERENCES posts (post_id) ON DELETE CASCADE NOT NULL,
+	subscription_id INTEGER REFERENCES subscriptions (subscription_id) ON DELETE SET NULL,
+	tracking_link_id INTEGER REFERENCES tracking_links (tracking_link_id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	is_bot BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS tracking_events_post_id_idx ON tracking_events (post_id, event_type);
+
+SELECT db_manage_updated_at('tracking_events');
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS soft_bounces;
+DROP TABLE IF EXISTS suppressions;
+DROP TYPE IF EXISTS suppression_reason_t;
+
+COMMIT;
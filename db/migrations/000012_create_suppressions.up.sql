@@ -0,0 +1,30 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE suppression_reason_t AS ENUM ('hard_bounce', 'soft_bounce', 'complaint');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS suppressions (
+	suppression_id SERIAL PRIMARY KEY,
+	email VARCHAR (300) UNIQUE NOT NULL,
+	reason suppression_reason_t NOT NULL,
+	source VARCHAR (100) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+SELECT db_manage_updated_at('suppressions');
+
+CREATE TABLE IF NOT EXISTS soft_bounces (
+	soft_bounce_id SERIAL PRIMARY KEY,
+	email VARCHAR (300) UNIQUE NOT NULL,
+	bounces INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+SELECT db_manage_updated_at('soft_bounces');
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE tasks
+	DROP COLUMN IF EXISTS sent,
+	DROP COLUMN IF EXISTS failed,
+	DROP COLUMN IF EXISTS total;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE tasks
+	ADD COLUMN IF NOT EXISTS sent INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS failed INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS total INTEGER NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS deliveries;
+DROP TYPE IF EXISTS delivery_status_t;
+
+ALTER TABLE tasks
+	DROP COLUMN IF EXISTS rejected;
+
+COMMIT;
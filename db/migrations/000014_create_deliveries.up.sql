@@ -0,0 +1,28 @@
+BEGIN;
+
+ALTER TABLE tasks
+	ADD COLUMN IF NOT EXISTS rejected INTEGER NOT NULL DEFAULT 0;
+
+DO $$ BEGIN
+	CREATE TYPE delivery_status_t AS ENUM ('sent', 'failed', 'rejected');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS deliveries (
+	delivery_id SERIAL PRIMARY KEY,
+	post_id INTEGER REFERENCES posts (post_id) ON DELETE CASCADE NOT NULL,
+	subscription_id INTEGER REFERENCES subscriptions (subscription_id) ON DELETE CASCADE NOT NULL,
+	delivery_status delivery_status_t NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (post_id, subscription_id)
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_subscription_id_idx ON deliveries (subscription_id);
+
+SELECT db_manage_updated_at('deliveries');
+
+COMMIT;
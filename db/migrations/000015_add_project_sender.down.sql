@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE projects
+	DROP COLUMN IF EXISTS sender_domain,
+	DROP COLUMN IF EXISTS reply_to,
+	DROP COLUMN IF EXISTS from_email,
+	DROP COLUMN IF EXISTS from_name;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE projects
+	ADD COLUMN IF NOT EXISTS from_name VARCHAR (300) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS from_email VARCHAR (300) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS reply_to VARCHAR (300) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS sender_domain VARCHAR (255) NOT NULL DEFAULT '';
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+DELETE FROM deliveries WHERE kind = 'welcome';
+DELETE FROM email_templates WHERE kind = 'welcome';
+
+DROP INDEX IF EXISTS deliveries_welcome_idx;
+
+ALTER TABLE deliveries
+	ALTER COLUMN post_id SET NOT NULL,
+	DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE email_templates
+	DROP COLUMN IF EXISTS kind;
+
+DROP TYPE IF EXISTS template_kind_t;
+
+COMMIT;
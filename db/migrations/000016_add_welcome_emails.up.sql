@@ -0,0 +1,19 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE template_kind_t AS ENUM ('issue', 'welcome');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+ALTER TABLE email_templates
+	ADD COLUMN IF NOT EXISTS kind template_kind_t NOT NULL DEFAULT 'issue';
+
+ALTER TABLE deliveries
+	ADD COLUMN IF NOT EXISTS kind template_kind_t NOT NULL DEFAULT 'issue',
+	ALTER COLUMN post_id DROP NOT NULL;
+
+-- welcome emails aren't related to posts and are sent once per subscription
+CREATE UNIQUE INDEX IF NOT EXISTS deliveries_welcome_idx ON deliveries (subscription_id) WHERE kind = 'welcome';
+
+COMMIT;
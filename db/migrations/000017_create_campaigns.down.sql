@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TABLE IF EXISTS campaigns;
+
+DELETE FROM pipelines WHERE pipeline_type = 'Broadcast';
+
+ALTER TABLE pipelines
+	DROP COLUMN IF EXISTS pipeline_type;
+
+DROP TYPE IF EXISTS pipeline_type_t;
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE pipeline_type_t AS ENUM ('Feed', 'Broadcast');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+ALTER TABLE pipelines
+	ADD COLUMN IF NOT EXISTS pipeline_type pipeline_type_t NOT NULL DEFAULT 'Feed';
+
+CREATE TABLE IF NOT EXISTS campaigns (
+	campaign_id SERIAL PRIMARY KEY,
+	project_id INTEGER REFERENCES projects (project_id) ON DELETE CASCADE NOT NULL,
+	pipeline_id INTEGER REFERENCES pipelines (pipeline_id) ON DELETE SET NULL,
+	subject VARCHAR(300) NOT NULL,
+	format template_format_t NOT NULL DEFAULT 'html',
+	content TEXT NOT NULL,
+	scheduled_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS campaigns_scheduled_at_idx ON campaigns (scheduled_at) WHERE pipeline_id IS NULL;
+
+SELECT db_manage_updated_at('campaigns');
+
+COMMIT;
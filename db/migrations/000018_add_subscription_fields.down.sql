@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS name,
+	DROP COLUMN IF EXISTS locale,
+	DROP COLUMN IF EXISTS timezone,
+	DROP COLUMN IF EXISTS attributes;
+
+COMMIT;
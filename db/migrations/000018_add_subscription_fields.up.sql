@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS name VARCHAR(300) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+COMMIT;
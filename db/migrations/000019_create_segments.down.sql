@@ -0,0 +1,15 @@
+BEGIN;
+
+DROP INDEX IF EXISTS tracking_events_subscription_id_idx;
+
+ALTER TABLE campaigns
+	DROP COLUMN IF EXISTS segment_id;
+
+DROP TABLE IF EXISTS segments;
+
+DROP INDEX IF EXISTS subscriptions_tags_idx;
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS tags;
+
+COMMIT;
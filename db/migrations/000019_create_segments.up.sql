@@ -0,0 +1,24 @@
+BEGIN;
+
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS subscriptions_tags_idx ON subscriptions USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS segments (
+	segment_id SERIAL PRIMARY KEY,
+	project_id INTEGER REFERENCES projects (project_id) ON DELETE CASCADE NOT NULL,
+	name VARCHAR(300) NOT NULL,
+	rules JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+SELECT db_manage_updated_at('segments');
+
+ALTER TABLE campaigns
+	ADD COLUMN IF NOT EXISTS segment_id INTEGER REFERENCES segments (segment_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS tracking_events_subscription_id_idx ON tracking_events (subscription_id, event_type);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM deliveries WHERE delivery_status = 'skipped';
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS topics;
+
+ALTER TABLE post_items
+	DROP COLUMN IF EXISTS categories;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE post_items
+	ADD COLUMN IF NOT EXISTS categories TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS topics TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TYPE delivery_status_t ADD VALUE IF NOT EXISTS 'skipped';
+
+COMMIT;
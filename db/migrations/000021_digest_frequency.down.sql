@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM deliveries WHERE delivery_status = 'deferred';
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS last_digest_at,
+	DROP COLUMN IF EXISTS frequency;
+
+DROP TYPE IF EXISTS frequency_t;
+
+COMMIT;
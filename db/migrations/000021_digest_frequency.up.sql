@@ -0,0 +1,17 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE frequency_t AS ENUM ('immediate', 'weekly', 'monthly');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS frequency frequency_t NOT NULL DEFAULT 'immediate',
+	ADD COLUMN IF NOT EXISTS last_digest_at TIMESTAMP NULL;
+
+-- posts of subscribers who read digests wait in the ledger until their
+-- next digest is sent
+ALTER TYPE delivery_status_t ADD VALUE IF NOT EXISTS 'deferred';
+
+COMMIT;
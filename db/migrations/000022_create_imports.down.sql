@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS imports;
+
+DROP TYPE IF EXISTS import_format_t;
+
+DROP TYPE IF EXISTS import_status_t;
+
+COMMIT;
@@ -0,0 +1,41 @@
+BEGIN;
+
+DO $$ BEGIN
+	CREATE TYPE import_status_t AS ENUM ('Waiting', 'Running', 'Finished', 'Failed');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+DO $$ BEGIN
+	CREATE TYPE import_format_t AS ENUM ('csv', 'jsonl');
+EXCEPTION
+    	WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS imports (
+	import_id SERIAL PRIMARY KEY,
+	project_id INTEGER REFERENCES projects (project_id) ON DELETE CASCADE NOT NULL,
+	format import_format_t NOT NULL,
+	options JSONB NOT NULL DEFAULT '{}',
+	import_status import_status_t NOT NULL DEFAULT 'Waiting',
+	-- the uploaded file, dropped when the import ends
+	data BYTEA NULL,
+	total INTEGER NOT NULL DEFAULT 0,
+	processed INTEGER NOT NULL DEFAULT 0,
+	created INTEGER NOT NULL DEFAULT 0,
+	updated INTEGER NOT NULL DEFAULT 0,
+	duplicates INTEGER NOT NULL DEFAULT 0,
+	invalid INTEGER NOT NULL DEFAULT 0,
+	suppressed INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	report JSONB NOT NULL DEFAULT '[]',
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+SELECT db_manage_updated_at('imports');
+
+CREATE INDEX IF NOT EXISTS imports_status_idx ON imports (import_status);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DELETE FROM imports WHERE format IN ('mailchimp', 'substack', 'buttondown');
+
+ALTER TABLE imports
+	DROP COLUMN IF EXISTS skipped;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TYPE import_format_t ADD VALUE IF NOT EXISTS 'mailchimp';
+ALTER TYPE import_format_t ADD VALUE IF NOT EXISTS 'substack';
+ALTER TYPE import_format_t ADD VALUE IF NOT EXISTS 'buttondown';
+
+ALTER TABLE imports
+	ADD COLUMN IF NOT EXISTS skipped INTEGER NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP INDEX IF EXISTS subscriptions_project_email_idx;
+
+-- addresses subscribed to many projects only keep their oldest
+-- subscription
+DELETE FROM subscriptions AS s
+	USING subscriptions AS older
+	WHERE s.email = older.email
+	AND s.subscription_id > older.subscription_id;
+
+ALTER TABLE subscriptions
+	DROP COLUMN IF EXISTS normalized_email,
+	ADD CONSTRAINT subscriptions_email_key UNIQUE (email);
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- normalized_email is the address compared when subscribing and against
+-- suppressions, trimmed, in lowercase and with the domain in punycode.
+-- Existing rows are backfilled without punycode, the application
+-- converts their domains when it starts.
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS normalized_email VARCHAR (300) NULL;
+
+UPDATE subscriptions SET normalized_email = LOWER(TRIM(email));
+
+-- addresses that only differed in case were different subscriptions of
+-- the same project, the oldest one is kept
+DELETE FROM subscriptions AS s
+	USING subscriptions AS older
+	WHERE s.project_id = older.project_id
+	AND s.normalized_email = older.normalized_email
+	AND s.subscription_id > older.subscription_id;
+
+ALTER TABLE subscriptions
+	ALTER COLUMN normalized_email SET NOT NULL,
+	DROP CONSTRAINT IF EXISTS subscriptions_email_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_project_email_idx ON subscriptions (project_id, normalized_email);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- validation is the outcome of the checks of the address when it
+-- subscribed, NULL for subscriptions that weren't checked
+ALTER TABLE subscriptions
+	ADD COLUMN IF NOT EXISTS validation JSONB NULL;
+
+COMMIT;
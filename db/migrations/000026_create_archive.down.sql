@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE IF EXISTS archive_issues;
+
+DROP INDEX IF EXISTS projects_slug_idx;
+
+ALTER TABLE projects
+	DROP COLUMN IF EXISTS slug;
+
+COMMIT;
@@ -0,0 +1,44 @@
+BEGIN;
+
+ALTER TABLE projects
+	ADD COLUMN IF NOT EXISTS slug TEXT NULL;
+
+-- existing projects get a slug from their name, numbered by their id when
+-- another project has the same one
+UPDATE projects
+	SET slug = TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g'))
+	WHERE slug IS NULL;
+
+UPDATE projects
+	SET slug = 'project'
+	WHERE slug = '';
+
+UPDATE projects AS p
+	SET slug = p.slug || '-' || p.project_id
+	WHERE EXISTS (
+		SELECT 1 FROM projects AS o WHERE o.slug = p.slug AND o.project_id < p.project_id
+	);
+
+ALTER TABLE projects
+	ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS projects_slug_idx ON projects (slug);
+
+-- archive_issues keep the web version of the issues, rendered with the
+-- template of the project when they were sent
+CREATE TABLE IF NOT EXISTS archive_issues (
+	post_id INTEGER PRIMARY KEY REFERENCES posts (post_id) ON DELETE CASCADE,
+	project_id INTEGER REFERENCES projects (project_id) ON DELETE CASCADE NOT NULL,
+	issue INTEGER NOT NULL,
+	subject TEXT NOT NULL,
+	content TEXT NOT NULL,
+	sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+SELECT db_manage_updated_at('archive_issues');
+
+CREATE UNIQUE INDEX IF NOT EXISTS archive_issues_project_issue_idx ON archive_issues (project_id, issue);
+
+COMMIT;
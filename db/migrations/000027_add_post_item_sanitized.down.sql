@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS post_items_unsanitized_idx;
+
+ALTER TABLE post_items
+	DROP COLUMN IF EXISTS sanitized;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- items scraped before their content was sanitized are sanitized by the
+-- application when it starts, until then their content is escaped
+ALTER TABLE post_items
+	ADD COLUMN IF NOT EXISTS sanitized BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS post_items_unsanitized_idx ON post_items (post_id) WHERE NOT sanitized;
+
+COMMIT;
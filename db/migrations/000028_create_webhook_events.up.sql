@@ -0,0 +1,12 @@
+BEGIN;
+
+-- webhook_events are the provider events already recorded, so the
+-- retries of a batch don't record them again
+CREATE TABLE IF NOT EXISTS webhook_events (
+	source VARCHAR (100) NOT NULL,
+	event_id VARCHAR (300) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (source, event_id)
+);
+
+COMMIT;
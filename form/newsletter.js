@@ -1,11 +1,57 @@
+const projectID = 4;
+const baseURL = "http://localhost:8080";
 const form = document.getElementById("newsletter-form");
 
-form.addEventListener("submit", (event) => {
+// the challenge is requested when the form is shown, so the server knows
+// how long it took to fill it
+const challenge = fetch(
+  `${baseURL}/projects/${projectID}/subscriptions/_challenge`
+)
+  .then((response) => response.json())
+  .then((body) => body.data);
+
+// leadingZeroBits counts the zero bits at the start of a hash
+const leadingZeroBits = (hash) => {
+  let zeros = 0;
+  for (const byte of new Uint8Array(hash)) {
+    if (byte !== 0) {
+      return zeros + Math.clz32(byte) - 24;
+    }
+    zeros += 8;
+  }
+  return zeros;
+};
+
+// solve finds the nonce of the proof of work of the form token
+const solve = async (token, difficulty) => {
+  if (!difficulty) {
+    return "";
+  }
+  const encoder = new TextEncoder();
+  for (let nonce = 0; ; nonce++) {
+    const data = encoder.encode(`${token}:${nonce}`);
+    const hash = await crypto.subtle.digest("SHA-256", data);
+    if (leadingZeroBits(hash) >= difficulty) {
+      return String(nonce);
+    }
+  }
+};
+
+form.addEventListener("submit", async (event) => {
   event.preventDefault();
-const projectID = 4;
   const email = document.getElementById("email").value;
+  const name = document.getElementById("name").value;
+  const tags = document.getElementById("tags").value.split(",");
+  const { form_token, proof_of_work } = await challenge;
   const requestBody = {
     email,
+    name,
+    locale: navigator.language,
+    timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+    tags,
+    website: document.getElementById("website").value,
+    form_token,
+    pow_nonce: await solve(form_token, proof_of_work),
   };
   const requestOptions = {
     method: "POST",
@@ -15,7 +61,7 @@ const projectID = 4;
     body: JSON.stringify(requestBody),
   };
   fetch(
-    `http://localhost:8080/projects/${projectID}/subscriptions`,
+    `${baseURL}/projects/${projectID}/subscriptions`,
     requestOptions
   )
     .then((response) => response.json())
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,40 @@ type config struct {
 	SubscriptionAESPassword string
 	SendGridAPIKey string
 	MinScrapeInterval time.Duration
+	TrackingSecret string
+	WebhookSecret string
+	SendGridWebhookPublicKey string
+	SoftBounceThreshold int64
+	PublisherConcurrency int64
+	ProviderRatePerSecond int64
+	ProviderRatePerHour int64
+	ProviderRatePerDay int64
+	SendMaxAttempts int64
+	SendRetryBaseDelay time.Duration
+	SendRetryMaxDelay time.Duration
+	EmailProvider string
+	EmailFileDir string
+	EmailFileFormat string
+	VerifiedSenderDomains string
+	SMTPHost string
+	SMTPPort int64
+	SMTPUsername string
+	SMTPPassword string
+	DKIMKeys string
+	WelcomeMaxAge time.Duration
+	ImportMaxSize int64
+	DisposableDomainsFile string
+	EmailLookupTimeout time.Duration
+	FormSecret string
+	SubscribeRatePerIP int64
+	SubscribeRatePerProject int64
+	TrustProxyHeaders bool
+	CaptchaProvider string
+	CaptchaSecret string
+	CaptchaSiteKey string
+	// DryRun is set by the --dry-run flag of the server, not by the
+	// environment
+	DryRun bool
 }
 
 var C *config
@@ -40,6 +75,37 @@ var Defaults = map[string]interface{}{
 	"SUBSCRIPTION_AES_PASSWORD": "CHANGEME",
 	"MIN_SCRAPE_INTERVAL": "168h",  // 7 days
 	"APPLICATION_DOMAIN": "newsletter.statictask.io",
+	"TRACKING_SECRET": "CHANGEME",
+	"WEBHOOK_SECRET": "CHANGEME",
+	"SENDGRID_WEBHOOK_PUBLIC_KEY": "",
+	"SOFT_BOUNCE_THRESHOLD": 3,
+	"PUBLISHER_CONCURRENCY": 10,
+	"PROVIDER_RATE_PER_SECOND": 100,  // 0 means unlimited
+	"PROVIDER_RATE_PER_HOUR": 0,
+	"PROVIDER_RATE_PER_DAY": 0,
+	"SEND_MAX_ATTEMPTS": 5,
+	"SEND_RETRY_BASE_DELAY": "1s",
+	"SEND_RETRY_MAX_DELAY": "1m",
+	"EMAIL_PROVIDER": "sendgrid",  // sendgrid, smtp, file or log
+	"EMAIL_FILE_DIR": "outbox",
+	"EMAIL_FILE_FORMAT": "eml",  // eml or mbox
+	"VERIFIED_SENDER_DOMAINS": "",  // comma separated
+	"SMTP_HOST": "localhost",
+	"SMTP_PORT": 587,
+	"SMTP_USERNAME": "",
+	"SMTP_PASSWORD": "",
+	"DKIM_KEYS": "",  // comma separated domain:selector:key, key is a PEM or a file path
+	"WELCOME_MAX_AGE": "24h",
+	"IMPORT_MAX_SIZE": 104857600,  // bytes, 100MB
+	"DISPOSABLE_DOMAINS_FILE": "",  // extends the bundled list, one domain per line
+	"EMAIL_LOOKUP_TIMEOUT": "3s",
+	"FORM_SECRET": "CHANGEME",
+	"SUBSCRIBE_RATE_PER_IP": 10,  // per hour, 0 means unlimited
+	"SUBSCRIBE_RATE_PER_PROJECT": 1000,  // per hour, projects can choose their own
+	"TRUST_PROXY_HEADERS": "false",  // read the client address from X-Forwarded-For
+	"CAPTCHA_PROVIDER": "",  // hcaptcha, turnstile, recaptcha or fake
+	"CAPTCHA_SECRET": "",
+	"CAPTCHA_SITE_KEY": "",
 }
 
 func Initialize() {
@@ -56,6 +122,37 @@ func Initialize() {
 		ApplicationDomain: getEnvOrDefaultString("APPLICATION_DOMAIN"),
 		SendGridAPIKey: getEnvOrDefaultString("SENDGRID_API_KEY"),
 		MinScrapeInterval: getEnvOrDefaultDuration("MIN_SCRAPE_INTERVAL"),
+		TrackingSecret: getEnvOrDefaultString("TRACKING_SECRET"),
+		WebhookSecret: getEnvOrDefaultString("WEBHOOK_SECRET"),
+		SendGridWebhookPublicKey: getEnvOrDefaultString("SENDGRID_WEBHOOK_PUBLIC_KEY"),
+		SoftBounceThreshold: getEnvOrDefaultInt64("SOFT_BOUNCE_THRESHOLD"),
+		PublisherConcurrency: getEnvOrDefaultPositiveInt64("PUBLISHER_CONCURRENCY"),
+		ProviderRatePerSecond: getEnvOrDefaultInt64("PROVIDER_RATE_PER_SECOND"),
+		ProviderRatePerHour: getEnvOrDefaultInt64("PROVIDER_RATE_PER_HOUR"),
+		ProviderRatePerDay: getEnvOrDefaultInt64("PROVIDER_RATE_PER_DAY"),
+		SendMaxAttempts: getEnvOrDefaultInt64("SEND_MAX_ATTEMPTS"),
+		SendRetryBaseDelay: getEnvOrDefaultDuration("SEND_RETRY_BASE_DELAY"),
+		SendRetryMaxDelay: getEnvOrDefaultDuration("SEND_RETRY_MAX_DELAY"),
+		EmailProvider: getEnvOrDefaultString("EMAIL_PROVIDER"),
+		EmailFileDir: getEnvOrDefaultString("EMAIL_FILE_DIR"),
+		EmailFileFormat: getEnvOrDefaultString("EMAIL_FILE_FORMAT"),
+		VerifiedSenderDomains: getEnvOrDefaultString("VERIFIED_SENDER_DOMAINS"),
+		SMTPHost: getEnvOrDefaultString("SMTP_HOST"),
+		SMTPPort: getEnvOrDefaultInt64("SMTP_PORT"),
+		SMTPUsername: getEnvOrDefaultString("SMTP_USERNAME"),
+		SMTPPassword: getEnvOrDefaultString("SMTP_PASSWORD"),
+		DKIMKeys: getEnvOrDefaultString("DKIM_KEYS"),
+		WelcomeMaxAge: getEnvOrDefaultDuration("WELCOME_MAX_AGE"),
+		ImportMaxSize: getEnvOrDefaultInt64("IMPORT_MAX_SIZE"),
+		DisposableDomainsFile: getEnvOrDefaultString("DISPOSABLE_DOMAINS_FILE"),
+		EmailLookupTimeout: getEnvOrDefaultDuration("EMAIL_LOOKUP_TIMEOUT"),
+		FormSecret: getEnvOrDefaultString("FORM_SECRET"),
+		SubscribeRatePerIP: getEnvOrDefaultInt64("SUBSCRIBE_RATE_PER_IP"),
+		SubscribeRatePerProject: getEnvOrDefaultInt64("SUBSCRIBE_RATE_PER_PROJECT"),
+		TrustProxyHeaders: getEnvOrDefaultBool("TRUST_PROXY_HEADERS"),
+		CaptchaProvider: getEnvOrDefaultString("CAPTCHA_PROVIDER"),
+		CaptchaSecret: getEnvOrDefaultString("CAPTCHA_SECRET"),
+		CaptchaSiteKey: getEnvOrDefaultString("CAPTCHA_SITE_KEY"),
 	}
 }
 
@@ -86,6 +183,18 @@ func getEnvOrDefaultInt64(key string) int64 {
 	return int64(value)
 }
 
+// getEnvOrDefaultPositiveInt64 returns the environment variable
+// value returned by viper or the hardcoded default, which must be at
+// least 1
+func getEnvOrDefaultPositiveInt64(key string) int64 {
+	value := getEnvOrDefaultInt64(key)
+	if value < 1 {
+		panic(fmt.Errorf("%s must be at least 1, got %d", key, value))
+	}
+
+	return value
+}
+
 // getEnvOrDefaultBool returns the environment variable
 // value returned by viper or the hardcoded default
 func getEnvOrDefaultBool(key string) bool {
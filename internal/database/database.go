@@ -90,3 +90,30 @@ func Exec(query string, params ...interface{}) error {
 
 	return nil
 }
+
+// Transaction runs fn inside a single transaction, which is committed
+// when fn succeeds and rolled back otherwise
+func Transaction(fn func(tx *sql.Tx) error) error {
+	db, err := Connect()
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
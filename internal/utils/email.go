@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeEmail returns the address in the form used to compare it with
+// others: trimmed, in lowercase and with an internationalized domain in
+// punycode, so "José@Bücher.de" becomes "josé@xn--bcher-kva.de"
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return email
+	}
+
+	return email[:at+1] + domain
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{"normalized", "jane@example.com", "jane@example.com"},
+		{"spaces", "  jane@example.com\t", "jane@example.com"},
+		{"case", "Jane.Doe@Example.COM", "jane.doe@example.com"},
+		{"internationalized domain", "José@Bücher.de", "josé@xn--bcher-kva.de"},
+		{"punycode domain", "jose@XN--BCHER-KVA.de", "jose@xn--bcher-kva.de"},
+		{"last at", `"a@b"@example.com`, `"a@b"@example.com`},
+		{"without at", " Jane ", "jane"},
+		{"invalid domain", "jane@exa mple.com", "jane@exa mple.com"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeEmail(tt.email); got != tt.want {
+				t.Fatalf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+
+	// addresses that differ only in case or encoding are the same
+	if NormalizeEmail("JOSÉ@bücher.DE") != NormalizeEmail("josé@xn--bcher-kva.de") {
+		t.Fatal("expected both forms of the address to be normalized the same")
+	}
+}
@@ -0,0 +1,50 @@
+package archive
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// Issue is the web version of an issue sent by a project, rendered with
+// the template of the project when it was sent. The parts of the email
+// that belong to each subscriber, like the unsubscribe link, are left out.
+type Issue struct {
+	PostID    int64     `json:"post_id"`
+	ProjectID int64     `json:"project_id"`
+	Number    int64     `json:"issue"`
+	Subject   string    `json:"subject"`
+	Content   string    `json:"content"`
+	SentAt    time.Time `json:"sent_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// New returns an empty Issue
+func New() *Issue {
+	return &Issue{}
+}
+
+// Save stores the Issue in the database, replacing the content of the
+// post when it was archived before, like when its task runs again
+func (i *Issue) Save() error {
+	if err := upsertIssue(i); err != nil {
+		return fmt.Errorf("unable to save archive issue: %v", err)
+	}
+
+	return nil
+}
+
+// IssueURL returns the address of the issue in the web archive of the
+// project with the slug
+func IssueURL(slug string, number int64) string {
+	link := url.URL{
+		Scheme: "https",
+		Host:   config.C.ApplicationDomain,
+		Path:   fmt.Sprintf("/p/%s/archive/%d", slug, number),
+	}
+
+	return link.String()
+}
@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// upsertIssue inserts an issue in the database or updates the archived
+// version of the same post
+func upsertIssue(i *Issue) error {
+	query := `
+		INSERT INTO archive_issues (
+		  post_id,
+		  project_id,
+		  issue,
+		  subject,
+		  content
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3,
+		  $4,
+		  $5
+		)
+		ON CONFLICT (post_id) DO UPDATE SET
+		  subject = EXCLUDED.subject,
+		  content = EXCLUDED.content
+		RETURNING
+		  post_id,
+		  project_id,
+		  issue,
+		  subject,
+		  content,
+		  sent_at,
+		  created_at,
+		  updated_at
+	`
+
+	savedIssue, err := scanIssue(query, i.PostID, i.ProjectID, i.Number, i.Subject, i.Content)
+	if err != nil {
+		return err
+	}
+
+	*i = *savedIssue
+
+	return nil
+}
+
+// getIssue returns a single issue of the project by its number
+func getIssue(projectID, number int64) (*Issue, error) {
+	query := `
+		SELECT
+		  post_id,
+		  project_id,
+		  issue,
+		  subject,
+		  content,
+		  sent_at,
+		  created_at,
+		  updated_at
+		FROM
+		  archive_issues
+		WHERE
+		  project_id = $1
+		  AND issue = $2
+	`
+
+	return scanIssue(query, projectID, number)
+}
+
+// getIssuesPage returns the issues of the project, the most recent
+// first, leaving their content out
+func getIssuesPage(projectID int64, limit, offset int) ([]*Issue, error) {
+	query := `
+		SELECT
+		  post_id,
+		  project_id,
+		  issue,
+		  subject,
+		  '' AS content,
+		  sent_at,
+		  created_at,
+		  updated_at
+		FROM
+		  archive_issues
+		WHERE
+		  project_id = $1
+		ORDER BY
+		  issue
+		DESC
+		LIMIT $2
+		OFFSET $3
+	`
+
+	return scanIssues(query, projectID, limit, offset)
+}
+
+// countIssues returns the number of issues archived by the project
+func countIssues(projectID int64) (int64, error) {
+	query := `SELECT COUNT(*) FROM archive_issues WHERE project_id = $1`
+
+	db, err := database.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	var count int64
+	if err := db.QueryRow(query, projectID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("unable to count archive issues: %v", err)
+	}
+
+	return count, nil
+}
+
+// scanIssue returns a single issue based on the given query
+func scanIssue(query string, params ...interface{}) (*Issue, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	i := New()
+
+	if err := row.Scan(&i.PostID, &i.ProjectID, &i.Number, &i.Subject, &i.Content, &i.SentAt, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan archive issue row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return i, nil
+}
+
+// scanIssues returns multiple issues based on the given query
+func scanIssues(query string, params ...interface{}) ([]*Issue, error) {
+	issues := []*Issue{}
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return issues, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		i := New()
+
+		if err := rows.Scan(&i.PostID, &i.ProjectID, &i.Number, &i.Subject, &i.Content, &i.SentAt, &i.CreatedAt, &i.UpdatedAt); err != nil {
+			return issues, fmt.Errorf("unable to scan archive issue row: %v", err)
+		}
+
+		issues = append(issues, i)
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,35 @@
+package archive
+
+import "fmt"
+
+// ProjectIssues is the entity used for lazy controlling interactions
+// with the archived issues of a project
+type ProjectIssues struct {
+	projectID int64
+}
+
+// NewProjectIssues returns a ProjectIssues controller
+func NewProjectIssues(projectID int64) *ProjectIssues {
+	return &ProjectIssues{projectID}
+}
+
+// Page returns the issues of a page of the archive, the most recent
+// first and without their content. Pages start from 1.
+func (pi *ProjectIssues) Page(page, perPage int) ([]*Issue, error) {
+	issues, err := getIssuesPage(pi.projectID, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list archive issues: %v", err)
+	}
+
+	return issues, nil
+}
+
+// Count returns the number of archived issues
+func (pi *ProjectIssues) Count() (int64, error) {
+	return countIssues(pi.projectID)
+}
+
+// Get returns an issue by its number, or nil if it wasn't archived
+func (pi *ProjectIssues) Get(number int64) (*Issue, error) {
+	return getIssue(pi.projectID, number)
+}
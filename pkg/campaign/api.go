@@ -0,0 +1,248 @@
+package campaign
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+// CreateCampaign creates a campaign in the project, it's sent at its
+// scheduled_at time or immediately if it's not scheduled
+func CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	controller := NewProjectCampaigns(int64(projectID))
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	c := New()
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// campaigns are only queued by the scheduler
+	c.PipelineID = nil
+	c.ProjectID = int64(projectID)
+
+	layout, err := template.NewProjectEmailTemplates(c.ProjectID).Layout()
+	if err != nil {
+		_log.Error("Failed getting project email layout.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := c.Validate(layout); err != nil {
+		_log.Error("Invalid Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := c.Segment(); err != nil {
+		_log.Error("Invalid Campaign segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.Add(c); err != nil {
+		_log.Error("Failed adding new Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Campaign created successfully.", zap.Int64("campaign_id", c.ID))
+	utils.WriteJSONResponseData(w, http.StatusOK, c)
+}
+
+// GetCampaigns returns the campaigns of the project
+func GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	campaigns, err := NewProjectCampaigns(int64(projectID)).All()
+	if err != nil {
+		_log.Error("Failed loading Campaigns.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, campaigns)
+}
+
+// GetCampaign returns a single campaign of the project
+func GetCampaign(w http.ResponseWriter, r *http.Request) {
+	c, _log, ok := loadCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	_log.Info("Campaign retrieved successfully.")
+	utils.WriteJSONResponseData(w, http.StatusOK, c)
+}
+
+// UpdateCampaign changes a campaign that wasn't queued for delivery yet
+func UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	c, _log, ok := loadCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	if c.IsQueued() {
+		err := fmt.Errorf("campaign %d was already sent", c.ID)
+		_log.Error("Failed updating Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusConflict, err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	c.PipelineID = nil
+
+	layout, err := template.NewProjectEmailTemplates(c.ProjectID).Layout()
+	if err != nil {
+		_log.Error("Failed getting project email layout.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := c.Validate(layout); err != nil {
+		_log.Error("Invalid Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := c.Segment(); err != nil {
+		_log.Error("Invalid Campaign segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.Update(); err != nil {
+		_log.Error("Failed updating Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Campaign updated successfully.")
+	utils.WriteJSONResponseData(w, http.StatusOK, c)
+}
+
+// SendCampaign sends a scheduled campaign immediately
+func SendCampaign(w http.ResponseWriter, r *http.Request) {
+	c, _log, ok := loadCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	if c.IsQueued() {
+		err := fmt.Errorf("campaign %d was already sent", c.ID)
+		_log.Error("Failed sending Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusConflict, err)
+		return
+	}
+
+	now := time.Now()
+	c.ScheduledAt = &now
+
+	if err := c.Update(); err != nil {
+		_log.Error("Failed updating Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Campaign will be sent immediately.")
+	utils.WriteJSONResponseData(w, http.StatusOK, c)
+}
+
+// DeleteCampaign deletes a campaign that wasn't queued for delivery yet
+func DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	c, _log, ok := loadCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	if c.IsQueued() {
+		err := fmt.Errorf("campaign %d was already sent", c.ID)
+		_log.Error("Failed deleting Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusConflict, err)
+		return
+	}
+
+	if err := c.Delete(); err != nil {
+		_log.Error("Failed deleting Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Campaign deleted successfully.")
+	msg := "Campaign deleted successfully."
+	utils.WriteJSONResponseMessage(w, http.StatusNoContent, msg)
+}
+
+// loadCampaign loads the campaign of the request URL, writing the error
+// response when it fails
+func loadCampaign(w http.ResponseWriter, r *http.Request) (*Campaign, *zap.Logger, bool) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	campaignID, err := strconv.Atoi(params["campaign_id"])
+	if err != nil {
+		_log.Error("Failed parsing campaign_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+
+	_log = _log.With(zap.Int64("campaign_id", int64(campaignID)))
+
+	c, err := NewProjectCampaigns(int64(projectID)).Get(int64(campaignID))
+	if err != nil {
+		_log.Error("Failed getting Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+
+	if c == nil {
+		err := fmt.Errorf("campaign %d not found", campaignID)
+		_log.Error("Failed getting Campaign.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return nil, nil, false
+	}
+
+	return c, _log, true
+}
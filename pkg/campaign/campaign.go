@@ -0,0 +1,128 @@
+package campaign
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/segment"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+// Campaign is an email written by hand and sent to the subscribers of a
+// project, independently of the project's feed
+type Campaign struct {
+	ID        int64 `json:"campaign_id"`
+	ProjectID int64 `json:"project_id"`
+	// PipelineID is the Broadcast pipeline created when the campaign is
+	// queued for delivery, the progress of the delivery is the one of
+	// its Publish task
+	PipelineID *int64 `json:"pipeline_id"`
+	// SegmentID restricts the campaign to a segment, campaigns without it
+	// are sent to the audience of the project
+	SegmentID *int64                  `json:"segment_id"`
+	Subject   string                  `json:"subject"`
+	Format    template.TemplateFormat `json:"format"`
+	Content   string                  `json:"content"`
+	// ScheduledAt is when the campaign is sent, campaigns created
+	// without it are sent immediately
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// New returns an empty Campaign
+func New() *Campaign {
+	return &Campaign{Format: template.FormatHTML}
+}
+
+// Create the campaign in the database
+func (c *Campaign) Create() error {
+	if err := insertCampaign(c); err != nil {
+		return fmt.Errorf("unable to create campaign: %v", err)
+	}
+
+	return nil
+}
+
+// Update the campaign in the database
+func (c *Campaign) Update() error {
+	if err := updateCampaign(c); err != nil {
+		return fmt.Errorf("unable to update campaign: %v", err)
+	}
+
+	return nil
+}
+
+// Delete the campaign from the database
+func (c *Campaign) Delete() error {
+	if err := deleteCampaign(c.ID, c.ProjectID); err != nil {
+		return fmt.Errorf("unable to delete campaign: %v", err)
+	}
+
+	return nil
+}
+
+// IsQueued says if the campaign was already queued for delivery, after
+// which it can't be changed anymore
+func (c *Campaign) IsQueued() bool {
+	return c.PipelineID != nil
+}
+
+// Validate checks if the subject and the content of the campaign can be
+// rendered in the given format, inside the given layout of the project
+// when it's Markdown
+func (c *Campaign) Validate(layout string) error {
+	if strings.TrimSpace(c.Subject) == "" {
+		return fmt.Errorf("campaign subject is required")
+	}
+
+	return c.EmailTemplate().Validate(layout)
+}
+
+// Segment returns the segment that receives the campaign, nil when it's
+// sent to the audience of the project
+func (c *Campaign) Segment() (*segment.Segment, error) {
+	if c.SegmentID == nil {
+		return nil, nil
+	}
+
+	s, err := segment.NewProjectSegments(c.ProjectID).Get(*c.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		return nil, fmt.Errorf("segment %d not found", *c.SegmentID)
+	}
+
+	return s, nil
+}
+
+// EmailTemplate returns the template that builds the campaign emails. It
+// isn't stored, campaigns have their own content.
+func (c *Campaign) EmailTemplate() *template.EmailTemplate {
+	et := template.New()
+	et.ProjectID = c.ProjectID
+	et.Name = fmt.Sprintf("Campaign %d", c.ID)
+	et.Format = c.Format
+	et.Subject = c.Subject
+	et.Content = c.Content
+
+	return et
+}
+
+// Queue creates the Broadcast pipeline of the campaign, with a post
+// titled by its subject and a Publish task that doesn't wait for
+// scraping. Everything is written in a single transaction, so a failure
+// in between never leaves a pipeline behind nor sends the campaign twice.
+func (c *Campaign) Queue() error {
+	pipelineID, err := queueCampaign(c)
+	if err != nil {
+		return fmt.Errorf("unable to queue campaign: %v", err)
+	}
+
+	c.PipelineID = &pipelineID
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package campaign
+
+import "fmt"
+
+// Campaigns is the entity used for controlling
+// interactions with the campaigns of every project
+type Campaigns struct{}
+
+// NewCampaigns returns a Campaigns controller
+func NewCampaigns() *Campaigns {
+	return &Campaigns{}
+}
+
+// Due returns the campaigns that must be queued for delivery
+func (cs *Campaigns) Due() ([]*Campaign, error) {
+	campaigns, err := getDueCampaigns()
+	if err != nil {
+		return campaigns, fmt.Errorf("unable to get due campaigns: %v", err)
+	}
+
+	return campaigns, nil
+}
+
+// GetByPipelineID returns the campaign sent by the pipeline, or nil if
+// it's not a Broadcast pipeline
+func (cs *Campaigns) GetByPipelineID(pipelineID int64) (*Campaign, error) {
+	campaign, err := getCampaignByPipelineID(pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pipeline campaign: %v", err)
+	}
+
+	return campaign, nil
+}
@@ -0,0 +1,345 @@
+package campaign
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/statictask/newsletter/internal/database"
+	"github.com/statictask/newsletter/pkg/pipeline"
+	"github.com/statictask/newsletter/pkg/task"
+)
+
+// insertCampaign inserts a campaign in the database, campaigns without
+// schedule are scheduled for now
+func insertCampaign(c *Campaign) error {
+	query := `
+		INSERT INTO campaigns (
+		  project_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3,
+		  $4,
+		  $5,
+		  COALESCE($6, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		)
+		RETURNING
+		  campaign_id,
+		  project_id,
+		  pipeline_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at,
+		  created_at,
+		  updated_at
+	`
+
+	savedCampaign, err := scanCampaign(query, c.ProjectID, c.SegmentID, c.Subject, c.Format, c.Content, utc(c.ScheduledAt))
+	if err != nil {
+		return err
+	}
+
+	*c = *savedCampaign
+
+	return nil
+}
+
+// updateCampaign updates a campaign in the database
+func updateCampaign(c *Campaign) error {
+	query := `
+		UPDATE
+		  campaigns
+		SET
+		  pipeline_id = $1,
+		  segment_id = $2,
+		  subject = $3,
+		  format = $4,
+		  content = $5,
+		  scheduled_at = COALESCE($6, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE
+		  campaign_id = $7
+		  AND project_id = $8
+		RETURNING
+		  campaign_id,
+		  project_id,
+		  pipeline_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at,
+		  created_at,
+		  updated_at
+	`
+
+	savedCampaign, err := scanCampaign(
+		query,
+		c.PipelineID,
+		c.SegmentID,
+		c.Subject,
+		c.Format,
+		c.Content,
+		utc(c.ScheduledAt),
+		c.ID,
+		c.ProjectID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if savedCampaign == nil {
+		return fmt.Errorf("campaign %d not found", c.ID)
+	}
+
+	*c = *savedCampaign
+
+	return nil
+}
+
+// queueCampaign creates the Broadcast pipeline of the campaign, its post
+// and its Ready Publish task, and links the campaign to the pipeline, all
+// in a single transaction
+func queueCampaign(c *Campaign) (int64, error) {
+	var pipelineID int64
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO pipelines
+			  (project_id, pipeline_type)
+			VALUES
+			  ($1, $2)
+			RETURNING
+			  pipeline_id
+		`
+
+		if err := tx.QueryRow(query, c.ProjectID, pipeline.Broadcast).Scan(&pipelineID); err != nil {
+			return fmt.Errorf("unable to create pipeline: %v", err)
+		}
+
+		query = `INSERT INTO posts (pipeline_id, title) VALUES ($1, $2)`
+
+		if _, err := tx.Exec(query, pipelineID, c.Subject); err != nil {
+			return fmt.Errorf("unable to create post: %v", err)
+		}
+
+		query = `INSERT INTO tasks (pipeline_id, task_type, task_status) VALUES ($1, $2, $3)`
+
+		if _, err := tx.Exec(query, pipelineID, task.Publish, task.Ready); err != nil {
+			return fmt.Errorf("unable to create task: %v", err)
+		}
+
+		query = `
+			UPDATE
+			  campaigns
+			SET
+			  pipeline_id = $1
+			WHERE
+			  campaign_id = $2
+			  AND project_id = $3
+			  AND pipeline_id IS NULL
+		`
+
+		res, err := tx.Exec(query, pipelineID, c.ID, c.ProjectID)
+		if err != nil {
+			return fmt.Errorf("unable to update campaign: %v", err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed checking the affected rows: %v", err)
+		} else if n == 0 {
+			return fmt.Errorf("campaign %d not found or already queued", c.ID)
+		}
+
+		return nil
+	})
+
+	return pipelineID, err
+}
+
+// deleteCampaign deletes a campaign from the database
+func deleteCampaign(campaignID, projectID int64) error {
+	query := `DELETE FROM campaigns WHERE campaign_id = $1 AND project_id = $2`
+
+	if err := database.Exec(query, campaignID, projectID); err != nil {
+		return fmt.Errorf("unable to delete campaign: %v", err)
+	}
+
+	return nil
+}
+
+// getCampaign returns a single campaign of the project
+func getCampaign(projectID, campaignID int64) (*Campaign, error) {
+	query := `
+		SELECT
+		  campaign_id,
+		  project_id,
+		  pipeline_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at,
+		  created_at,
+		  updated_at
+		FROM
+		  campaigns
+		WHERE
+		  project_id = $1
+		  AND campaign_id = $2
+	`
+
+	return scanCampaign(query, projectID, campaignID)
+}
+
+// getCampaignByPipelineID returns the campaign sent by the pipeline
+func getCampaignByPipelineID(pipelineID int64) (*Campaign, error) {
+	query := `
+		SELECT
+		  campaign_id,
+		  project_id,
+		  pipeline_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at,
+		  created_at,
+		  updated_at
+		FROM
+		  campaigns
+		WHERE
+		  pipeline_id = $1
+	`
+
+	return scanCampaign(query, pipelineID)
+}
+
+// getCampaignsByProjectID returns the campaigns of the project, the most
+// recently scheduled first
+func getCampaignsByProjectID(projectID int64) ([]*Campaign, error) {
+	query := `
+		SELECT
+		  campaign_id,
+		  project_id,
+		  pipeline_id,
+		  segment_id,
+		  subject,
+		  format,
+		  content,
+		  scheduled_at,
+		  created_at,
+		  updated_at
+		FROM
+		  campaigns
+		WHERE
+		  project_id = $1
+		ORDER BY
+		  scheduled_at
+		DESC
+	`
+
+	return scanCampaigns(query, projectID)
+}
+
+// getDueCampaigns returns the campaigns of enabled projects that weren't
+// queued yet and whose schedule is due
+func getDueCampaigns() ([]*Campaign, error) {
+	query := `
+		SELECT
+		  c.campaign_id,
+		  c.project_id,
+		  c.pipeline_id,
+		  c.segment_id,
+		  c.subject,
+		  c.format,
+		  c.content,
+		  c.scheduled_at,
+		  c.created_at,
+		  c.updated_at
+		FROM
+		  campaigns AS c
+		JOIN projects AS p
+		  ON c.project_id = p.project_id
+		WHERE
+		  c.pipeline_id IS NULL
+		  AND c.scheduled_at <= CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+		  AND p.is_enabled = true
+		ORDER BY
+		  c.scheduled_at
+	`
+
+	return scanCampaigns(query)
+}
+
+// scanCampaign returns a single campaign that matches the given query
+func scanCampaign(query string, params ...interface{}) (*Campaign, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	c := New()
+
+	if err := row.Scan(&c.ID, &c.ProjectID, &c.PipelineID, &c.SegmentID, &c.Subject, &c.Format, &c.Content, &c.ScheduledAt, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan campaign row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+// scanCampaigns returns multiple campaigns that match the given query
+func scanCampaigns(query string, params ...interface{}) ([]*Campaign, error) {
+	var campaigns []*Campaign
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return campaigns, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		c := New()
+
+		if err := rows.Scan(&c.ID, &c.ProjectID, &c.PipelineID, &c.SegmentID, &c.Subject, &c.Format, &c.Content, &c.ScheduledAt, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return campaigns, fmt.Errorf("unable to scan a campaign row: %v", err)
+		}
+
+		campaigns = append(campaigns, c)
+	}
+
+	return campaigns, nil
+}
+
+// utc converts the time to UTC before it's stored in columns without
+// time zone
+func utc(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
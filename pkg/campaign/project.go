@@ -0,0 +1,42 @@
+package campaign
+
+import "fmt"
+
+// ProjectCampaigns is the entity used for controlling
+// interactions with the campaigns of a project
+type ProjectCampaigns struct {
+	projectID int64
+}
+
+// NewProjectCampaigns returns a ProjectCampaigns controller
+func NewProjectCampaigns(projectID int64) *ProjectCampaigns {
+	return &ProjectCampaigns{projectID}
+}
+
+// All returns the campaigns of the project
+func (pc *ProjectCampaigns) All() ([]*Campaign, error) {
+	campaigns, err := getCampaignsByProjectID(pc.projectID)
+	if err != nil {
+		return campaigns, fmt.Errorf("unable to get campaigns: %v", err)
+	}
+
+	return campaigns, nil
+}
+
+// Get returns a single campaign of the project
+func (pc *ProjectCampaigns) Get(campaignID int64) (*Campaign, error) {
+	campaign, err := getCampaign(pc.projectID, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get campaign: %v", err)
+	}
+
+	return campaign, nil
+}
+
+// Add creates a new campaign in the project
+func (pc *ProjectCampaigns) Add(c *Campaign) error {
+	// make sure the campaign has the correct ProjectID before adding
+	c.ProjectID = pc.projectID
+
+	return c.Create()
+}
@@ -0,0 +1,45 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+// GetSubscriptionDeliveries returns the results of the emails sent
+// to a subscription
+func GetSubscriptionDeliveries(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	subscriptionID, err := strconv.Atoi(params["subscription_id"])
+	if err != nil {
+		_log.Error("Failed parsing subscription_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log = _log.With(zap.Int64("subscription_id", int64(subscriptionID)))
+
+	deliveries, err := NewSubscriptionDeliveries(int64(projectID), int64(subscriptionID)).All()
+	if err != nil {
+		_log.Error("Failed getting Deliveries.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, deliveries)
+}
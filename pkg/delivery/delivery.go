@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	// Sent emails were accepted by the provider
+	Sent Status = "sent"
+	// Failed emails exhausted their attempts on transient errors
+	Failed Status = "failed"
+	// Rejected emails were refused by the provider for permanent
+	// reasons, like an invalid address, and are never retried
+	Rejected Status = "rejected"
+	// Skipped emails weren't sent because there was nothing for the
+	// subscription to read, like when no item matches their topics
+	Skipped Status = "skipped"
+	// Deferred posts wait for the next digest of the subscription, which
+	// gives them the status of the digest email
+	Deferred Status = "deferred"
+)
+
+type Kind string
+
+const (
+	// Issue deliveries send a post to a subscription
+	Issue Kind = "issue"
+	// Welcome deliveries greet new subscriptions, once per subscription
+	Welcome Kind = "welcome"
+)
+
+// Delivery is the ledger entry of an email sent to a subscription.
+// PostID is zero for welcome emails.
+type Delivery struct {
+	ID             int64     `json:"delivery_id"`
+	Kind           Kind      `json:"kind"`
+	PostID         int64     `json:"post_id"`
+	SubscriptionID int64     `json:"subscription_id"`
+	Status         Status    `json:"delivery_status"`
+	Attempts       int       `json:"attempts"`
+	Error          string    `json:"error"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// New returns an empty Delivery
+func New() *Delivery {
+	return &Delivery{Kind: Issue}
+}
+
+// Save stores the Delivery in the database, replacing the previous
+// result of the same post, or welcome email, and subscription
+func (d *Delivery) Save() error {
+	upsert := upsertDelivery
+	if d.Kind == Welcome {
+		upsert = upsertWelcomeDelivery
+	}
+
+	if err := upsert(d); err != nil {
+		return fmt.Errorf("unable to save delivery: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// upsertDelivery inserts a delivery in the database or updates the
+// existing one of the same post and subscription
+func upsertDelivery(d *Delivery) error {
+	query := `
+		INSERT INTO deliveries (
+		  post_id,
+		  subscription_id,
+		  delivery_status,
+		  attempts,
+		  error
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3,
+		  $4,
+		  $5
+		)
+		ON CONFLICT (post_id, subscription_id) DO UPDATE SET
+		  delivery_status = EXCLUDED.delivery_status,
+		  attempts = deliveries.attempts + EXCLUDED.attempts,
+		  error = EXCLUDED.error
+		RETURNING
+		  delivery_id,
+		  kind,
+		  post_id,
+		  subscription_id,
+		  delivery_status,
+		  attempts,
+		  error,
+		  created_at,
+		  updated_at
+	`
+
+	savedDelivery, err := scanDelivery(query, d.PostID, d.SubscriptionID, d.Status, d.Attempts, d.Error)
+	if err != nil {
+		return err
+	}
+
+	*d = *savedDelivery
+
+	return nil
+}
+
+// upsertWelcomeDelivery inserts the welcome delivery of a subscription
+// in the database or updates the existing one
+func upsertWelcomeDelivery(d *Delivery) error {
+	query := `
+		INSERT INTO deliveries (
+		  kind,
+		  subscription_id,
+		  delivery_status,
+		  attempts,
+		  error
+		)
+		VALUES (
+		  'welcome',
+		  $1,
+		  $2,
+		  $3,
+		  $4
+		)
+		ON CONFLICT (subscription_id) WHERE kind = 'welcome' DO UPDATE SET
+		  delivery_status = EXCLUDED.delivery_status,
+		  attempts = deliveries.attempts + EXCLUDED.attempts,
+		  error = EXCLUDED.error
+		RETURNING
+		  delivery_id,
+		  kind,
+		  post_id,
+		  subscription_id,
+		  delivery_status,
+		  attempts,
+		  error,
+		  created_at,
+		  updated_at
+	`
+
+	savedDelivery, err := scanDelivery(query, d.SubscriptionID, d.Status, d.Attempts, d.Error)
+	if err != nil {
+		return err
+	}
+
+	*d = *savedDelivery
+
+	return nil
+}
+
+// getSentSubscriptionIDs returns the subscriptions that received the post
+func getSentSubscriptionIDs(postID int64) (map[int64]bool, error) {
+	query := `
+		SELECT
+		  subscription_id
+		FROM
+		  deliveries
+		WHERE
+		  post_id = $1
+		  AND delivery_status = 'sent'
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	ids := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("unable to scan a delivery row: %v", err)
+		}
+
+		ids[id] = true
+	}
+
+	return ids, nil
+}
+
+// getSubscriptionDeliveries returns the deliveries of a subscription
+// that belongs to the project
+func getSubscriptionDeliveries(projectID, subscriptionID int64) ([]*Delivery, error) {
+	query := `
+		SELECT
+		  d.delivery_id,
+		  d.kind,
+		  d.post_id,
+		  d.subscription_id,
+		  d.delivery_status,
+		  d.attempts,
+		  d.error,
+		  d.created_at,
+		  d.updated_at
+		FROM
+		  deliveries AS d
+		JOIN subscriptions AS s
+		  ON d.subscription_id = s.subscription_id
+		WHERE
+		  s.project_id = $1
+		  AND d.subscription_id = $2
+		ORDER BY
+		  d.created_at
+		DESC
+	`
+
+	return scanDeliveries(query, projectID, subscriptionID)
+}
+
+// getDeferredDeliveries returns the deferred deliveries of a subscription
+// that belongs to the project, the oldest first
+func getDeferredDeliveries(projectID, subscriptionID int64) ([]*Delivery, error) {
+	query := `
+		SELECT
+		  d.delivery_id,
+		  d.kind,
+		  d.post_id,
+		  d.subscription_id,
+		  d.delivery_status,
+		  d.attempts,
+		  d.error,
+		  d.created_at,
+		  d.updated_at
+		FROM
+		  deliveries AS d
+		JOIN subscriptions AS s
+		  ON d.subscription_id = s.subscription_id
+		WHERE
+		  s.project_id = $1
+		  AND d.subscription_id = $2
+		  AND d.delivery_status = 'deferred'
+		ORDER BY
+		  d.created_at
+	`
+
+	return scanDeliveries(query, projectID, subscriptionID)
+}
+
+// scanDelivery returns a single delivery that matches the given query
+func scanDelivery(query string, params ...interface{}) (*Delivery, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	d := New()
+
+	// welcome deliveries don't have a post
+	var postID sql.NullInt64
+
+	if err := row.Scan(&d.ID, &d.Kind, &postID, &d.SubscriptionID, &d.Status, &d.Attempts, &d.Error, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan delivery row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	d.PostID = postID.Int64
+
+	return d, nil
+}
+
+// scanDeliveries returns multiple deliveries that match the given query
+func scanDeliveries(query string, params ...interface{}) ([]*Delivery, error) {
+	var deliveries []*Delivery
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return deliveries, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		d := New()
+		var postID sql.NullInt64
+
+		if err := rows.Scan(&d.ID, &d.Kind, &postID, &d.SubscriptionID, &d.Status, &d.Attempts, &d.Error, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return deliveries, fmt.Errorf("unable to scan a delivery row: %v", err)
+		}
+
+		d.PostID = postID.Int64
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,24 @@
+package delivery
+
+import "fmt"
+
+// PostDeliveries is the entity used for lazy controlling
+// interactions with the deliveries of a single post
+type PostDeliveries struct {
+	postID int64
+}
+
+// NewPostDeliveries returns a PostDeliveries controller
+func NewPostDeliveries(postID int64) *PostDeliveries {
+	return &PostDeliveries{postID}
+}
+
+// Sent returns the IDs of the subscriptions that already received the post
+func (pd *PostDeliveries) Sent() (map[int64]bool, error) {
+	ids, err := getSentSubscriptionIDs(pd.postID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sent deliveries: %v", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,36 @@
+package delivery
+
+import "fmt"
+
+// SubscriptionDeliveries is the entity used for lazy controlling
+// interactions with the deliveries of a single subscription
+type SubscriptionDeliveries struct {
+	projectID      int64
+	subscriptionID int64
+}
+
+// NewSubscriptionDeliveries returns a SubscriptionDeliveries controller
+func NewSubscriptionDeliveries(projectID, subscriptionID int64) *SubscriptionDeliveries {
+	return &SubscriptionDeliveries{projectID, subscriptionID}
+}
+
+// All returns the deliveries of the subscription, the most recent first
+func (sd *SubscriptionDeliveries) All() ([]*Delivery, error) {
+	deliveries, err := getSubscriptionDeliveries(sd.projectID, sd.subscriptionID)
+	if err != nil {
+		return deliveries, fmt.Errorf("unable to get deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// Deferred returns the deliveries of the posts waiting for the next
+// digest of the subscription, the oldest first
+func (sd *SubscriptionDeliveries) Deferred() ([]*Delivery, error) {
+	deliveries, err := getDeferredDeliveries(sd.projectID, sd.subscriptionID)
+	if err != nil {
+		return deliveries, fmt.Errorf("unable to get deferred deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+// maxMemory is the part of the uploaded files kept in memory while the
+// request is parsed, the rest goes to temporary files
+const maxMemory = 32 << 20
+
+// CreateImport uploads a file of subscriptions to be imported in the
+// background. The request is a multipart form with the "file", its
+// "format" and the import "options" as JSON.
+func CreateImport(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	controller := NewProjectImports(int64(projectID))
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.C.ImportMaxSize)
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		_log.Error("Failed parsing the uploaded file.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		_log.Error("Failed reading the uploaded file.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		_log.Error("Failed reading the uploaded file.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	i := New()
+	i.Format = formatOf(r.FormValue("format"), header.Filename)
+
+	if options := r.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), &i.Options); err != nil {
+			_log.Error("Failed decoding Import options.", zap.Error(err))
+			utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := i.Validate(data); err != nil {
+		_log.Error("Invalid Import.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.Add(i, data); err != nil {
+		_log.Error("Failed adding new Import.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Import created successfully.", zap.Int64("import_id", i.ID), zap.Int("size", len(data)))
+	utils.WriteJSONResponseData(w, http.StatusAccepted, i)
+}
+
+// GetImports returns the imports of the project
+func GetImports(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	imports, err := NewProjectImports(int64(projectID)).All()
+	if err != nil {
+		_log.Error("Failed loading Imports.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, imports)
+}
+
+// GetImport returns the progress and the report of an import
+func GetImport(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	importID, err := strconv.Atoi(params["import_id"])
+	if err != nil {
+		_log.Error("Failed parsing import_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log = _log.With(zap.Int64("import_id", int64(importID)))
+
+	i, err := NewProjectImports(int64(projectID)).Get(int64(importID))
+	if err != nil {
+		_log.Error("Failed getting Import.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if i == nil {
+		err := fmt.Errorf("import %d not found", importID)
+		_log.Error("Failed getting Import.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, i)
+}
+
+// ExportSubscriptions streams the subscriptions of the project as CSV or
+// JSON lines. The query chooses the "format", the "fields" separated by
+// commas, and filters by "status", "tag", "created_after" and
+// "created_before".
+func ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	query := r.URL.Query()
+
+	format := FormatCSV
+	if f := query.Get("format"); f != "" {
+		format = Format(f)
+	}
+
+	var fields []string
+	if f := query.Get("fields"); f != "" {
+		for _, field := range strings.Split(f, ",") {
+			fields = append(fields, strings.TrimSpace(field))
+		}
+	}
+
+	filter := &ExportFilter{
+		Status:        query.Get("status"),
+		Tags:          query["tag"],
+		CreatedAfter:  query.Get("created_after"),
+		CreatedBefore: query.Get("created_before"),
+	}
+
+	exporter, err := NewExporter(format, fields, filter)
+	if err != nil {
+		_log.Error("Invalid Subscriptions export.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", exporter.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="subscriptions.%s"`, format))
+
+	// the response is streamed, so errors after the first rows can only
+	// be logged
+	if err := exporter.Export(w, int64(projectID)); err != nil {
+		_log.Error("Failed exporting Subscriptions.", zap.Error(err))
+		return
+	}
+
+	_log.Info("Subscriptions exported successfully.")
+}
+
+// formatOf returns the format of the uploaded file, which is the one
+// chosen or else the one of its extension
+func formatOf(format, filename string) Format {
+	if format != "" {
+		return Format(strings.ToLower(format))
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+
+	return FormatCSV
+}
@@ -0,0 +1,246 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/segment"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/suppression"
+)
+
+// ExportFields are the fields exported when none are chosen. Custom
+// fields are exported as "attributes.plan", or all of them in JSON lines
+// as "attributes".
+var ExportFields = []string{
+	"email",
+	"name",
+	"locale",
+	"timezone",
+	"tags",
+	"topics",
+	"frequency",
+	"status",
+	"created_at",
+}
+
+// exportableFields are the fields that can be exported besides the
+// custom ones
+var exportableFields = map[string]bool{
+	"subscription_id":  true,
+	"email":            true,
+	"name":             true,
+	"locale":           true,
+	"timezone":         true,
+	"tags":             true,
+	"topics":           true,
+	"frequency":        true,
+	"status":           true,
+	"tracking_opt_out": true,
+	"attributes":       true,
+	"created_at":       true,
+	"updated_at":       true,
+}
+
+// ExportFilter selects the subscriptions of an export
+type ExportFilter struct {
+	// Status is either "active" or "suppressed"
+	Status string
+	// Tags must all be in the exported subscriptions
+	Tags []string
+	// CreatedAfter and CreatedBefore are dates like "2023-01-31"
+	CreatedAfter  string
+	CreatedBefore string
+}
+
+// Rules returns the segment rules of the filter
+func (ef *ExportFilter) Rules() *segment.Rules {
+	rules := &segment.Rules{Match: segment.MatchAll}
+
+	if ef.Status != "" {
+		rules.Conditions = append(rules.Conditions, &segment.Condition{Field: "status", Operator: segment.OpEquals, Value: ef.Status})
+	}
+
+	for _, t := range ef.Tags {
+		rules.Conditions = append(rules.Conditions, &segment.Condition{Field: "tag", Operator: segment.OpHas, Value: t})
+	}
+
+	if ef.CreatedAfter != "" {
+		rules.Conditions = append(rules.Conditions, &segment.Condition{Field: "created_at", Operator: segment.OpAfter, Value: ef.CreatedAfter})
+	}
+
+	if ef.CreatedBefore != "" {
+		rules.Conditions = append(rules.Conditions, &segment.Condition{Field: "created_at", Operator: segment.OpBefore, Value: ef.CreatedBefore})
+	}
+
+	return rules
+}
+
+// Exporter writes the subscriptions of a project to a file
+type Exporter struct {
+	format Format
+	fields []string
+	filter *subscription.Filter
+}
+
+// NewExporter returns an Exporter of the fields of the subscriptions
+// that match the filter
+func NewExporter(format Format, fields []string, ef *ExportFilter) (*Exporter, error) {
+	if !format.IsValid() {
+		return nil, fmt.Errorf("invalid format '%s', use one of %v", format, Formats)
+	}
+
+	if len(fields) == 0 {
+		fields = ExportFields
+	}
+
+	for _, field := range fields {
+		if exportableFields[field] {
+			continue
+		}
+
+		if key := strings.TrimPrefix(field, "attributes."); key != field && key != "" {
+			continue
+		}
+
+		return nil, fmt.Errorf("unknown field '%s'", field)
+	}
+
+	filter, err := ef.Rules().Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Exporter{format, fields, filter}, nil
+}
+
+// ContentType returns the media type of the exported file
+func (e *Exporter) ContentType() string {
+	if e.format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv"
+}
+
+// Export writes the subscriptions of the project while they are read
+// from the database
+func (e *Exporter) Export(w io.Writer, projectID int64) error {
+	suppressed, err := loadSuppressed()
+	if err != nil {
+		return err
+	}
+
+	subscriptions := subscription.NewProjectSubscriptions(projectID)
+
+	if e.format == FormatJSONL {
+		enc := json.NewEncoder(w)
+
+		return subscriptions.Each(e.filter, func(s *subscription.Subscription) error {
+			obj := map[string]interface{}{}
+			for _, field := range e.fields {
+				obj[field] = exportValue(s, field, suppressed)
+			}
+
+			return enc.Encode(obj)
+		})
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(e.fields); err != nil {
+		return err
+	}
+
+	err = subscriptions.Each(e.filter, func(s *subscription.Subscription) error {
+		values := make([]string, len(e.fields))
+		for i, field := range e.fields {
+			values[i] = csvValue(exportValue(s, field, suppressed))
+		}
+
+		return cw.Write(values)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// loadSuppressed returns the suppressed addresses
+func loadSuppressed() (map[string]bool, error) {
+	suppressions, err := suppression.NewSuppressions().All()
+	if err != nil {
+		return nil, err
+	}
+
+	suppressed := map[string]bool{}
+	for _, s := range suppressions {
+		suppressed[s.Email] = true
+	}
+
+	return suppressed, nil
+}
+
+// exportValue returns the value of a field of the subscription
+func exportValue(s *subscription.Subscription, field string, suppressed map[string]bool) interface{} {
+	switch field {
+	case "subscription_id":
+		return s.ID
+	case "email":
+		return s.Email
+	case "name":
+		return s.Name
+	case "locale":
+		return s.Locale
+	case "timezone":
+		return s.Timezone
+	case "tags":
+		return s.Tags
+	case "topics":
+		return s.Topics
+	case "frequency":
+		return s.Frequency
+	case "status":
+		if suppressed[suppression.NormalizeEmail(s.Email)] {
+			return segment.StatusSuppressed
+		}
+
+		return segment.StatusActive
+	case "tracking_opt_out":
+		return s.TrackingOptOut
+	case "attributes":
+		return s.Attributes
+	case "created_at":
+		return s.CreatedAt
+	case "updated_at":
+		return s.UpdatedAt
+	}
+
+	return s.Attributes[strings.TrimPrefix(field, "attributes.")]
+}
+
+// csvValue converts a value to the text of a CSV column, lists are
+// separated by commas and objects are JSON
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ",")
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	case subscription.Attributes, map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,297 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// insertImport inserts an import and its file in the database
+func insertImport(i *Import, data []byte) error {
+	query := `
+		INSERT INTO imports (
+		  project_id,
+		  format,
+		  options,
+		  import_status,
+		  data
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3,
+		  $4,
+		  $5
+		)
+		RETURNING
+		  import_id,
+		  project_id,
+		  format,
+		  options,
+		  import_status,
+		  total,
+		  processed,
+		  created,
+		  updated,
+		  duplicates,
+		  invalid,
+		  skipped,
+		  suppressed,
+		  failed,
+		  report,
+		  error,
+		  created_at,
+		  updated_at
+	`
+
+	savedImport, err := scanImport(query, i.ProjectID, i.Format, i.Options, i.Status, data)
+	if err != nil {
+		return err
+	}
+
+	*i = *savedImport
+
+	return nil
+}
+
+// updateImportProgress updates the status, the counters and the report of
+// an import in the database, dropping its file when it ends
+func updateImportProgress(i *Import) error {
+	query := `
+		UPDATE
+		  imports
+		SET
+		  import_status = $1,
+		  total = $2,
+		  processed = $3,
+		  created = $4,
+		  updated = $5,
+		  duplicates = $6,
+		  invalid = $7,
+		  skipped = $8,
+		  suppressed = $9,
+		  failed = $10,
+		  report = $11,
+		  error = $12,
+		  data = CASE WHEN $1::import_status_t IN ('Finished', 'Failed') THEN NULL ELSE data END
+		WHERE
+		  import_id = $13
+	`
+
+	if err := database.Exec(
+		query,
+		i.Status,
+		i.Total,
+		i.Processed,
+		i.Created,
+		i.Updated,
+		i.Duplicates,
+		i.Invalid,
+		i.Skipped,
+		i.Suppressed,
+		i.Failed,
+		i.Report,
+		i.Error,
+		i.ID,
+	); err != nil {
+		return fmt.Errorf("failed updating import: %v", err)
+	}
+
+	return nil
+}
+
+// failRunningImports fails the imports that were running when the
+// application stopped
+func failRunningImports() error {
+	query := `
+		UPDATE
+		  imports
+		SET
+		  import_status = 'Failed',
+		  error = 'the import was interrupted, upload the file again to import the remaining rows',
+		  data = NULL
+		WHERE
+		  import_status = 'Running'
+	`
+
+	if err := database.Exec(query); err != nil {
+		return fmt.Errorf("failed updating running imports: %v", err)
+	}
+
+	return nil
+}
+
+// getImportData returns the file of an import
+func getImportData(importID int64) ([]byte, error) {
+	query := `SELECT data FROM imports WHERE import_id = $1`
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	var data []byte
+	if err := db.QueryRow(query, importID).Scan(&data); err != nil {
+		return nil, fmt.Errorf("unable to scan import data: %v", err)
+	}
+
+	return data, nil
+}
+
+// getImport returns a single import of the project
+func getImport(projectID, importID int64) (*Import, error) {
+	query := `
+		SELECT
+		  import_id,
+		  project_id,
+		  format,
+		  options,
+		  import_status,
+		  total,
+		  processed,
+		  created,
+		  updated,
+		  duplicates,
+		  invalid,
+		  skipped,
+		  suppressed,
+		  failed,
+		  report,
+		  error,
+		  created_at,
+		  updated_at
+		FROM
+		  imports
+		WHERE
+		  project_id = $1
+		  AND import_id = $2
+	`
+
+	return scanImport(query, projectID, importID)
+}
+
+// getImportsByProjectID returns the imports of the project, the most
+// recent first
+func getImportsByProjectID(projectID int64) ([]*Import, error) {
+	query := `
+		SELECT
+		  import_id,
+		  project_id,
+		  format,
+		  options,
+		  import_status,
+		  total,
+		  processed,
+		  created,
+		  updated,
+		  duplicates,
+		  invalid,
+		  skipped,
+		  suppressed,
+		  failed,
+		  report,
+		  error,
+		  created_at,
+		  updated_at
+		FROM
+		  imports
+		WHERE
+		  project_id = $1
+		ORDER BY
+		  created_at
+		DESC
+	`
+
+	return scanImports(query, projectID)
+}
+
+// getNextWaitingImport returns the oldest import waiting to run
+func getNextWaitingImport() (*Import, error) {
+	query := `
+		SELECT
+		  import_id,
+		  project_id,
+		  format,
+		  options,
+		  import_status,
+		  total,
+		  processed,
+		  created,
+		  updated,
+		  duplicates,
+		  invalid,
+		  skipped,
+		  suppressed,
+		  failed,
+		  report,
+		  error,
+		  created_at,
+		  updated_at
+		FROM
+		  imports
+		WHERE
+		  import_status = 'Waiting'
+		ORDER BY
+		  created_at
+		LIMIT 1
+	`
+
+	return scanImport(query)
+}
+
+// scanImport returns a single import that matches the given query
+func scanImport(query string, params ...interface{}) (*Import, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	i := New()
+
+	if err := row.Scan(&i.ID, &i.ProjectID, &i.Format, &i.Options, &i.Status, &i.Total, &i.Processed, &i.Created, &i.Updated, &i.Duplicates, &i.Invalid, &i.Skipped, &i.Suppressed, &i.Failed, &i.Report, &i.Error, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan import row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return i, nil
+}
+
+// scanImports returns multiple imports that match the given query
+func scanImports(query string, params ...interface{}) ([]*Import, error) {
+	var imports []*Import
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return imports, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		i := New()
+
+		if err := rows.Scan(&i.ID, &i.ProjectID, &i.Format, &i.Options, &i.Status, &i.Total, &i.Processed, &i.Created, &i.Updated, &i.Duplicates, &i.Invalid, &i.Skipped, &i.Suppressed, &i.Failed, &i.Report, &i.Error, &i.CreatedAt, &i.UpdatedAt); err != nil {
+			return imports, fmt.Errorf("unable to scan an import row: %v", err)
+		}
+
+		imports = append(imports, i)
+	}
+
+	return imports, nil
+}
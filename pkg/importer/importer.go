@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+)
+
+type Status string
+
+const (
+	// Waiting imports are run by the import scheduler, one at a time
+	Waiting  Status = "Waiting"
+	Running  Status = "Running"
+	Finished Status = "Finished"
+	// Failed imports couldn't read the file or the project, problems of
+	// single rows are in the report instead
+	Failed Status = "Failed"
+)
+
+// maxReportProblems limits the problems kept in the report of an import,
+// the counters keep counting after it
+const maxReportProblems = 1000
+
+// Import is a background job that creates the subscriptions of a project
+// from an uploaded file
+type Import struct {
+	ID        int64   `json:"import_id"`
+	ProjectID int64   `json:"project_id"`
+	Format    Format  `json:"format"`
+	Options   Options `json:"options"`
+	Status    Status  `json:"import_status"`
+	// Total is the number of rows in the file and Processed the ones
+	// already imported, which are either Created, Updated, Duplicates,
+	// Invalid, Skipped, Suppressed or Failed. Skipped are the rows of
+	// exports of other services that aren't subscribed there anymore.
+	Total      int64 `json:"total"`
+	Processed  int64 `json:"processed"`
+	Created    int64 `json:"created"`
+	Updated    int64 `json:"updated"`
+	Duplicates int64 `json:"duplicates"`
+	Invalid    int64 `json:"invalid"`
+	Skipped    int64 `json:"skipped"`
+	Suppressed int64 `json:"suppressed"`
+	Failed     int64 `json:"failed"`
+	// Report lists the rows that weren't imported and why
+	Report    Report    `json:"report"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Options change how the rows of the file become subscriptions
+type Options struct {
+	// Mapping is ignored by the formats of other services, which have
+	// their own columns
+	Mapping Mapping `json:"mapping"`
+	// DryRun imports nothing, the counters and the report say what the
+	// import would do
+	DryRun bool `json:"dry_run"`
+	// Consented lists were already confirmed by the subscribers
+	// elsewhere, so they aren't sent the welcome email of the project
+	Consented bool `json:"consented"`
+	// UpdateExisting merges the rows into the subscriptions that already
+	// exist, which are reported as duplicates otherwise
+	UpdateExisting bool `json:"update_existing"`
+	// Tags are added to every imported subscription
+	Tags []string `json:"tags"`
+}
+
+// Problem is a row of the file that wasn't imported
+type Problem struct {
+	Row    int64  `json:"row"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// Report is the list of problems of an import
+type Report []*Problem
+
+// New returns an empty Import
+func New() *Import {
+	return &Import{Format: FormatCSV, Status: Waiting, Report: Report{}}
+}
+
+// Create the import in the database, with the uploaded file
+func (i *Import) Create(data []byte) error {
+	if err := insertImport(i, data); err != nil {
+		return fmt.Errorf("unable to create import: %v", err)
+	}
+
+	return nil
+}
+
+// Validate checks if the options of the import are valid and if the file
+// can be read
+func (i *Import) Validate(data []byte) error {
+	if !i.Format.IsValid() {
+		return fmt.Errorf("invalid format '%s', use one of %v", i.Format, Formats)
+	}
+
+	tags, err := subscription.NormalizeTags(i.Options.Tags)
+	if err != nil {
+		return err
+	}
+
+	i.Options.Tags = tags
+
+	if _, err := NewReader(i.Format, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateProgress stores the status, the counters and the report of the
+// import in the database
+func (i *Import) UpdateProgress() error {
+	if err := updateImportProgress(i); err != nil {
+		return fmt.Errorf("unable to update import progress: %v", err)
+	}
+
+	return nil
+}
+
+// Progress describes the rows of the import, like "imported 4210 of 8000"
+func (i *Import) Progress() string {
+	return fmt.Sprintf("imported %d of %d", i.Processed, i.Total)
+}
+
+// addProblem adds a row to the report, while it's not full
+func (i *Import) addProblem(row int64, email, reason string) {
+	if len(i.Report) >= maxReportProblems {
+		return
+	}
+
+	i.Report = append(i.Report, &Problem{Row: row, Email: email, Reason: reason})
+}
+
+// Scan loads the Options from the JSON stored in the database
+func (o *Options) Scan(src interface{}) error {
+	return scanJSON(src, o)
+}
+
+// Value converts the Options to JSON before storing them in the database
+func (o Options) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+// Scan loads the Report from the JSON stored in the database
+func (r *Report) Scan(src interface{}) error {
+	return scanJSON(src, r)
+}
+
+// Value converts the Report to JSON before storing them in the database
+func (r Report) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(r)
+}
+
+// scanJSON loads a value from the JSON stored in the database
+func scanJSON(src interface{}, v interface{}) error {
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		return fmt.Errorf("unable to scan %T from %T", v, src)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to parse %T: %v", v, err)
+	}
+
+	return nil
+}
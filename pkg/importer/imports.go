@@ -0,0 +1,28 @@
+package importer
+
+import "fmt"
+
+// Imports is the entity used for controlling
+// interactions with the imports of every project
+type Imports struct{}
+
+// NewImports returns an Imports controller
+func NewImports() *Imports {
+	return &Imports{}
+}
+
+// Next returns the oldest import waiting to run, or nil if there's none
+func (is *Imports) Next() (*Import, error) {
+	i, err := getNextWaitingImport()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get next import: %v", err)
+	}
+
+	return i, nil
+}
+
+// FailRunning fails the imports left running when the application
+// stopped, which imported an unknown part of their rows
+func (is *Imports) FailRunning() error {
+	return failRunningImports()
+}
@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+// Mapping says which columns of the file have the fields of the
+// subscriptions. Fields without a column are read from the column with
+// their own name, like "email", ignoring the case, and custom fields
+// are only imported when they are mapped.
+type Mapping struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	// Tags are separated by commas, semicolons or pipes in CSV files,
+	// and can be arrays in JSON lines
+	Tags string `json:"tags"`
+	// CreatedAt is the signup date, kept by the imported subscriptions
+	CreatedAt string `json:"created_at"`
+	// Attributes maps the custom fields to their columns, like
+	// {"plan": "Subscription Plan"}
+	Attributes map[string]string `json:"attributes"`
+}
+
+// isTagSeparator says if the character splits the tags of a CSV column
+func isTagSeparator(r rune) bool {
+	return r == ',' || r == ';' || r == '|'
+}
+
+// column returns the column of a field, which is the field itself when
+// it's not mapped
+func column(mapped, field string) string {
+	if mapped != "" {
+		return mapped
+	}
+
+	return field
+}
+
+// Subscription converts a row of the file into a subscription, which
+// still needs to be validated
+func (m *Mapping) Subscription(rec Record) (*subscription.Subscription, error) {
+	s := subscription.New()
+
+	s.Email = strings.TrimSpace(rec.String(column(m.Email, "email")))
+	if s.Email == "" {
+		return nil, fmt.Errorf("email is missing")
+	}
+
+	if err := validation.CheckSyntax(s.Email); err != nil {
+		return s, err
+	}
+
+	s.Name = rec.String(column(m.Name, "name"))
+	s.Locale = strings.TrimSpace(rec.String(column(m.Locale, "locale")))
+	s.Timezone = strings.TrimSpace(rec.String(column(m.Timezone, "timezone")))
+	s.Tags = rec.Strings(column(m.Tags, "tags"))
+	s.CreatedAt = parseDate(rec.String(column(m.CreatedAt, "created_at")))
+
+	for field, col := range m.Attributes {
+		if v := rec.Get(col); v != nil && v != "" {
+			s.Attributes[field] = v
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the value of the column, whose name is compared ignoring
+// the case when there isn't an exact match
+func (rec Record) Get(col string) interface{} {
+	if v, ok := rec[col]; ok {
+		return v
+	}
+
+	for k, v := range rec {
+		if strings.EqualFold(k, col) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// String returns the value of the column as text
+func (rec Record) String(col string) string {
+	switch v := rec.Get(col).(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return ""
+}
+
+// Strings returns the values of a column with a list, either an array
+// or a text with separators
+func (rec Record) Strings(col string) []string {
+	var values []string
+
+	switch v := rec.Get(col).(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case string:
+		values = strings.FieldsFunc(v, isTagSeparator)
+	}
+
+	return values
+}
@@ -0,0 +1,43 @@
+package importer
+
+import "fmt"
+
+// ProjectImports is the entity used for controlling
+// interactions with the imports of a project
+type ProjectImports struct {
+	projectID int64
+}
+
+// NewProjectImports returns a ProjectImports controller
+func NewProjectImports(projectID int64) *ProjectImports {
+	return &ProjectImports{projectID}
+}
+
+// All returns the imports of the project, the most recent first
+func (pi *ProjectImports) All() ([]*Import, error) {
+	imports, err := getImportsByProjectID(pi.projectID)
+	if err != nil {
+		return imports, fmt.Errorf("unable to get imports: %v", err)
+	}
+
+	return imports, nil
+}
+
+// Get returns a single import of the project
+func (pi *ProjectImports) Get(importID int64) (*Import, error) {
+	i, err := getImport(pi.projectID, importID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get import: %v", err)
+	}
+
+	return i, nil
+}
+
+// Add creates a new import of the file in the project, which waits for
+// the import scheduler unless it's created already running
+func (pi *ProjectImports) Add(i *Import, data []byte) error {
+	// make sure the import has the correct ProjectID before adding
+	i.ProjectID = pi.projectID
+
+	return i.Create(data)
+}
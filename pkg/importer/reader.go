@@ -0,0 +1,280 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format is the format of the uploaded files
+type Format string
+
+const (
+	// FormatCSV files have a header row with the names of the columns
+	FormatCSV Format = "csv"
+	// FormatJSONL files have a JSON object per line
+	FormatJSONL Format = "jsonl"
+	// FormatMailchimp, FormatSubstack and FormatButtondown are the CSV
+	// exports of those services, or zip archives of them, which are
+	// translated by their own adapters instead of a mapping
+	FormatMailchimp  Format = "mailchimp"
+	FormatSubstack   Format = "substack"
+	FormatButtondown Format = "buttondown"
+)
+
+// Formats are the formats files can be imported from
+var Formats = []Format{FormatCSV, FormatJSONL, FormatMailchimp, FormatSubstack, FormatButtondown}
+
+// IsValid checks if the format is supported
+func (f Format) IsValid() bool {
+	for _, format := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxLineSize limits the size of a JSON line
+const maxLineSize = 1024 * 1024
+
+// utf8BOM starts the CSV files exported by some spreadsheets
+const utf8BOM = "\ufeff"
+
+// Record is a row of the file, by column name. Values of CSV files are
+// strings, while JSON lines keep their types.
+type Record map[string]interface{}
+
+// Reader reads the rows of a file one at a time, returning io.EOF after
+// the last one. Errors of a single row don't stop the reader.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// zipSignature starts the zip archives
+var zipSignature = []byte("PK\x03\x04")
+
+// NewReader returns the reader of a file of the format. Files of every
+// format but JSON lines can be zip archives of CSV files.
+func NewReader(format Format, data []byte) (Reader, error) {
+	if !format.IsValid() {
+		return nil, fmt.Errorf("invalid format '%s', use one of %v", format, Formats)
+	}
+
+	if format == FormatJSONL {
+		return newJSONLReader(bytes.NewReader(data)), nil
+	}
+
+	if bytes.HasPrefix(data, zipSignature) {
+		return newZipReader(data, archivedFiles(format))
+	}
+
+	return newCSVReader(bytes.NewReader(data))
+}
+
+// csvReader reads the rows of a CSV file with a header
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// newCSVReader reads the header of the CSV file and returns its reader
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the header: %v", err)
+	}
+
+	header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	return &csvReader{r: cr, header: header}, nil
+}
+
+// Read returns the next row of the file
+func (cr *csvReader) Read() (Record, error) {
+	values, err := cr.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := Record{}
+	for i, v := range values {
+		if i >= len(cr.header) {
+			return nil, fmt.Errorf("row has %d columns, the header has %d", len(values), len(cr.header))
+		}
+
+		rec[cr.header[i]] = v
+	}
+
+	return rec, nil
+}
+
+// zipReader reads the rows of the CSV files of a zip archive, one file
+// after the other
+type zipReader struct {
+	files   []*zip.File
+	current *csvReader
+	closer  io.Closer
+}
+
+// newZipReader returns the reader of the CSV files of the archive that
+// the filter accepts
+func newZipReader(data []byte, filter func(name string) bool) (*zipReader, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the zip archive: %v", err)
+	}
+
+	zr := &zipReader{}
+	for _, f := range archive.File {
+		// archives created by macOS have copies of the files' metadata
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+
+		if filter(path.Base(f.Name)) {
+			zr.files = append(zr.files, f)
+		}
+	}
+
+	if len(zr.files) == 0 {
+		return nil, fmt.Errorf("zip archive has no file to import")
+	}
+
+	return zr, nil
+}
+
+// Read returns the next row of the current file, opening the next file
+// when it ends. Empty files are skipped.
+func (zr *zipReader) Read() (Record, error) {
+	for {
+		if zr.current != nil {
+			rec, err := zr.current.Read()
+			if err != io.EOF {
+				return rec, err
+			}
+
+			zr.closer.Close()
+			zr.current = nil
+		}
+
+		if len(zr.files) == 0 {
+			return nil, io.EOF
+		}
+
+		f := zr.files[0]
+		zr.files = zr.files[1:]
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to open '%s': %v", f.Name, err)
+		}
+
+		current, err := newCSVReader(rc)
+		if err != nil {
+			rc.Close()
+			continue
+		}
+
+		zr.current, zr.closer = current, rc
+	}
+}
+
+// archivedFiles returns the filter of the files of an archive that have
+// subscribers, Substack archives have posts as well
+func archivedFiles(format Format) func(name string) bool {
+	return func(name string) bool {
+		name = strings.ToLower(name)
+		if format == FormatSubstack && !strings.HasPrefix(name, "email_list") {
+			return false
+		}
+
+		return strings.HasSuffix(name, ".csv")
+	}
+}
+
+// jsonlReader reads the objects of a JSON lines file
+type jsonlReader struct {
+	s    *bufio.Scanner
+	done bool
+}
+
+// newJSONLReader returns the reader of a JSON lines file
+func newJSONLReader(r io.Reader) *jsonlReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	return &jsonlReader{s: s}
+}
+
+// Read returns the object of the next line of the file, skipping the
+// empty ones. Lines that are too long stop the reader.
+func (jr *jsonlReader) Read() (Record, error) {
+	if jr.done {
+		return nil, io.EOF
+	}
+
+	for jr.s.Scan() {
+		line := bytes.TrimSpace(jr.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		rec := Record{}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		return rec, nil
+	}
+
+	jr.done = true
+
+	if err := jr.s.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read line: %w", err)
+	}
+
+	return nil, io.EOF
+}
+
+// countRecords returns the number of rows of the file
+func countRecords(format Format, data []byte) (int64, error) {
+	r, err := NewReader(format, data)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+
+		if errors.Is(err, bufio.ErrTooLong) {
+			return n, fmt.Errorf("line after row %d is longer than %d bytes", n, maxLineSize)
+		}
+
+		n++
+	}
+}
@@ -0,0 +1,297 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/delivery"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/suppression"
+)
+
+// progressInterval is the minimum interval between progress updates of
+// the import in the database
+const progressInterval = 5 * time.Second
+
+// run holds what an import needs to know about the project while it
+// reads the file
+type run struct {
+	imp           *Import
+	subscriptions *subscription.ProjectSubscriptions
+	// existing are the subscriptions of the project and suppressed the
+	// suppressed addresses, by normalized email
+	existing   map[string]*subscription.Subscription
+	suppressed map[string]bool
+	// seen are the addresses already read from the file
+	seen      map[string]bool
+	lastFlush time.Time
+	log       *zap.Logger
+}
+
+// Run imports the rows of the file, storing the progress of the import
+// while it runs. Problems of single rows are counted and reported, and
+// don't fail the import.
+func (i *Import) Run() error {
+	i.Status = Running
+	if err := i.UpdateProgress(); err != nil {
+		return err
+	}
+
+	i.Status = Finished
+	if err := i.run(); err != nil {
+		i.Status = Failed
+		i.Error = err.Error()
+	}
+
+	return i.UpdateProgress()
+}
+
+// run reads the file and imports its rows
+func (i *Import) run() error {
+	data, err := getImportData(i.ID)
+	if err != nil {
+		return err
+	}
+
+	if i.Total, err = countRecords(i.Format, data); err != nil {
+		return err
+	}
+
+	r, err := NewReader(i.Format, data)
+	if err != nil {
+		return err
+	}
+
+	rn := &run{
+		imp:           i,
+		subscriptions: subscription.NewProjectSubscriptions(i.ProjectID),
+		existing:      map[string]*subscription.Subscription{},
+		suppressed:    map[string]bool{},
+		seen:          map[string]bool{},
+		lastFlush:     time.Now(),
+		log:           log.L.With(zap.Int64("project_id", i.ProjectID), zap.Int64("import_id", i.ID)),
+	}
+
+	subscriptions, err := rn.subscriptions.All()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		rn.existing[suppression.NormalizeEmail(s.Email)] = s
+	}
+
+	suppressions, err := suppression.NewSuppressions().All()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range suppressions {
+		rn.suppressed[s.Email] = true
+	}
+
+	for row := int64(1); ; row++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			i.Invalid++
+			i.addProblem(row, "", err.Error())
+		} else {
+			rn.importRecord(row, rec)
+		}
+
+		i.Processed++
+		rn.flushProgress()
+	}
+}
+
+// importRecord creates or updates the subscription of a row, unless it's
+// invalid, repeated, skipped or suppressed
+func (rn *run) importRecord(row int64, rec Record) {
+	i := rn.imp
+
+	e, err := translate(i.Format, rec, &i.Options.Mapping)
+	if err == nil {
+		e.s.Tags = append(e.s.Tags, i.Options.Tags...)
+		err = e.s.Validate()
+	}
+
+	if err != nil {
+		var email string
+		if e != nil && e.s != nil {
+			email = e.s.Email
+		}
+
+		i.Invalid++
+		i.addProblem(row, email, err.Error())
+		return
+	}
+
+	s := e.s
+	email := suppression.NormalizeEmail(s.Email)
+
+	if rn.seen[email] {
+		i.Duplicates++
+		i.addProblem(row, s.Email, "email is repeated in the file")
+		return
+	}
+
+	rn.seen[email] = true
+
+	if e.skip != "" {
+		i.Skipped++
+		i.addProblem(row, s.Email, e.skip)
+		return
+	}
+
+	if e.suppress != "" && !rn.suppressed[email] {
+		if err := rn.suppress(email, e.suppress); err != nil {
+			i.Failed++
+			i.addProblem(row, s.Email, err.Error())
+			return
+		}
+
+		i.Suppressed++
+		i.addProblem(row, s.Email, fmt.Sprintf("email is suppressed as %s by %s", e.suppress, i.Format))
+		return
+	}
+
+	if rn.suppressed[email] {
+		i.Suppressed++
+		i.addProblem(row, s.Email, "email is suppressed by a bounce or a complaint")
+		return
+	}
+
+	if existing, ok := rn.existing[email]; ok {
+		if !i.Options.UpdateExisting {
+			i.Duplicates++
+			i.addProblem(row, s.Email, "email is already subscribed")
+			return
+		}
+
+		if err := rn.update(existing, s); err != nil {
+			i.Failed++
+			i.addProblem(row, s.Email, err.Error())
+			return
+		}
+
+		i.Updated++
+		return
+	}
+
+	err = rn.create(s)
+	if errors.Is(err, subscription.ErrAlreadySubscribed) {
+		i.Duplicates++
+		i.addProblem(row, s.Email, "email is already subscribed")
+		return
+	}
+
+	if err != nil {
+		i.Failed++
+		i.addProblem(row, s.Email, err.Error())
+		return
+	}
+
+	i.Created++
+}
+
+// create adds the subscription to the project. Subscribers of consented
+// imports are marked as welcomed, so they don't get the welcome email.
+func (rn *run) create(s *subscription.Subscription) error {
+	if rn.imp.Options.DryRun {
+		return nil
+	}
+
+	if err := rn.subscriptions.Add(s); err != nil {
+		return err
+	}
+
+	if !rn.imp.Options.Consented {
+		return nil
+	}
+
+	d := delivery.New()
+	d.Kind = delivery.Welcome
+	d.SubscriptionID = s.ID
+	d.Status = delivery.Skipped
+
+	if err := d.Save(); err != nil {
+		rn.log.Error("Failed skipping welcome email of imported Subscription.", zap.Error(err), zap.Int64("subscription_id", s.ID))
+	}
+
+	return nil
+}
+
+// update merges the fields of the row into the existing subscription,
+// keeping the ones the row doesn't have
+func (rn *run) update(existing, s *subscription.Subscription) error {
+	if s.Name != "" {
+		existing.Name = s.Name
+	}
+
+	if s.Locale != "" {
+		existing.Locale = s.Locale
+	}
+
+	if s.Timezone != "" {
+		existing.Timezone = s.Timezone
+	}
+
+	existing.Tags = append(existing.Tags, s.Tags...)
+
+	for k, v := range s.Attributes {
+		existing.Attributes[k] = v
+	}
+
+	if err := existing.Validate(); err != nil {
+		return err
+	}
+
+	if rn.imp.Options.DryRun {
+		return nil
+	}
+
+	return existing.Update()
+}
+
+// suppress adds the address to the suppression list, with the service
+// of the import as its source
+func (rn *run) suppress(email string, reason suppression.Reason) error {
+	rn.suppressed[email] = true
+
+	if rn.imp.Options.DryRun {
+		return nil
+	}
+
+	sp := suppression.New()
+	sp.Email = email
+	sp.Reason = reason
+	sp.Source = string(rn.imp.Format)
+
+	return sp.Create()
+}
+
+// flushProgress stores the progress of the import in the database when
+// progressInterval elapsed since the last update
+func (rn *run) flushProgress() {
+	if time.Since(rn.lastFlush) < progressInterval {
+		return
+	}
+
+	rn.lastFlush = time.Now()
+
+	if err := rn.imp.UpdateProgress(); err != nil {
+		rn.log.Error("Failed updating Import progress.", zap.Error(err))
+		return
+	}
+
+	rn.log.Info("Import progress.", zap.String("progress", rn.imp.Progress()))
+}
@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/suppression"
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+// entry is what a row of the file becomes
+type entry struct {
+	s *subscription.Subscription
+	// suppress is set when the address must be suppressed instead of
+	// subscribed, like the ones Mailchimp cleaned after bounces
+	suppress suppression.Reason
+	// skip says why the row isn't imported, like the subscribers that
+	// unsubscribed from the other service
+	skip string
+}
+
+// adapter translates a row of the export of another service
+type adapter func(rec Record) (*entry, error)
+
+// adapters translate the exports of other services, the rows of the
+// other formats are read with the Mapping of the import
+var adapters = map[Format]adapter{
+	FormatMailchimp:  mailchimpEntry,
+	FormatSubstack:   substackEntry,
+	FormatButtondown: buttondownEntry,
+}
+
+// dateLayouts are the layouts of the dates read from the files
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// translate converts a row of the file into an entry, which still needs
+// to be validated
+func translate(format Format, rec Record, m *Mapping) (*entry, error) {
+	if a, ok := adapters[format]; ok {
+		return a(rec)
+	}
+
+	s, err := m.Subscription(rec)
+
+	return &entry{s: s}, err
+}
+
+// vendorSubscription returns a subscription of the email of an export
+func vendorSubscription(email string) (*subscription.Subscription, error) {
+	s := subscription.New()
+
+	s.Email = strings.TrimSpace(email)
+	if s.Email == "" {
+		return nil, fmt.Errorf("email is missing")
+	}
+
+	return s, validation.CheckSyntax(s.Email)
+}
+
+// mailchimpColumns are the columns of Mailchimp audience exports that
+// aren't merge fields
+var mailchimpColumns = map[string]bool{
+	"email address":        true,
+	"first name":           true,
+	"last name":            true,
+	"member_rating":        true,
+	"optin_time":           true,
+	"optin_ip":             true,
+	"confirm_time":         true,
+	"confirm_ip":           true,
+	"latitude":             true,
+	"longitude":            true,
+	"gmtoff":               true,
+	"dstoff":               true,
+	"timezone":             true,
+	"cc":                   true,
+	"region":               true,
+	"last_changed":         true,
+	"leid":                 true,
+	"euid":                 true,
+	"notes":                true,
+	"tags":                 true,
+	"unsub_time":           true,
+	"unsub_campaign_title": true,
+	"unsub_campaign_id":    true,
+	"unsub_reason":         true,
+	"unsub_reason_other":   true,
+	"clean_time":           true,
+	"clean_campaign_title": true,
+	"clean_campaign_id":    true,
+}
+
+// mailchimpEntry translates a row of a Mailchimp audience export, which
+// is a zip archive with the subscribed, unsubscribed and cleaned members
+// in different files. Cleaned members are suppressed as hard bounces, and
+// merge fields like "Company" become custom fields like "company".
+func mailchimpEntry(rec Record) (*entry, error) {
+	s, err := vendorSubscription(rec.String("Email Address"))
+	if err != nil {
+		return &entry{s: s}, err
+	}
+
+	if rec.String("CLEAN_TIME") != "" {
+		return &entry{s: s, suppress: suppression.HardBounce}, nil
+	}
+
+	if rec.String("UNSUB_TIME") != "" {
+		return &entry{s: s, skip: "email unsubscribed in Mailchimp"}, nil
+	}
+
+	s.Name = strings.TrimSpace(rec.String("First Name") + " " + rec.String("Last Name"))
+	s.Timezone = strings.TrimSpace(rec.String("TIMEZONE"))
+	s.Tags = vendorTags(rec.String("TAGS"))
+
+	// members added by the API or imported into Mailchimp have no opt-in
+	// time, only a confirmation one
+	s.CreatedAt = parseDate(rec.String("OPTIN_TIME"))
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = parseDate(rec.String("CONFIRM_TIME"))
+	}
+
+	if rating := rec.String("MEMBER_RATING"); rating != "" {
+		s.Attributes["member_rating"] = rating
+	}
+
+	if country := rec.String("CC"); country != "" {
+		s.Attributes["country"] = country
+	}
+
+	for col, v := range rec {
+		if mailchimpColumns[strings.ToLower(col)] || v == "" {
+			continue
+		}
+
+		s.Attributes[attributeName(col)] = v
+	}
+
+	return &entry{s: s}, nil
+}
+
+// substackEntry translates a row of the email list of a Substack export.
+// Paying subscribers are tagged "paid", with their plan as a custom field.
+func substackEntry(rec Record) (*entry, error) {
+	s, err := vendorSubscription(rec.String("email"))
+	if err != nil {
+		return &entry{s: s}, err
+	}
+
+	if isTrue(rec.String("email_disabled")) {
+		return &entry{s: s, skip: "email disabled in Substack"}, nil
+	}
+
+	s.CreatedAt = parseDate(rec.String("created_at"))
+
+	if isTrue(rec.String("active_subscription")) {
+		s.Tags = append(s.Tags, "paid")
+
+		if plan := rec.String("plan"); plan != "" {
+			s.Attributes["plan"] = plan
+		}
+
+		if expiry := rec.String("expiry"); expiry != "" {
+			s.Attributes["expiry"] = expiry
+		}
+	}
+
+	return &entry{s: s}, nil
+}
+
+// buttondownEntry translates a row of a Buttondown subscribers export.
+// Premium subscribers are tagged "paid" and churned ones "churned", and
+// the metadata of the subscribers become custom fields.
+func buttondownEntry(rec Record) (*entry, error) {
+	s, err := vendorSubscription(rec.String("email"))
+	if err != nil {
+		return &entry{s: s}, err
+	}
+
+	switch t := strings.ToLower(rec.String("subscriber_type")); t {
+	case "", "regular", "gifted", "trialed", "paused":
+	case "premium":
+		s.Tags = append(s.Tags, "paid")
+	case "churned":
+		s.Tags = append(s.Tags, "churned")
+	default:
+		// unactivated, unsubscribed, removed and spammy subscribers
+		return &entry{s: s, skip: fmt.Sprintf("subscriber is %s in Buttondown", t)}, nil
+	}
+
+	s.CreatedAt = parseDate(rec.String("creation_date"))
+	s.Tags = append(s.Tags, vendorTags(rec.String("tags"))...)
+
+	if metadata := rec.String("metadata"); metadata != "" {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+			return &entry{s: s}, fmt.Errorf("invalid metadata: %v", err)
+		}
+
+		for k, v := range fields {
+			s.Attributes[attributeName(k)] = v
+		}
+	}
+
+	for _, col := range []string{"notes", "referrer_url"} {
+		if v := rec.String(col); v != "" {
+			s.Attributes[col] = v
+		}
+	}
+
+	return &entry{s: s}, nil
+}
+
+// vendorTags splits the tags of an export, which are quoted like
+// "VIP","Early Adopter" in Mailchimp and lists like ['vip'] in Buttondown,
+// into valid tags like "vip" and "early-adopter"
+func vendorTags(value string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(value, isTagSeparator) {
+		t = strings.Trim(t, ` "'[]`)
+		if t != "" {
+			tags = append(tags, strings.Join(strings.Fields(strings.ToLower(t)), "-"))
+		}
+	}
+
+	return tags
+}
+
+// attributeName converts a column into the name of a custom field, like
+// "Company Name" into "company_name"
+func attributeName(col string) string {
+	return strings.Join(strings.Fields(strings.ToLower(col)), "_")
+}
+
+// parseDate returns the time of a date in one of the dateLayouts, or the
+// zero time when it's missing or has an unknown layout
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
+
+// isTrue says if the text of a column is a true boolean
+func isTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "t", "yes", "1":
+		return true
+	}
+
+	return false
+}
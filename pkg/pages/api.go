@@ -0,0 +1,262 @@
+package pages
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/protection"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+// GetSubscribePage builds the hosted subscribe form of a project
+func GetSubscribePage(w http.ResponseWriter, r *http.Request) {
+	p, ok := getProject(w, r)
+	if !ok {
+		return
+	}
+
+	data := newPage(p, subscribePage, r)
+	if !prepareForm(w, data) {
+		return
+	}
+
+	render(w, http.StatusOK, subscribePage, data)
+}
+
+// PostSubscribeForm subscribes the reader of the hosted form, or of any
+// HTML form posting email and name, and redirects to the confirmation
+func PostSubscribeForm(w http.ResponseWriter, r *http.Request) {
+	p, ok := getProject(w, r)
+	if !ok {
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", p.ID))
+	data := newPage(p, subscribePage, r)
+
+	b, err := protection.Check(r, p.ID)
+	if err != nil {
+		_log.Error("Failed checking subscription attempt.", zap.Error(err))
+		renderForm(w, http.StatusInternalServerError, data, "Something went wrong, try again later.")
+		return
+	}
+
+	data.Name = strings.TrimSpace(r.PostForm.Get("name"))
+	data.Email = strings.TrimSpace(r.PostForm.Get("email"))
+
+	if b != nil {
+		if b.Silent {
+			redirectConfirm(w, r, data)
+			return
+		}
+
+		b.SetHeaders(w)
+		renderForm(w, b.Code, data, b.Err.Error())
+		return
+	}
+
+	s := subscription.New()
+	s.Email = data.Email
+	s.Name = data.Name
+	s.Locale = r.PostForm.Get("locale")
+	s.Timezone = r.PostForm.Get("timezone")
+
+	if err := s.Validate(); err != nil {
+		_log.Info("Invalid Subscription.", zap.Error(err))
+		renderForm(w, http.StatusBadRequest, data, err.Error())
+		return
+	}
+
+	controller := p.Subscriptions()
+
+	var rejected *validation.RejectedError
+	if err := controller.CheckEmail(r.Context(), s); errors.As(err, &rejected) {
+		_log.Info("Subscription rejected.", zap.String("check", rejected.Check))
+		renderForm(w, http.StatusBadRequest, data, rejected.Error())
+		return
+	} else if err != nil {
+		_log.Error("Failed checking Subscription email.", zap.Error(err))
+		renderForm(w, http.StatusInternalServerError, data, "Something went wrong, try again later.")
+		return
+	}
+
+	err = controller.Add(s)
+	if errors.Is(err, subscription.ErrAlreadySubscribed) {
+		// the form doesn't tell who is subscribed already
+		_log.Info("Subscription already exists.", zap.Int64("subscription_id", s.ID))
+		redirectConfirm(w, r, data)
+		return
+	}
+
+	if err != nil {
+		_log.Error("Failed adding new Subscription.", zap.Error(err))
+		renderForm(w, http.StatusInternalServerError, data, "Something went wrong, try again later.")
+		return
+	}
+
+	_log.Info("Subscription created successfully.", zap.Int64("subscription_id", s.ID))
+	redirectConfirm(w, r, data)
+}
+
+// GetConfirmPage builds the page shown after subscribing
+func GetConfirmPage(w http.ResponseWriter, r *http.Request) {
+	p, ok := getProject(w, r)
+	if !ok {
+		return
+	}
+
+	render(w, http.StatusOK, confirmPage, newPage(p, confirmPage, r))
+}
+
+// GetUnsubscribePage builds the unsubscribe page of the subscription of
+// the token, branded by its project
+func GetUnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	s, err := subscription.GetByToken(token)
+	if err != nil || s == nil {
+		renderNotFound(w)
+		return
+	}
+
+	p, err := project.NewProjects().Get(s.ProjectID)
+	if err != nil || p == nil {
+		renderNotFound(w)
+		return
+	}
+
+	data := newPage(p, unsubscribePage, r)
+	data.Email = s.Email
+	data.Token = token
+
+	render(w, http.StatusOK, unsubscribePage, data)
+}
+
+// PostUnsubscribeForm deletes the subscription of the token and redirects
+// to the goodbye page of its project
+func PostUnsubscribeForm(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	s, err := subscription.GetByToken(token)
+	if err != nil || s == nil {
+		renderNotFound(w)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", s.ProjectID), zap.Int64("subscription_id", s.ID))
+
+	if err := s.Delete(); err != nil {
+		_log.Error("Failed deleting subscription by token.", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	_log.Info("Subscription deleted successfully.")
+	http.Redirect(w, r, fmt.Sprintf("/projects/%d/goodbye", s.ProjectID), http.StatusSeeOther)
+}
+
+// GetGoodbyePage builds the page shown after unsubscribing
+func GetGoodbyePage(w http.ResponseWriter, r *http.Request) {
+	p, ok := getProject(w, r)
+	if !ok {
+		return
+	}
+
+	render(w, http.StatusOK, goodbyePage, newPage(p, goodbyePage, r))
+}
+
+// GetEmbedScript returns the script that embeds the subscribe form of a
+// project in other sites, inside an iframe
+func GetEmbedScript(w http.ResponseWriter, r *http.Request) {
+	p, ok := getProject(w, r)
+	if !ok {
+		return
+	}
+
+	src := fmt.Sprintf("https://%s/projects/%d/subscribe?embed=1", config.C.ApplicationDomain, p.ID)
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	fmt.Fprintf(w, embedScript, strconv.Quote(src))
+}
+
+// getProject loads the project of the route, writing the 404 page when
+// it doesn't exist
+func getProject(w http.ResponseWriter, r *http.Request) (*project.Project, bool) {
+	projectID, err := strconv.Atoi(mux.Vars(r)["project_id"])
+	if err != nil {
+		renderNotFound(w)
+		return nil, false
+	}
+
+	p, err := project.NewProjects().Get(int64(projectID))
+	if err != nil {
+		log.L.Error("Failed getting Project.", zap.Int64("project_id", int64(projectID)), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if p == nil {
+		renderNotFound(w)
+		return nil, false
+	}
+
+	return p, true
+}
+
+// prepareForm issues the challenge of the subscribe form, writing an error
+// when it fails
+func prepareForm(w http.ResponseWriter, data *page) bool {
+	c, err := protection.NewChallenge(data.Project)
+	if err != nil {
+		log.L.Error("Failed issuing form token.", zap.Int64("project_id", data.Project.ID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+
+	data.Challenge = c
+	if widget, ok := captchaWidgets[c.CaptchaProvider]; ok {
+		data.Captcha = &widget
+	}
+
+	return true
+}
+
+// renderForm renders the subscribe form again with an error, keeping what
+// the reader typed
+func renderForm(w http.ResponseWriter, status int, data *page, msg string) {
+	if !prepareForm(w, data) {
+		return
+	}
+
+	data.Error = msg
+	render(w, status, subscribePage, data)
+}
+
+// redirectConfirm redirects the reader to the RedirectURL of the project
+// or to its confirmation page. Embedded forms always go to the latter, so
+// the site isn't loaded inside the iframe.
+func redirectConfirm(w http.ResponseWriter, r *http.Request, data *page) {
+	if data.Branding.RedirectURL != "" && !data.Embedded {
+		http.Redirect(w, r, data.Branding.RedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	target := fmt.Sprintf("/projects/%d/confirm", data.Project.ID)
+	if data.Embedded {
+		target += "?" + url.Values{"embed": {"1"}}.Encode()
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
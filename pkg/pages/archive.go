@@ -0,0 +1,139 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/archive"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+// GetArchivePage builds a page of the list of issues sent by a project
+// with a public archive
+func GetArchivePage(w http.ResponseWriter, r *http.Request) {
+	p, ok := getArchiveProject(w, r)
+	if !ok {
+		return
+	}
+
+	if p.Settings.Archive.Visibility != project.ArchivePublic {
+		renderNotFound(w)
+		return
+	}
+
+	pageNumber := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			renderNotFound(w)
+			return
+		}
+
+		pageNumber = n
+	}
+
+	_log := log.L.With(zap.Int64("project_id", p.ID))
+	controller := archive.NewProjectIssues(p.ID)
+	perPage := p.Settings.Archive.PerPage
+
+	count, err := controller.Count()
+	if err != nil {
+		_log.Error("Failed counting archive issues.", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	issues, err := controller.Page(pageNumber, perPage)
+	if err != nil {
+		_log.Error("Failed listing archive issues.", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(issues) == 0 && pageNumber > 1 {
+		renderNotFound(w)
+		return
+	}
+
+	data := newPage(p, archivePage, r)
+	data.Copy = project.PageCopy{
+		Title: "Archive",
+		Text:  fmt.Sprintf("The issues sent by %s.", p.Name),
+	}
+	data.Issues = issues
+
+	if pageNumber > 1 {
+		data.NewerPage = pageNumber - 1
+	}
+
+	if int64(pageNumber*perPage) < count {
+		data.OlderPage = pageNumber + 1
+	}
+
+	render(w, http.StatusOK, archivePage, data)
+}
+
+// GetArchiveIssue builds the web version of an issue sent by a project
+// with a public or unlisted archive
+func GetArchiveIssue(w http.ResponseWriter, r *http.Request) {
+	p, ok := getArchiveProject(w, r)
+	if !ok {
+		return
+	}
+
+	visibility := p.Settings.Archive.Visibility
+	if visibility == project.ArchivePrivate {
+		renderNotFound(w)
+		return
+	}
+
+	number, err := strconv.Atoi(mux.Vars(r)["issue"])
+	if err != nil {
+		renderNotFound(w)
+		return
+	}
+
+	i, err := archive.NewProjectIssues(p.ID).Get(int64(number))
+	if err != nil {
+		log.L.Error("Failed getting archive issue.", zap.Int64("project_id", p.ID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if i == nil {
+		renderNotFound(w)
+		return
+	}
+
+	if visibility == project.ArchiveUnlisted {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, i.Content)
+}
+
+// getArchiveProject loads the project of the slug of the route, writing
+// the 404 page when it doesn't exist
+func getArchiveProject(w http.ResponseWriter, r *http.Request) (*project.Project, bool) {
+	slug := mux.Vars(r)["slug"]
+
+	p, err := project.NewProjects().GetBySlug(slug)
+	if err != nil {
+		log.L.Error("Failed getting Project.", zap.String("slug", slug), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if p == nil {
+		renderNotFound(w)
+		return nil, false
+	}
+
+	return p, true
+}
@@ -0,0 +1,29 @@
+package pages
+
+// embedScript inserts the iframe of the subscribe form, whose address
+// fills the %s, after the script tag or inside the element of its
+// data-target selector. The data-height attribute sets the height of the
+// iframe in pixels.
+const embedScript = `(function () {
+  var script = document.currentScript;
+  if (!script) {
+    return;
+  }
+
+  var frame = document.createElement('iframe');
+  frame.src = %s;
+  frame.title = 'Subscribe';
+  frame.loading = 'lazy';
+  frame.style.border = '0';
+  frame.style.width = '100%%';
+  frame.style.height = (parseInt(script.getAttribute('data-height'), 10) || 260) + 'px';
+
+  var target = script.getAttribute('data-target');
+  var parent = target ? document.querySelector(target) : null;
+  if (parent) {
+    parent.appendChild(frame);
+  } else {
+    script.parentNode.insertBefore(frame, script.nextSibling);
+  }
+})();
+`
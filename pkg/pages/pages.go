@@ -0,0 +1,113 @@
+package pages
+
+import (
+	"html/template"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/archive"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/protection"
+)
+
+// Names of the hosted pages, each one is a template in static/pages
+// rendered inside the layout
+const (
+	subscribePage   = "subscribe"
+	confirmPage     = "confirm"
+	unsubscribePage = "unsubscribe"
+	goodbyePage     = "goodbye"
+	archivePage     = "archive"
+)
+
+// captchaWidget is the script and the element of the widget of a CAPTCHA
+// provider, which adds the response to the form. The fake provider has no
+// widget, the form asks for its response in a text field.
+type captchaWidget struct {
+	Script string
+	Class  string
+}
+
+var captchaWidgets = map[string]captchaWidget{
+	"hcaptcha":  {"https://js.hcaptcha.com/1/api.js", "h-captcha"},
+	"turnstile": {"https://challenges.cloudflare.com/turnstile/v0/api.js", "cf-turnstile"},
+	"recaptcha": {"https://www.google.com/recaptcha/api.js", "g-recaptcha"},
+	"fake":      {"", ""},
+}
+
+// page is the data of a hosted page
+type page struct {
+	Project  *project.Project
+	Branding project.BrandingSettings
+	Copy     project.PageCopy
+	SiteURL  string
+	// Embedded pages are shown inside the iframe of embed.js, without
+	// the logo and margins
+	Embedded bool
+	Error    string
+
+	// fields of the subscribe page
+	Name      string
+	Email     string
+	Honeypot  string
+	Challenge *protection.Challenge
+	Captcha   *captchaWidget
+
+	// fields of the unsubscribe page
+	Token string
+
+	// fields of the archive page, NewerPage and OlderPage are zero when
+	// there are no pages before or after this one
+	Issues    []*archive.Issue
+	NewerPage int
+	OlderPage int
+}
+
+// newPage returns the data of a hosted page of the project
+func newPage(p *project.Project, name string, r *http.Request) *page {
+	b := p.Settings.Branding
+
+	copies := map[string]project.PageCopy{
+		subscribePage:   b.Subscribe,
+		confirmPage:     b.Confirm,
+		unsubscribePage: b.Unsubscribe,
+		goodbyePage:     b.Goodbye,
+	}
+
+	return &page{
+		Project:  p,
+		Branding: b,
+		Copy:     copies[name],
+		SiteURL:  p.SiteURL(),
+		Embedded: r.URL.Query().Get("embed") == "1",
+		Honeypot: protection.HoneypotField,
+	}
+}
+
+// render writes a hosted page with the status
+func render(w http.ResponseWriter, status int, name string, data *page) {
+	tmpl, err := template.ParseFiles("static/pages/layout.html", "static/pages/"+name+".html")
+	if err != nil {
+		log.L.Error("Failed parsing page template.", zap.String("page", name), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.L.Error("Failed rendering page.", zap.String("page", name), zap.Error(err))
+	}
+}
+
+// renderNotFound writes the 404 page
+func renderNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	tmpl := template.Must(template.ParseFiles("static/404/index.html"))
+	tmpl.Execute(w, nil)
+}
@@ -11,17 +11,18 @@ import (
 func insertPipeline(p *Pipeline) error {
 	query := `
 		INSERT INTO pipelines
-		  (project_id)
+		  (project_id, pipeline_type)
 		VALUES
-		  ($1)
+		  ($1, $2)
 		RETURNING
 		  pipeline_id,
 		  project_id,
+		  pipeline_type,
 		  created_at,
 		  updated_at
 	`
 
-	savedPipeline, err := scanPipeline(query, p.ProjectID)
+	savedPipeline, err := scanPipeline(query, p.ProjectID, p.Type)
 	if err != nil {
 		return err
 	}
@@ -31,18 +32,20 @@ func insertPipeline(p *Pipeline) error {
 	return err
 }
 
-// getLastPipeline return a single row that matches the last pipeline for a given project_id
+// getLastPipeline return a single row that matches the last feed pipeline for a given project_id
 func getLastPipeline(projectID int64) (*Pipeline, error) {
 	query := `
 		SELECT
 		  pipeline_id,
 		  project_id,
+		  pipeline_type,
 		  created_at,
 		  updated_at
 		FROM
 		  pipelines
 		WHERE
 		  project_id = $1
+		  AND pipeline_type = 'Feed'
 		ORDER BY
 		  created_at
 		DESC
@@ -56,7 +59,7 @@ func getLastPipeline(projectID int64) (*Pipeline, error) {
 func getPipelinesByProjectID(projectID int64) ([]*Pipeline, error) {
 	query := `
 		SELECT 
-		  pipeline_id,project_id,created_at,updated_at
+		  pipeline_id,project_id,pipeline_type,created_at,updated_at
 		FROM
 		  pipelines
 		WHERE
@@ -87,7 +90,7 @@ func scanPipelines(query string, params ...interface{}) ([]*Pipeline, error) {
 	for rows.Next() {
 		p := New()
 
-		if err := rows.Scan(&p.ID, &p.ProjectID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.ProjectID, &p.Type, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return ps, fmt.Errorf("unable to scan pipeline row: %v", err)
 		}
 
@@ -109,7 +112,7 @@ func scanPipeline(query string, params ...interface{}) (*Pipeline, error) {
 	row := db.QueryRow(query, params...)
 
 	p := &Pipeline{}
-	if err := row.Scan(&p.ID, &p.ProjectID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+	if err := row.Scan(&p.ID, &p.ProjectID, &p.Type, &p.CreatedAt, &p.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to scan pipeline row: %v", err)
 		}
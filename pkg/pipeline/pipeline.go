@@ -8,15 +8,25 @@ import (
 	"github.com/statictask/newsletter/pkg/task"
 )
 
+type PipelineType string
+
+const (
+	// Feed pipelines scrape the project's feed and publish the new items
+	Feed PipelineType = "Feed"
+	// Broadcast pipelines publish a campaign, skipping the scraping
+	Broadcast PipelineType = "Broadcast"
+)
+
 type Pipeline struct {
 	ID        int64
 	ProjectID int64
+	Type      PipelineType
 	CreatedAt *time.Time
 	UpdatedAt *time.Time
 }
 
 func New() *Pipeline {
-	return &Pipeline{}
+	return &Pipeline{Type: Feed}
 }
 
 // Create the Pipeline in the database
@@ -31,7 +31,7 @@ func (ps *ProjectPipelines) Create() (*Pipeline, error) {
 	return p, nil
 }
 
-// Last returns the last pipeline of the respective Project
+// Last returns the last Feed pipeline of the respective Project
 func (ps *ProjectPipelines) Last() (*Pipeline, error) {
 	return getLastPipeline(ps.projectID)
 }
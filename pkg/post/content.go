@@ -3,6 +3,7 @@ package post
 import (
 
 	"fmt"
+	"html"
 
 	"github.com/statictask/newsletter/pkg/postitem"
 )
@@ -16,27 +17,36 @@ func NewContentBuilder(title string, items []*postitem.PostItem) *ContentBuilder
 	return &ContentBuilder{title, items}
 }
 
+// BuildHTML builds a single HTML document with all the items. Titles and
+// links are escaped, while the content was already sanitized when the
+// feed was scraped, unless the item is older than the sanitizer.
 func (c *ContentBuilder) BuildHTML() (string, error) {
 	if len(c.items) == 0 {
 		return "", fmt.Errorf("empty list of content items to build")
 	}
 
+	title := html.EscapeString(c.title)
 	body := "<html>\n" +
 		"  <head>\n" +
-		"    <title>" + c.title + "</title>\n" +
+		"    <title>" + title + "</title>\n" +
 		"  </head>\n" +
 		"  <body>\n" +
-		"    <h1>" + c.title + "</h1>\n" +
+		"    <h1>" + title + "</h1>\n" +
 		"    <br>\n"
 
 	for _, i := range c.items {
+		content := i.Content
+		if !i.Sanitized {
+			content = html.EscapeString(content)
+		}
+
 		body = body +
 			"    <hr>\n" +
-			"    <a href=\"" + i.Link + "\">\n" +
-			"      <h3>" + i.Title + "</h3>\n" +
+			"    <a href=\"" + html.EscapeString(i.Link) + "\">\n" +
+			"      <h3>" + html.EscapeString(i.Title) + "</h3>\n" +
 			"    </a>\n" +
 			"    <br>\n" +
-			"    <p>" + i.Content + "</p>\n"
+			"    <p>" + content + "</p>\n"
 	}
 
 	body = body +
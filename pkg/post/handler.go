@@ -57,6 +57,27 @@ func getPostsByProjectID(projectID int64) ([]*Post, error) {
 	return scanPosts(query, projectID)
 }
 
+// getPost returns a single post of the project
+func getPost(projectID, postID int64) (*Post, error) {
+	query := `
+		SELECT
+		  p.post_id,
+		  p.pipeline_id,
+		  p.title,
+		  p.created_at,
+		  p.updated_at
+		FROM
+		  posts AS p
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = $1
+		  AND p.post_id = $2
+	`
+
+	return scanPost(query, projectID, postID)
+}
+
 // getPostByPipelineID return a single row that matches a given expression
 func getPostByPipelineID(pipelineID int64) (*Post, error) {
 	query := `
@@ -75,8 +96,8 @@ func getPostByPipelineID(pipelineID int64) (*Post, error) {
 	return scanPost(query, pipelineID)
 }
 
-// getLastPostByProjectID returns all posts in the database based
-// on a given expression
+// getLastPostByProjectID returns the last post scraped from the
+// project's feed
 func getLastPostByProjectID(projectID int64) (*Post, error) {
 	query := `
 		SELECT
@@ -91,6 +112,7 @@ func getLastPostByProjectID(projectID int64) (*Post, error) {
 		  ON p.pipeline_id = pl.pipeline_id
 		WHERE
 		  pl.project_id = $1
+		  AND pl.pipeline_type = 'Feed'
 		ORDER BY
 		  p.created_at
 		DESC
@@ -100,6 +122,122 @@ func getLastPostByProjectID(projectID int64) (*Post, error) {
 	return scanPost(query, projectID)
 }
 
+// getRecentPostsByProjectID returns the last n posts scraped from the
+// project's feed
+func getRecentPostsByProjectID(projectID int64, n int) ([]*Post, error) {
+	query := `
+		SELECT
+		  p.post_id,
+		  p.pipeline_id,
+		  p.title,
+		  p.created_at,
+		  p.updated_at
+		FROM
+		  posts AS p
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = $1
+		  AND pl.pipeline_type = 'Feed'
+		ORDER BY
+		  p.created_at
+		DESC
+		LIMIT $2
+	`
+
+	return scanPosts(query, projectID, n)
+}
+
+// getPostIssueNumber returns the position of the post among the posts
+// scraped from the project's feed, starting from 1
+func getPostIssueNumber(postID int64) (int64, error) {
+	query := `
+		SELECT
+		  COUNT(*)
+		FROM
+		  posts AS p
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = (
+		    SELECT
+		      pl2.project_id
+		    FROM
+		      posts AS p2
+		    JOIN pipelines AS pl2
+		      ON p2.pipeline_id = pl2.pipeline_id
+		    WHERE
+		      p2.post_id = $1
+		  )
+		  AND pl.pipeline_type = 'Feed'
+		  AND p.post_id <= $1
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	var issue int64
+	if err := db.QueryRow(query, postID).Scan(&issue); err != nil {
+		return 0, fmt.Errorf("unable to scan post issue number: %v", err)
+	}
+
+	return issue, nil
+}
+
+// getCategoriesByProjectID returns the distinct categories of the items
+// scraped from the project's feed, regardless of their case
+func getCategoriesByProjectID(projectID int64) ([]string, error) {
+	query := `
+		SELECT
+		  MIN(c.category)
+		FROM
+		  post_items AS pi
+		JOIN posts AS p
+		  ON pi.post_id = p.post_id
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		CROSS JOIN LATERAL
+		  unnest(pi.categories) AS c(category)
+		WHERE
+		  pl.project_id = $1
+		  AND pl.pipeline_type = 'Feed'
+		GROUP BY
+		  LOWER(c.category)
+		ORDER BY
+		  LOWER(c.category)
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	categories := []string{}
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return categories, fmt.Errorf("unable to scan category row: %v", err)
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
 // scanPost returns a single post based on the given query
 func scanPost(query string, params ...interface{}) (*Post, error) {
 	db, err := database.Connect()
@@ -60,6 +60,16 @@ func (p *Post) GetHTMLContent() (string, error) {
 	return builder.BuildHTML()
 }
 
+// IssueNumber returns the sequential number of the post in its project
+func (p *Post) IssueNumber() (int64, error) {
+	issue, err := getPostIssueNumber(p.ID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get post issue number: %v", err)
+	}
+
+	return issue, nil
+}
+
 // GetSubject returns the Title of the post
 func (p *Post) GetSubject() string {
 	return p.Title
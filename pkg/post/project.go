@@ -16,7 +16,23 @@ func (pp *ProjectPosts) All() ([]*Post, error) {
 	return getPostsByProjectID(pp.projectID)
 }
 
+// Get returns a single project's post, or nil if it doesn't exist
+func (pp *ProjectPosts) Get(postID int64) (*Post, error) {
+	return getPost(pp.projectID, postID)
+}
+
 // Last returns the last project's post
 func (pp *ProjectPosts) Last() (*Post, error) {
 	return getLastPostByProjectID(pp.projectID)
 }
+
+// Recent returns the last n project's posts, the most recent first
+func (pp *ProjectPosts) Recent(n int) ([]*Post, error) {
+	return getRecentPostsByProjectID(pp.projectID, n)
+}
+
+// Categories returns the categories of the items scraped from the
+// project's feed, which are the topics subscribers can choose
+func (pp *ProjectPosts) Categories() ([]string, error) {
+	return getCategoriesByProjectID(pp.projectID)
+}
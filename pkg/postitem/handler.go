@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/lib/pq"
+
 	"github.com/statictask/newsletter/internal/database"
 )
 
@@ -14,13 +16,19 @@ func insertPostItem(p *PostItem) error {
 		  post_id,
 		  title,
 		  link,
-		  content
+		  content,
+		  original_content,
+		  categories,
+		  sanitized
 	        )
 		VALUES (
 		  $1,
 		  $2,
 		  $3,
-		  $4
+		  $4,
+		  $5,
+		  COALESCE($6, '{}'::TEXT[]),
+		  true
 	        )
 		RETURNING
 		  post_item_id,
@@ -28,11 +36,14 @@ func insertPostItem(p *PostItem) error {
 		  title,
 		  link,
 		  content,
+		  original_content,
+		  categories,
+		  sanitized,
 		  created_at,
 		  updated_at
 	`
 
-	savedPostItem, err := scanPostItem(query, p.PostID, p.Title, p.Link, p.Content)
+	savedPostItem, err := scanPostItem(query, p.PostID, p.Title, p.Link, p.Content, p.OriginalContent, pq.Array(p.Categories))
 	if err != nil {
 		return err
 	}
@@ -51,6 +62,9 @@ func getPostItemsByPostID(postID int64) ([]*PostItem, error) {
 		  title,
 		  link,
 		  content,
+		  original_content,
+		  categories,
+		  sanitized,
 		  created_at,
 		  updated_at
 		FROM
@@ -62,6 +76,57 @@ func getPostItemsByPostID(postID int64) ([]*PostItem, error) {
 	return scanPostItems(query, postID)
 }
 
+// getUnsanitizedPostItemsByProjectID returns the items of the project
+// scraped before their content was sanitized
+func getUnsanitizedPostItemsByProjectID(projectID int64) ([]*PostItem, error) {
+	query := `
+		SELECT
+		  pi.post_item_id,
+		  pi.post_id,
+		  pi.title,
+		  pi.link,
+		  pi.content,
+		  pi.original_content,
+		  pi.categories,
+		  pi.sanitized,
+		  pi.created_at,
+		  pi.updated_at
+		FROM
+		  post_items AS pi
+		JOIN posts AS p
+		  ON pi.post_id = p.post_id
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = $1
+		  AND NOT pi.sanitized
+	`
+
+	return scanPostItems(query, projectID)
+}
+
+// updatePostItemContent replaces the content of a PostItem with its
+// sanitized version. Items scraped before the original content was kept
+// get their current content as the original one first.
+func updatePostItemContent(p *PostItem) error {
+	query := `
+		UPDATE
+		  post_items
+		SET
+		  original_content = CASE WHEN original_content = '' THEN content ELSE original_content END,
+		  content=$1,
+		  sanitized=true
+		WHERE
+		  post_item_id=$2
+	`
+
+	if err := database.Exec(query, p.Content, p.ID); err != nil {
+		return fmt.Errorf("failed updating post_item content: %v", err)
+	}
+
+	return nil
+}
+
 // scanPostItem returns a single post based on the given query
 func scanPostItem(query string, params ...interface{}) (*PostItem, error) {
 	db, err := database.Connect()
@@ -74,7 +139,7 @@ func scanPostItem(query string, params ...interface{}) (*PostItem, error) {
 	row := db.QueryRow(query, params...)
 	p := &PostItem{}
 
-	if err := row.Scan(&p.ID, &p.PostID, &p.Title, &p.Link, &p.Content, &p.CreatedAt, &p.UpdatedAt); err != nil {
+	if err := row.Scan(&p.ID, &p.PostID, &p.Title, &p.Link, &p.Content, &p.OriginalContent, pq.Array(&p.Categories), &p.Sanitized, &p.CreatedAt, &p.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to scan post_item row: %v", err)
 		}
@@ -106,7 +171,7 @@ func scanPostItems(query string, params ...interface{}) ([]*PostItem, error) {
 	for rows.Next() {
 		p := New()
 
-		if err := rows.Scan(&p.ID, &p.PostID, &p.Title, &p.Link, &p.Content, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.PostID, &p.Title, &p.Link, &p.Content, &p.OriginalContent, pq.Array(&p.Categories), &p.Sanitized, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return ps, fmt.Errorf("unable to scan post_item row: %v", err)
 		}
 
@@ -15,3 +15,20 @@ func NewPostPostItems(postID int64) *PostPostItems {
 func (pp *PostPostItems) All() ([]*PostItem, error) {
 	return getPostItemsByPostID(pp.postID)
 }
+
+// ProjectPostItems is the entity used for lazy controlling interactions
+// with the PostItems of all posts of a project
+type ProjectPostItems struct {
+	projectID int64
+}
+
+// NewProjectPostItems returns a ProjectPostItems controller
+func NewProjectPostItems(projectID int64) *ProjectPostItems {
+	return &ProjectPostItems{projectID}
+}
+
+// Unsanitized returns the project's items scraped before their content
+// was sanitized
+func (pp *ProjectPostItems) Unsanitized() ([]*PostItem, error) {
+	return getUnsanitizedPostItemsByProjectID(pp.projectID)
+}
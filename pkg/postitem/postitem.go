@@ -10,7 +10,16 @@ type PostItem struct {
 	PostID 	   int64
 	Title      string
 	Link       string
+	// Content is the sanitized HTML sent to subscribers while the
+	// OriginalContent is kept as it was published in the feed
 	Content    string
+	OriginalContent string
+	// Sanitized is false for the items scraped before their content was
+	// sanitized, which must be escaped until it's sanitized
+	Sanitized  bool
+	// Categories come from the feed, subscribers choose topics among
+	// them
+	Categories []string
 	CreatedAt  *time.Time
 	UpdatedAt  *time.Time
 }
@@ -27,3 +36,21 @@ func (p *PostItem) Create() error {
 
 	return nil
 }
+
+// SetSanitizedContent stores the sanitized version of the content of an
+// item scraped before the content was sanitized, keeping the content as
+// the original one when the item has none
+func (p *PostItem) SetSanitizedContent(content string) error {
+	if p.OriginalContent == "" {
+		p.OriginalContent = p.Content
+	}
+
+	p.Content = content
+	p.Sanitized = true
+
+	if err := updatePostItemContent(p); err != nil {
+		return fmt.Errorf("unable to update post_item: %v", err)
+	}
+
+	return nil
+}
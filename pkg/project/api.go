@@ -2,6 +2,7 @@ package project
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -22,6 +23,27 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := project.Settings.Validate(); err != nil {
+		log.L.Error("Invalid Project settings.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := project.ValidateSender(); err != nil {
+		log.L.Error("Invalid Project sender.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := project.ValidateSlug(); err != nil {
+		log.L.Error("Invalid Project slug.", zap.Error(err))
+		utils.WriteJSONResponseError(w, slugErrorStatus(err), err)
+		return
+	}
+
+	// segments belong to a project, so new ones have none
+	project.Settings.Audience.SegmentID = 0
+
 	if err := project.Create(); err != nil {
 		log.L.Error("Failed creating a new Project.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -113,6 +135,30 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := project.Settings.Validate(); err != nil {
+		_log.Error("Invalid Project settings.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := project.ValidateSender(); err != nil {
+		_log.Error("Invalid Project sender.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := project.ValidateSlug(); err != nil {
+		_log.Error("Invalid Project slug.", zap.Error(err))
+		utils.WriteJSONResponseError(w, slugErrorStatus(err), err)
+		return
+	}
+
+	if _, err := project.Audience(); err != nil {
+		_log.Error("Invalid Project audience.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	if err := project.Update(); err != nil {
 		_log.Error("Failed updating project.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -161,3 +207,12 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	_log.Info(msg)
 	utils.WriteJSONResponseMessage(w, http.StatusNoContent, msg)
 }
+
+// slugErrorStatus returns the HTTP status of a slug validation error
+func slugErrorStatus(err error) int {
+	if errors.Is(err, ErrSlugTaken) {
+		return http.StatusConflict
+	}
+
+	return http.StatusBadRequest
+}
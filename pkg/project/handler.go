@@ -12,22 +12,54 @@ func insertProject(p *Project) error {
 	query := `
 		INSERT INTO projects (
 		  name,
-		  feed_url
+		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
+		  slug
 	  	)
 		VALUES (
 		  $1,
-		  $2
+		  $2,
+		  $3,
+		  $4,
+		  $5,
+		  $6,
+		  $7,
+		  $8,
+		  $9
 		)
 		RETURNING
 		  project_id,
 		  name,
+		  slug,
 		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
 		  is_enabled,
 		  created_at,
 		  updated_at
 	`
 
-	savedProject, err := scanProject(query, p.Name, p.FeedURL)
+	savedProject, err := scanProject(
+		query,
+		p.Name,
+		p.FeedURL,
+		p.EmailLayout,
+		p.Settings,
+		p.FromName,
+		p.FromEmail,
+		p.ReplyTo,
+		p.SenderDomain,
+		p.Slug,
+	)
 	if err != nil {
 		return err
 	}
@@ -45,12 +77,33 @@ func updateProject(p *Project) error {
 		SET
 		  name=$1,
 		  feed_url=$2,
-		  is_enabled=$3
+		  email_layout=$3,
+		  settings=$4,
+		  from_name=$5,
+		  from_email=$6,
+		  reply_to=$7,
+		  sender_domain=$8,
+		  is_enabled=$9,
+		  slug=$10
 		WHERE
-		  project_id=$4
+		  project_id=$11
 	`
 
-	if err := database.Exec(query, p.Name, p.FeedURL, p.IsEnabled, p.ID); err != nil {
+	params := []interface{}{
+		p.Name,
+		p.FeedURL,
+		p.EmailLayout,
+		p.Settings,
+		p.FromName,
+		p.FromEmail,
+		p.ReplyTo,
+		p.SenderDomain,
+		p.IsEnabled,
+		p.Slug,
+		p.ID,
+	}
+
+	if err := database.Exec(query, params...); err != nil {
 		return fmt.Errorf("failed updating project: %v", err)
 	}
 
@@ -68,13 +121,44 @@ func deleteProject(projectID int64) error {
 	return nil
 }
 
+// getProjects returns every project
+func getProjects() ([]*Project, error) {
+	query := `
+		SELECT
+		  project_id,
+		  name,
+		  slug,
+		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
+		  is_enabled,
+		  created_at,
+		  updated_at
+		FROM
+		  projects
+	`
+
+	return scanProjects(query)
+}
+
 // getEnabledProjects returns projects that match is_enabled = true
 func getEnabledProjects() ([]*Project, error) {
 	query := `
 		SELECT
 		  project_id,
 		  name,
+		  slug,
 		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
 		  is_enabled,
 		  created_at,
 		  updated_at
@@ -93,7 +177,14 @@ func getProjectByTaskID(taskID int64) (*Project, error) {
 		SELECT
 		  pr.project_id,
 		  pr.name,
+		  pr.slug,
 		  pr.feed_url,
+		  pr.email_layout,
+		  pr.settings,
+		  pr.from_name,
+		  pr.from_email,
+		  pr.reply_to,
+		  pr.sender_domain,
 		  pr.is_enabled,
 		  pr.created_at,
 		  pr.updated_at
@@ -115,7 +206,14 @@ func getProjectByID(projectID int64) (*Project, error) {
 		SELECT
 		  project_id,
 		  name,
+		  slug,
 		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
 		  is_enabled,
 		  created_at,
 		  updated_at
@@ -128,6 +226,32 @@ func getProjectByID(projectID int64) (*Project, error) {
 	return scanProject(query, projectID)
 }
 
+// getProjectBySlug returns a single project based on its slug
+func getProjectBySlug(slug string) (*Project, error) {
+	query := `
+		SELECT
+		  project_id,
+		  name,
+		  slug,
+		  feed_url,
+		  email_layout,
+		  settings,
+		  from_name,
+		  from_email,
+		  reply_to,
+		  sender_domain,
+		  is_enabled,
+		  created_at,
+		  updated_at
+		FROM
+		  projects
+		WHERE
+		  slug = $1
+	`
+
+	return scanProject(query, slug)
+}
+
 // scanProject returns a single project based on the given query
 func scanProject(query string, params ...interface{}) (*Project, error) {
 	db, err := database.Connect()
@@ -140,7 +264,7 @@ func scanProject(query string, params ...interface{}) (*Project, error) {
 	row := db.QueryRow(query, params...)
 	p := New()
 
-	if err := row.Scan(&p.ID, &p.Name, &p.FeedURL, &p.IsEnabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
+	if err := row.Scan(&p.ID, &p.Name, &p.Slug, &p.FeedURL, &p.EmailLayout, &p.Settings, &p.FromName, &p.FromEmail, &p.ReplyTo, &p.SenderDomain, &p.IsEnabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to scan project row: %v", err)
 		}
@@ -172,7 +296,7 @@ func scanProjects(query string, params ...interface{}) ([]*Project, error) {
 	for rows.Next() {
 		p := New()
 
-		if err := rows.Scan(&p.ID, &p.Name, &p.FeedURL, &p.IsEnabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Slug, &p.FeedURL, &p.EmailLayout, &p.Settings, &p.FromName, &p.FromEmail, &p.ReplyTo, &p.SenderDomain, &p.IsEnabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return projects, fmt.Errorf("unable to scan a project row: %v", err)
 		}
 
@@ -2,26 +2,42 @@ package project
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/statictask/newsletter/pkg/pipeline"
 	"github.com/statictask/newsletter/pkg/post"
+	"github.com/statictask/newsletter/pkg/segment"
 	"github.com/statictask/newsletter/pkg/subscription"
 	"github.com/statictask/newsletter/pkg/template"
 )
 
 type Project struct {
-	ID        int64      `json:"project_id"`
-	Name      string     `json:"name"`
-	FeedURL   string     `json:"feed_url"`
-	IsEnabled bool       `json:"is_enabled"`
-	CreatedAt *time.Time `json:"created_at"`
-	UpdatedAt *time.Time `json:"updated_at"`
+	ID   int64  `json:"project_id"`
+	Name string `json:"name"`
+	// Slug identifies the project in the URLs of its public pages, like
+	// the archive at /p/{slug}/archive
+	Slug    string `json:"slug"`
+	FeedURL string `json:"feed_url"`
+	// EmailLayout wraps the content of Markdown email templates, it must
+	// contain a {{ yield }} marker. The default layout is used if empty.
+	EmailLayout string   `json:"email_layout"`
+	Settings    Settings `json:"settings"`
+	// FromName, FromEmail and ReplyTo identify the project in its emails,
+	// the global publisher is used for the ones that are empty. FromEmail
+	// must belong to SenderDomain, when it's set, and to a verified domain.
+	FromName     string     `json:"from_name"`
+	FromEmail    string     `json:"from_email"`
+	ReplyTo      string     `json:"reply_to"`
+	SenderDomain string     `json:"sender_domain"`
+	IsEnabled    bool       `json:"is_enabled"`
+	CreatedAt    *time.Time `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
 }
 
-// New returns an empty Project
+// New returns an empty Project with default Settings
 func New() *Project {
-	return &Project{}
+	return &Project{Settings: DefaultSettings()}
 }
 
 // Create the project in the database
@@ -51,11 +67,46 @@ func (p *Project) Delete() error {
 	return nil
 }
 
+// SiteURL returns the root URL of the site serving the project's feed
+func (p *Project) SiteURL() string {
+	u, err := url.Parse(p.FeedURL)
+	if err != nil || u.Host == "" {
+		return p.FeedURL
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+}
+
 // Subscriptions return a lazy inteface for interacting with project's subscriptions
 func (p *Project) Subscriptions() *subscription.ProjectSubscriptions {
 	return subscription.NewProjectSubscriptions(p.ID)
 }
 
+// Segments returns a lazy interface for interacting with project's segments
+func (p *Project) Segments() *segment.ProjectSegments {
+	return segment.NewProjectSegments(p.ID)
+}
+
+// Audience returns the segment that receives the project's emails, nil
+// when they are sent to every subscriber
+func (p *Project) Audience() (*segment.Segment, error) {
+	segmentID := p.Settings.Audience.SegmentID
+	if segmentID == 0 {
+		return nil, nil
+	}
+
+	s, err := p.Segments().Get(segmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		return nil, fmt.Errorf("audience segment %d not found", segmentID)
+	}
+
+	return s, nil
+}
+
 // Pipelines return a lazy inteface for interacting with project's pipelines
 func (p *Project) Pipelines() *pipeline.ProjectPipelines {
 	return pipeline.NewProjectPipelines(p.ID)
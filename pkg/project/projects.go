@@ -16,6 +16,11 @@ func (pp *Projects) Get(projectID int64) (*Project, error) {
 	return getProjectByID(projectID)
 }
 
+// GetBySlug returns a single project according to its slug
+func (pp *Projects) GetBySlug(slug string) (*Project, error) {
+	return getProjectBySlug(slug)
+}
+
 // Delete deletes a project based on its ID
 func (pp *Projects) Delete(projectID int64) error {
 	if err := deleteProject(projectID); err != nil {
@@ -25,6 +30,11 @@ func (pp *Projects) Delete(projectID int64) error {
 	return nil
 }
 
+// All returns all the projects registered in the database
+func (pp *Projects) All() ([]*Project, error) {
+	return getProjects()
+}
+
 // AllEnabled returns all the projects registered in the database that are enabled
 func (pp *Projects) AllEnabled() ([]*Project, error) {
 	return getEnabledProjects()
@@ -0,0 +1,114 @@
+package project
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// Sender is the identity used in the emails of a project
+type Sender struct {
+	Name    string
+	Email   string
+	ReplyTo string
+}
+
+// Sender returns the identity of the project, using the global publisher
+// for the fields that aren't set. Projects with a SenderDomain but no
+// FromEmail send from the global publisher's mailbox in their domain.
+func (p *Project) Sender() *Sender {
+	s := &Sender{
+		Name:    p.FromName,
+		Email:   p.FromEmail,
+		ReplyTo: p.ReplyTo,
+	}
+
+	if s.Name == "" {
+		s.Name = config.C.PublisherName
+	}
+
+	if s.Email == "" {
+		s.Email = config.C.PublisherEmail
+		if p.SenderDomain != "" {
+			s.Email = localPart(config.C.PublisherEmail) + "@" + p.SenderDomain
+		}
+	}
+
+	return s
+}
+
+// ValidateSender checks the project's addresses and makes sure emails
+// are only sent from verified domains
+func (p *Project) ValidateSender() error {
+	p.SenderDomain = strings.ToLower(strings.TrimSpace(p.SenderDomain))
+
+	if p.SenderDomain != "" && !IsVerifiedDomain(p.SenderDomain) {
+		return fmt.Errorf("sender domain '%s' isn't verified", p.SenderDomain)
+	}
+
+	if p.FromEmail != "" {
+		addr, err := mail.ParseAddress(p.FromEmail)
+		if err != nil {
+			return fmt.Errorf("invalid from_email: %v", err)
+		}
+
+		p.FromEmail = addr.Address
+		domain := domainPart(p.FromEmail)
+
+		if p.SenderDomain != "" && domain != p.SenderDomain {
+			return fmt.Errorf("from_email must belong to the sender domain '%s'", p.SenderDomain)
+		}
+
+		if !IsVerifiedDomain(domain) {
+			return fmt.Errorf("from_email domain '%s' isn't verified", domain)
+		}
+	}
+
+	if p.ReplyTo != "" {
+		addr, err := mail.ParseAddress(p.ReplyTo)
+		if err != nil {
+			return fmt.Errorf("invalid reply_to: %v", err)
+		}
+
+		p.ReplyTo = addr.Address
+	}
+
+	return nil
+}
+
+// IsVerifiedDomain says if emails can be sent from the domain. The domain
+// of the global publisher is always verified.
+func IsVerifiedDomain(domain string) bool {
+	domain = strings.ToLower(domain)
+	if domain == domainPart(config.C.PublisherEmail) {
+		return true
+	}
+
+	for _, d := range strings.Split(config.C.VerifiedSenderDomains, ",") {
+		if strings.ToLower(strings.TrimSpace(d)) == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localPart returns the part of the address before the @
+func localPart(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[:i]
+	}
+
+	return address
+}
+
+// domainPart returns the lower case part of the address after the @
+func domainPart(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return strings.ToLower(address[i+1:])
+	}
+
+	return ""
+}
@@ -0,0 +1,426 @@
+package project
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+// Settings holds per-project options that change how issues are built
+// and delivered. They're stored as JSON in the projects table, so options
+// missing from the stored document keep their default values.
+type Settings struct {
+	Processing ProcessingSettings `json:"processing"`
+	Sanitizer  SanitizerSettings  `json:"sanitizer"`
+	Content    ContentSettings    `json:"content"`
+	Tracking   TrackingSettings   `json:"tracking"`
+	UTM        UTMSettings        `json:"utm"`
+	Delivery   DeliverySettings   `json:"delivery"`
+	Welcome    WelcomeSettings    `json:"welcome"`
+	Audience   AudienceSettings   `json:"audience"`
+	// Validation chooses which addresses are flagged or rejected when
+	// they subscribe
+	Validation validation.Settings `json:"validation"`
+	Protection ProtectionSettings  `json:"protection"`
+	Branding   BrandingSettings    `json:"branding"`
+	Archive    ArchiveSettings     `json:"archive"`
+}
+
+// colorRegexp accepts hexadecimal CSS colors
+var colorRegexp = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// maxProofOfWork keeps the proof of work quick on slow phones, each bit
+// doubles the work
+const maxProofOfWork = 24
+
+type ContentMode string
+
+const (
+	// ContentFull sends the whole content of the feed items
+	ContentFull ContentMode = "full"
+	// ContentSummary sends the description of the feed items
+	ContentSummary ContentMode = "summary"
+	// ContentWords sends the first ContentSettings.Words words
+	ContentWords ContentMode = "words"
+	// ContentParagraphs sends the first ContentSettings.Paragraphs paragraphs
+	ContentParagraphs ContentMode = "paragraphs"
+	// ContentTitle sends only the title and the link of the feed items
+	ContentTitle ContentMode = "title"
+)
+
+var ContentModes = []ContentMode{ContentFull, ContentSummary, ContentWords, ContentParagraphs, ContentTitle}
+
+type ArchiveVisibility string
+
+const (
+	// ArchivePublic lists the sent issues at /p/{slug}/archive
+	ArchivePublic ArchiveVisibility = "public"
+	// ArchiveUnlisted only shows the issues to those who have their
+	// links, like the "view in browser" link of the emails, and asks
+	// search engines not to index them
+	ArchiveUnlisted ArchiveVisibility = "unlisted"
+	// ArchivePrivate doesn't show the issues on the web
+	ArchivePrivate ArchiveVisibility = "private"
+)
+
+var ArchiveVisibilities = []ArchiveVisibility{ArchivePublic, ArchiveUnlisted, ArchivePrivate}
+
+// ProcessingSettings toggles the steps applied to the rendered HTML of an
+// email before it's sent
+type ProcessingSettings struct {
+	// InlineCSS copies the rules of <style> blocks into style attributes
+	InlineCSS bool `json:"inline_css"`
+	// AbsoluteURLs rewrites relative URLs of feed items content using
+	// the item link as base
+	AbsoluteURLs bool `json:"absolute_urls"`
+	// LinkAttributes adds LinkTarget and LinkRel to every link
+	LinkAttributes bool   `json:"link_attributes"`
+	LinkTarget     string `json:"link_target"`
+	LinkRel        string `json:"link_rel"`
+	// MaxMessageSize is the size in bytes above which a warning is logged
+	// for the email, 0 disables the check. Gmail clips messages > 102KB.
+	MaxMessageSize int `json:"max_message_size"`
+}
+
+// SanitizerSettings is the policy used to clean the HTML of feed items
+// when they're scraped. Scripts, <style> blocks, forms and embedded objects
+// are always removed, as well as tracking pixels.
+type SanitizerSettings struct {
+	// AllowedTags and AllowedAttributes replace the default allowlists
+	// when they're not empty
+	AllowedTags       []string `json:"allowed_tags"`
+	AllowedAttributes []string `json:"allowed_attributes"`
+	// StripStyles removes style attributes
+	StripStyles bool `json:"strip_styles"`
+	// StripIframes removes iframes and their content
+	StripIframes bool `json:"strip_iframes"`
+	// RemoveImages removes every image, not only tracking pixels
+	RemoveImages bool `json:"remove_images"`
+}
+
+// ContentSettings controls how much of each feed item goes into an issue
+type ContentSettings struct {
+	Mode       ContentMode `json:"mode"`
+	Words      int         `json:"words"`
+	Paragraphs int         `json:"paragraphs"`
+	// ReadMoreText is the text of the link to the item appended to
+	// content that isn't complete
+	ReadMoreText string `json:"read_more_text"`
+	// MaxItems caps the number of items per issue, 0 means no limit.
+	// Items above the limit are replaced by a link to MoreLink, which
+	// defaults to the site serving the feed.
+	MaxItems int    `json:"max_items"`
+	MoreLink string `json:"more_link"`
+}
+
+// TrackingSettings enables open and click tracking for the project.
+// Subscribers can still opt out individually.
+type TrackingSettings struct {
+	Opens  bool `json:"opens"`
+	Clicks bool `json:"clicks"`
+}
+
+// UTMSettings adds UTM parameters to the links of feed items, so the
+// traffic coming from issues is attributed in analytics tools
+type UTMSettings struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+	Medium  string `json:"medium"`
+	// Campaign is a pattern in which {title}, {slug} and {issue} are
+	// replaced by the post title, its slug and the issue number
+	Campaign string `json:"campaign"`
+	// Content tags the links inside the items content too, not only
+	// the links to the items
+	Content bool `json:"content"`
+	// ExcludedDomains are never tagged, including their subdomains
+	ExcludedDomains []string `json:"excluded_domains"`
+}
+
+// DeliverySettings limits how fast the emails of the project are sent.
+// Zero values fall back to the global concurrency and disable the
+// project's rate limits, the provider limits are always applied.
+type DeliverySettings struct {
+	Concurrency   int `json:"concurrency"`
+	RatePerSecond int `json:"rate_per_second"`
+	RatePerHour   int `json:"rate_per_hour"`
+	RatePerDay    int `json:"rate_per_day"`
+}
+
+// WelcomeSettings customizes the email sent to new subscribers when the
+// project has an active welcome template
+type WelcomeSettings struct {
+	// RecentPosts is the number of past posts included in the email
+	RecentPosts int `json:"recent_posts"`
+}
+
+// AudienceSettings chooses who receives the issues and campaigns of the
+// project, campaigns can choose a segment of their own
+type AudienceSettings struct {
+	// SegmentID restricts the emails to a segment, zero sends them to
+	// every subscriber
+	SegmentID int64 `json:"segment_id"`
+}
+
+// ProtectionSettings guard the public subscribe endpoint of the project
+// against bots. Subscriptions are always limited per client address and
+// refused when the honeypot field is filled. The other checks need a form
+// token, which the subscribe form gets from the challenge endpoint.
+type ProtectionSettings struct {
+	// RatePerHour limits the subscriptions of the project, 0 uses the
+	// global SUBSCRIBE_RATE_PER_PROJECT
+	RatePerHour int `json:"rate_per_hour"`
+	// MinFillTime is the number of seconds a person takes at least to
+	// fill the form, counted from the issue of its token
+	MinFillTime int `json:"min_fill_time"`
+	// ProofOfWork is the number of leading zero bits of the hash the
+	// form must find before subscribing, 0 disables it
+	ProofOfWork int `json:"proof_of_work"`
+	// Captcha requires a response of the CAPTCHA_PROVIDER
+	Captcha bool `json:"captcha"`
+}
+
+// BrandingSettings customize the hosted pages of the project: the
+// subscribe form, the confirmation shown after subscribing, and the
+// unsubscribe and goodbye pages
+type BrandingSettings struct {
+	LogoURL string `json:"logo_url"`
+	// colors are hexadecimal CSS colors, like "#4caf50"
+	PrimaryColor    string `json:"primary_color"`
+	BackgroundColor string `json:"background_color"`
+	TextColor       string `json:"text_color"`
+	// RedirectURL replaces the confirmation page after subscribing, like
+	// a thank you page of the project's site
+	RedirectURL string   `json:"redirect_url"`
+	Subscribe   PageCopy `json:"subscribe"`
+	Confirm     PageCopy `json:"confirm"`
+	Unsubscribe PageCopy `json:"unsubscribe"`
+	Goodbye     PageCopy `json:"goodbye"`
+}
+
+// PageCopy is the text of a hosted page, Button is only used by the pages
+// with forms
+type PageCopy struct {
+	Title  string `json:"title"`
+	Text   string `json:"text"`
+	Button string `json:"button"`
+}
+
+// ArchiveSettings choose who reads the web archive of the issues
+type ArchiveSettings struct {
+	Visibility ArchiveVisibility `json:"visibility"`
+	// PerPage is the number of issues in each page of the archive
+	PerPage int `json:"per_page"`
+}
+
+// RequiresToken says if subscriptions need a form token
+func (ps *ProtectionSettings) RequiresToken() bool {
+	return ps.MinFillTime > 0 || ps.ProofOfWork > 0
+}
+
+// DefaultSettings returns the Settings used by projects that didn't
+// customize them
+func DefaultSettings() Settings {
+	return Settings{
+		Processing: ProcessingSettings{
+			InlineCSS:      true,
+			AbsoluteURLs:   true,
+			LinkAttributes: true,
+			LinkTarget:     "_blank",
+			LinkRel:        "noopener noreferrer",
+			MaxMessageSize: 102 * 1024,
+		},
+		Sanitizer: SanitizerSettings{
+			StripStyles:  true,
+			StripIframes: true,
+			RemoveImages: false,
+		},
+		Content: ContentSettings{
+			Mode:         ContentFull,
+			Words:        150,
+			Paragraphs:   3,
+			ReadMoreText: "Read more",
+			MaxItems:     0,
+		},
+		Tracking: TrackingSettings{
+			Opens:  false,
+			Clicks: false,
+		},
+		UTM: UTMSettings{
+			Enabled:  false,
+			Source:   "newsletter",
+			Medium:   "email",
+			Campaign: "issue-{issue}",
+			Content:  false,
+		},
+		Delivery: DeliverySettings{
+			Concurrency:   0,
+			RatePerSecond: 0,
+			RatePerHour:   0,
+			RatePerDay:    0,
+		},
+		Welcome: WelcomeSettings{
+			RecentPosts: 0,
+		},
+		Audience: AudienceSettings{
+			SegmentID: 0,
+		},
+		Validation: validation.DefaultSettings(),
+		Protection: ProtectionSettings{
+			RatePerHour: 0,
+			MinFillTime: 0,
+			ProofOfWork: 0,
+			Captcha:     false,
+		},
+		Branding: BrandingSettings{
+			PrimaryColor:    "#4caf50",
+			BackgroundColor: "#f1f1f1",
+			TextColor:       "#333333",
+			Subscribe: PageCopy{
+				Title:  "Subscribe",
+				Text:   "Get the new posts in your inbox.",
+				Button: "Subscribe",
+			},
+			Confirm: PageCopy{
+				Title: "Thanks for subscribing",
+				Text:  "The next posts will arrive in your inbox.",
+			},
+			Unsubscribe: PageCopy{
+				Title:  "Unsubscribe",
+				Text:   "We're sorry to see you go.",
+				Button: "Unsubscribe",
+			},
+			Goodbye: PageCopy{
+				Title: "Goodbye",
+				Text:  "You won't receive our emails anymore.",
+			},
+		},
+		Archive: ArchiveSettings{
+			Visibility: ArchivePrivate,
+			PerPage:    20,
+		},
+	}
+}
+
+// Validate checks if the Settings values are consistent
+func (s *Settings) Validate() error {
+	valid := false
+	for _, m := range ContentModes {
+		if s.Content.Mode == m {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid content mode '%s', use one of %v", s.Content.Mode, ContentModes)
+	}
+
+	if s.Content.Words < 1 || s.Content.Paragraphs < 1 {
+		return fmt.Errorf("content words and paragraphs must be greater than zero")
+	}
+
+	if s.Content.MaxItems < 0 || s.Processing.MaxMessageSize < 0 {
+		return fmt.Errorf("content max_items and processing max_message_size can't be negative")
+	}
+
+	d := s.Delivery
+	if d.Concurrency < 0 || d.RatePerSecond < 0 || d.RatePerHour < 0 || d.RatePerDay < 0 {
+		return fmt.Errorf("delivery concurrency and rates can't be negative")
+	}
+
+	if s.Welcome.RecentPosts < 0 {
+		return fmt.Errorf("welcome recent_posts can't be negative")
+	}
+
+	if s.Audience.SegmentID < 0 {
+		return fmt.Errorf("audience segment_id can't be negative")
+	}
+
+	pr := s.Protection
+	if pr.RatePerHour < 0 || pr.MinFillTime < 0 || pr.ProofOfWork < 0 {
+		return fmt.Errorf("protection rate_per_hour, min_fill_time and proof_of_work can't be negative")
+	}
+
+	if pr.ProofOfWork > maxProofOfWork {
+		return fmt.Errorf("protection proof_of_work can't be greater than %d", maxProofOfWork)
+	}
+
+	if pr.Captcha && config.C.CaptchaProvider == "" {
+		return fmt.Errorf("protection captcha requires a CAPTCHA_PROVIDER")
+	}
+
+	b := s.Branding
+	for _, color := range []string{b.PrimaryColor, b.BackgroundColor, b.TextColor} {
+		if !colorRegexp.MatchString(color) {
+			return fmt.Errorf("invalid branding color '%s', use a hexadecimal color like '#4caf50'", color)
+		}
+	}
+
+	for _, link := range []string{b.LogoURL, b.RedirectURL} {
+		if u, err := url.Parse(link); link != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https")) {
+			return fmt.Errorf("invalid branding url '%s', use an http or https url", link)
+		}
+	}
+
+	valid = false
+	for _, v := range ArchiveVisibilities {
+		if s.Archive.Visibility == v {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid archive visibility '%s', use one of %v", s.Archive.Visibility, ArchiveVisibilities)
+	}
+
+	if s.Archive.PerPage < 1 {
+		return fmt.Errorf("archive per_page must be greater than zero")
+	}
+
+	if err := s.Validation.Validate(); err != nil {
+		return err
+	}
+
+	if s.UTM.Enabled && (s.UTM.Source == "" || s.UTM.Medium == "" || s.UTM.Campaign == "") {
+		return fmt.Errorf("utm source, medium and campaign are required when utm is enabled")
+	}
+
+	return nil
+}
+
+// Scan loads the Settings from the JSON stored in the database
+func (s *Settings) Scan(src interface{}) error {
+	*s = DefaultSettings()
+
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan settings from %T", src)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("unable to parse settings: %v", err)
+	}
+
+	return nil
+}
+
+// Value converts the Settings to JSON before storing them in the database
+func (s Settings) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize settings: %v", err)
+	}
+
+	return data, nil
+}
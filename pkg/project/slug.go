@@ -0,0 +1,87 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxSlugLength limits the slugs, which are part of the project's URLs
+const maxSlugLength = 64
+
+var (
+	slugRegexp          = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparatorRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ErrSlugTaken is returned when another project has the slug
+var ErrSlugTaken = errors.New("slug is used by another project")
+
+// Slugify converts a name into a slug, like "My Blog!" into "my-blog"
+func Slugify(name string) string {
+	slug := slugSeparatorRegexp.ReplaceAllString(strings.ToLower(name), "-")
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+	}
+
+	slug = strings.Trim(slug, "-")
+	if slug == "" {
+		return "project"
+	}
+
+	return slug
+}
+
+// ValidateSlug checks the slug that identifies the project in its public
+// pages. Projects without one get a slug from their name, numbered when
+// another project has it already.
+func (p *Project) ValidateSlug() error {
+	if p.Slug == "" {
+		slug, err := availableSlug(Slugify(p.Name), p.ID)
+		if err != nil {
+			return err
+		}
+
+		p.Slug = slug
+		return nil
+	}
+
+	if len(p.Slug) > maxSlugLength || !slugRegexp.MatchString(p.Slug) {
+		return fmt.Errorf("invalid slug '%s', use lowercase letters, numbers and dashes", p.Slug)
+	}
+
+	other, err := getProjectBySlug(p.Slug)
+	if err != nil {
+		return err
+	}
+
+	if other != nil && other.ID != p.ID {
+		return fmt.Errorf("%w: '%s'", ErrSlugTaken, p.Slug)
+	}
+
+	return nil
+}
+
+// availableSlug returns the slug, or the first numbered version of it,
+// like "blog-2", that no other project has
+func availableSlug(slug string, projectID int64) (string, error) {
+	candidate := slug
+	for n := 2; ; n++ {
+		other, err := getProjectBySlug(candidate)
+		if err != nil {
+			return "", err
+		}
+
+		if other == nil || other.ID == projectID {
+			return candidate, nil
+		}
+
+		suffix := fmt.Sprintf("-%d", n)
+		if len(slug)+len(suffix) > maxSlugLength {
+			slug = strings.TrimRight(slug[:maxSlugLength-len(suffix)], "-")
+		}
+
+		candidate = slug + suffix
+	}
+}
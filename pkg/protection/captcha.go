@@ -0,0 +1,107 @@
+package protection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// Verifier checks the responses of a CAPTCHA
+type Verifier interface {
+	Verify(ctx context.Context, response, remoteIP string) (bool, error)
+}
+
+// siteVerifyURLs are the verification endpoints of the CAPTCHA providers,
+// which share the same API
+var siteVerifyURLs = map[string]string{
+	"hcaptcha":  "https://hcaptcha.com/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// NewVerifier returns the Verifier of the CAPTCHA_PROVIDER, nil when it
+// isn't set
+func NewVerifier() (Verifier, error) {
+	provider := config.C.CaptchaProvider
+
+	switch provider {
+	case "":
+		return nil, nil
+	case "fake":
+		return &FakeVerifier{}, nil
+	}
+
+	u, ok := siteVerifyURLs[provider]
+	if !ok {
+		return nil, fmt.Errorf("invalid captcha provider '%s', use hcaptcha, turnstile, recaptcha or fake", provider)
+	}
+
+	return &SiteVerifier{
+		URL:    u,
+		Secret: config.C.CaptchaSecret,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// SiteVerifier verifies responses with the siteverify API of hCaptcha,
+// Turnstile and reCAPTCHA
+type SiteVerifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Verify sends the response to the provider
+func (sv *SiteVerifier) Verify(ctx context.Context, response, remoteIP string) (bool, error) {
+	form := url.Values{
+		"secret":   {sv.Secret},
+		"response": {response},
+		"remoteip": {remoteIP},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sv.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := sv.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("unable to verify captcha: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unable to verify captcha: provider returned %s", res.Status)
+	}
+
+	result := struct {
+		Success bool `json:"success"`
+	}{}
+
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("unable to parse captcha verification: %v", err)
+	}
+
+	return result.Success, nil
+}
+
+// FakeResponse is the only response accepted by the FakeVerifier
+const FakeResponse = "pass"
+
+// FakeVerifier accepts the FakeResponse without calling any provider,
+// for tests and development
+type FakeVerifier struct{}
+
+// Verify says if the response is the FakeResponse
+func (fv *FakeVerifier) Verify(ctx context.Context, response, remoteIP string) (bool, error) {
+	return response == FakeResponse, nil
+}
@@ -0,0 +1,25 @@
+package protection
+
+import (
+	"crypto/sha256"
+	"math/bits"
+)
+
+// CheckProofOfWork says if the SHA-256 of the form token, a colon and the
+// nonce starts with at least difficulty zero bits. Forms find the nonce
+// by trying 0, 1, 2... which takes about 2^difficulty hashes.
+func CheckProofOfWork(token, nonce string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			zeros += bits.LeadingZeros8(b)
+			break
+		}
+
+		zeros += 8
+	}
+
+	return zeros >= difficulty
+}
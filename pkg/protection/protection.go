@@ -0,0 +1,404 @@
+package protection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+// maxBodySize limits the subscribe requests read by the protection
+const maxBodySize = 1 << 20
+
+// HoneypotField is a field of the subscribe form hidden from people, so
+// only bots fill it
+const HoneypotField = "website"
+
+// captchaFields are the fields with the CAPTCHA response, the widgets of
+// the providers add their own ones to HTML forms
+var captchaFields = []string{"captcha_response", "h-captcha-response", "cf-turnstile-response", "g-recaptcha-response"}
+
+// errTooMany is the error of the requests over a rate limit
+var errTooMany = fmt.Errorf("too many subscriptions, try again later")
+
+var (
+	ipLimiter      = newLimiter()
+	projectLimiter = newLimiter()
+
+	verifierOnce sync.Once
+	verifier     Verifier
+)
+
+// fields are the protection fields of a subscribe request
+type fields struct {
+	Honeypot        string
+	FormToken       string
+	Nonce           string
+	CaptchaResponse string
+}
+
+// Challenge is what a subscribe form needs to pass the protection of the
+// project
+type Challenge struct {
+	FormToken string `json:"form_token"`
+	// MinFillTime is in seconds and ProofOfWork in bits, see
+	// CheckProofOfWork
+	MinFillTime     int    `json:"min_fill_time"`
+	ProofOfWork     int    `json:"proof_of_work"`
+	CaptchaProvider string `json:"captcha_provider"`
+	CaptchaSiteKey  string `json:"captcha_site_key"`
+}
+
+// GetChallenge issues a form token to a subscribe form of the project,
+// with the checks it has to pass
+func GetChallenge(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	p, err := project.NewProjects().Get(int64(projectID))
+	if err != nil {
+		_log.Error("Failed getting Project.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if p == nil {
+		err := fmt.Errorf("project %d not found", projectID)
+		_log.Error("Failed getting Project.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return
+	}
+
+	c, err := NewChallenge(p)
+	if err != nil {
+		_log.Error("Failed issuing form token.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, c)
+}
+
+// NewChallenge returns the challenge of a subscribe form of the project
+// shown now. Forms get no token while FORM_SECRET isn't configured, so
+// the projects that require one refuse their subscriptions.
+func NewChallenge(p *project.Project) (*Challenge, error) {
+	ps := p.Settings.Protection
+	c := &Challenge{
+		MinFillTime: ps.MinFillTime,
+		ProofOfWork: ps.ProofOfWork,
+	}
+
+	token, err := NewFormToken(p.ID)
+	if err == nil {
+		c.FormToken = token.Encode()
+	} else if !errors.Is(err, errSecretUnset) {
+		return nil, err
+	}
+
+	if ps.Captcha {
+		c.CaptchaProvider = config.C.CaptchaProvider
+		c.CaptchaSiteKey = config.C.CaptchaSiteKey
+	}
+
+	return c, nil
+}
+
+// Blocked is a subscribe request refused by the protection
+type Blocked struct {
+	// Code is the HTTP status of the response and Reason the one logged
+	Code   int
+	Reason string
+	Err    error
+	// RetryAfter is set when a rate limit was exceeded
+	RetryAfter time.Duration
+	// Silent requests look successful to the client, like the ones of
+	// bots filling the honeypot, so they don't try harder
+	Silent bool
+}
+
+// SetHeaders sets the headers of the response to the blocked request,
+// like the Retry-After of the rate limits
+func (b *Blocked) SetHeaders(w http.ResponseWriter) {
+	if b.RetryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfter(b.RetryAfter))
+	}
+}
+
+// Check runs the protection of the project on a subscribe request of a
+// JSON or HTML form, returning why it's blocked or nil when it passes.
+// Blocked attempts are logged.
+func Check(r *http.Request, projectID int64) (*Blocked, error) {
+	ip := ClientIP(r)
+	_log := log.L.With(zap.Int64("project_id", projectID), zap.String("ip", ip))
+
+	b, err := check(r, projectID, ip)
+	if b != nil {
+		_log.Warn("Subscription attempt blocked.", zap.String("reason", b.Reason))
+	}
+
+	return b, err
+}
+
+// check runs the protection of the project on the request
+func check(r *http.Request, projectID int64, ip string) (*Blocked, error) {
+	if ok, wait := ipLimiter.Allow(ip, int(config.C.SubscribeRatePerIP)); !ok {
+		return &Blocked{http.StatusTooManyRequests, "ip rate limit", errTooMany, wait, false}, nil
+	}
+
+	p, err := project.NewProjects().Get(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return &Blocked{http.StatusNotFound, "project not found", fmt.Errorf("project %d not found", projectID), 0, false}, nil
+	}
+
+	return checkProject(r, p, ip)
+}
+
+// checkProject runs the checks of the project on the request. Its rate
+// limit is taken last, so the requests of bots refused by the other
+// checks don't use the subscriptions of real readers.
+func checkProject(r *http.Request, p *project.Project, ip string) (*Blocked, error) {
+	ps := p.Settings.Protection
+
+	f, err := readFields(r)
+	if err != nil {
+		return &Blocked{http.StatusBadRequest, "unreadable request", err, 0, false}, nil
+	}
+
+	if f.Honeypot != "" {
+		return &Blocked{http.StatusOK, "honeypot", nil, 0, true}, nil
+	}
+
+	if ps.RequiresToken() {
+		if reason, err := checkFormToken(p.ID, &ps, f); err != nil {
+			return &Blocked{http.StatusBadRequest, reason, err, 0, false}, nil
+		}
+	}
+
+	if ps.Captcha {
+		if reason, err := checkCaptcha(r, ip, f); err != nil {
+			return &Blocked{http.StatusBadRequest, reason, err, 0, false}, nil
+		}
+	}
+
+	rate := ps.RatePerHour
+	if rate == 0 {
+		rate = int(config.C.SubscribeRatePerProject)
+	}
+
+	if ok, wait := projectLimiter.Allow(strconv.FormatInt(p.ID, 10), rate); !ok {
+		return &Blocked{http.StatusTooManyRequests, "project rate limit", errTooMany, wait, false}, nil
+	}
+
+	return nil, nil
+}
+
+// Subscribe protects the subscribe API of a project, refusing the
+// requests that exceed the rate limits or fail the checks of the project
+func Subscribe(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		projectID, err := strconv.Atoi(params["project_id"])
+		if err != nil {
+			// the handler answers the invalid requests
+			next(w, r)
+			return
+		}
+
+		b, err := Check(r, int64(projectID))
+		if err != nil {
+			log.L.Error("Failed checking subscription attempt.", zap.Error(err), zap.Int64("project_id", int64(projectID)))
+			utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if b == nil {
+			next(w, r)
+			return
+		}
+
+		if b.Silent {
+			utils.WriteJSONResponseMessage(w, http.StatusOK, "subscription received")
+			return
+		}
+
+		b.SetHeaders(w)
+		utils.WriteJSONResponseError(w, b.Code, b.Err)
+	}
+}
+
+// checkFormToken checks the form token of the request, the fill time and
+// the proof of work, returning the reason of the failure
+func checkFormToken(projectID int64, ps *project.ProtectionSettings, f *fields) (string, error) {
+	if !secretIsSet() {
+		return "form token unavailable", errSecretUnset
+	}
+
+	if f.FormToken == "" {
+		return "form token missing", fmt.Errorf("form_token is missing, get one from the challenge endpoint")
+	}
+
+	token, err := DecodeFormToken(f.FormToken)
+	if err != nil {
+		return "form token invalid", err
+	}
+
+	age := time.Since(token.IssuedAt)
+
+	if token.ProjectID != projectID || age > maxTokenAge {
+		return "form token invalid", fmt.Errorf("form token expired, reload the form")
+	}
+
+	if age < time.Duration(ps.MinFillTime)*time.Second {
+		return "form filled too fast", fmt.Errorf("form filled too fast, try again")
+	}
+
+	if ps.ProofOfWork > 0 && !CheckProofOfWork(f.FormToken, f.Nonce, ps.ProofOfWork) {
+		return "proof of work invalid", fmt.Errorf("invalid proof of work")
+	}
+
+	if use(f.FormToken) {
+		return "form token reused", fmt.Errorf("form token already used, reload the form")
+	}
+
+	return "", nil
+}
+
+// checkCaptcha verifies the CAPTCHA response of the request, returning the
+// reason of the failure
+func checkCaptcha(r *http.Request, ip string, f *fields) (string, error) {
+	verifierOnce.Do(func() {
+		var err error
+		if verifier, err = NewVerifier(); err != nil {
+			log.L.Error("Failed creating captcha verifier.", zap.Error(err))
+		}
+	})
+
+	if verifier == nil {
+		return "captcha unavailable", fmt.Errorf("captcha isn't available, try again later")
+	}
+
+	if f.CaptchaResponse == "" {
+		return "captcha missing", fmt.Errorf("captcha response is missing")
+	}
+
+	ok, err := verifier.Verify(r.Context(), f.CaptchaResponse, ip)
+	if err != nil {
+		log.L.Error("Failed verifying captcha.", zap.Error(err))
+		return "captcha unavailable", fmt.Errorf("captcha couldn't be verified, try again later")
+	}
+
+	if !ok {
+		return "captcha failed", fmt.Errorf("captcha failed, try again")
+	}
+
+	return "", nil
+}
+
+// readFields reads the protection fields of JSON and form requests,
+// leaving the body to be read again by the handler
+func readFields(r *http.Request) (*fields, error) {
+	f := &fields{}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+
+		f.Honeypot = r.PostForm.Get(HoneypotField)
+		f.FormToken = r.PostForm.Get("form_token")
+		f.Nonce = r.PostForm.Get("pow_nonce")
+		for _, name := range captchaFields {
+			if v := r.PostForm.Get(name); v != "" {
+				f.CaptchaResponse = v
+			}
+		}
+
+		return f, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(body, &values); err != nil {
+		// the handler answers the malformed requests
+		return f, nil
+	}
+
+	text := func(name string) string {
+		switch v := values[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+
+		return ""
+	}
+
+	f.Honeypot = text(HoneypotField)
+	f.FormToken = text("form_token")
+	f.Nonce = text("pow_nonce")
+	for _, name := range captchaFields {
+		if v := text(name); v != "" {
+			f.CaptchaResponse = v
+		}
+	}
+
+	return f, nil
+}
+
+// ClientIP returns the address of the client, the first one of
+// X-Forwarded-For when TRUST_PROXY_HEADERS is set
+func ClientIP(r *http.Request) string {
+	if config.C.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// retryAfter returns the seconds of the Retry-After header, rounded up
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int((wait + time.Second - 1) / time.Second))
+}
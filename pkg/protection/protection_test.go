@@ -0,0 +1,352 @@
+package protection
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+func TestMain(m *testing.M) {
+	config.Initialize()
+	config.C.FormSecret = "test-secret"
+
+	os.Exit(m.Run())
+}
+
+// withSecret runs the test with FORM_SECRET set to the secret
+func withSecret(t *testing.T, secret string) {
+	t.Helper()
+
+	previous := config.C.FormSecret
+	config.C.FormSecret = secret
+	t.Cleanup(func() { config.C.FormSecret = previous })
+}
+
+// issuedToken returns the encoded token of the project issued age ago
+func issuedToken(t *testing.T, projectID int64, age time.Duration) string {
+	t.Helper()
+
+	token, err := NewFormToken(projectID)
+	if err != nil {
+		t.Fatalf("failed issuing form token: %v", err)
+	}
+
+	token.IssuedAt = time.Now().Add(-age)
+
+	return token.Encode()
+}
+
+// solveProofOfWork returns the first nonce of the token with the difficulty
+func solveProofOfWork(token string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		if CheckProofOfWork(token, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
+
+func TestFormToken(t *testing.T) {
+	encoded := issuedToken(t, 42, time.Minute)
+
+	token, err := DecodeFormToken(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token.ProjectID != 42 {
+		t.Fatalf("project = %d, want 42", token.ProjectID)
+	}
+
+	if age := time.Since(token.IssuedAt); age < time.Minute || age > 2*time.Minute {
+		t.Fatalf("unexpected token age %s", age)
+	}
+
+	payload, signature, _ := strings.Cut(encoded, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"without signature", payload},
+		{"changed project", strings.Replace(payload, "16-", "17-", 1) + "." + signature},
+		{"changed signature", payload + "." + strings.Repeat("A", len(signature))},
+		{"extra part", encoded + ".x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeFormToken(tt.token); err == nil {
+				t.Fatalf("expected '%s' to be refused", tt.token)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		withSecret(t, "other-secret")
+
+		if _, err := DecodeFormToken(encoded); err == nil {
+			t.Fatal("expected the token of another secret to be refused")
+		}
+	})
+}
+
+func TestFormTokenUnsetSecret(t *testing.T) {
+	for _, secret := range []string{"", unsetSecret} {
+		t.Run(secret, func(t *testing.T) {
+			withSecret(t, secret)
+
+			if _, err := NewFormToken(1); err != errSecretUnset {
+				t.Fatalf("NewFormToken error = %v, want %v", err, errSecretUnset)
+			}
+
+			c, err := NewChallenge(&project.Project{ID: 1})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.FormToken != "" {
+				t.Fatalf("expected no form token, got %s", c.FormToken)
+			}
+		})
+	}
+}
+
+func TestCheckFormToken(t *testing.T) {
+	ps := &project.ProtectionSettings{MinFillTime: 3, ProofOfWork: 8}
+
+	valid := issuedToken(t, 1, time.Minute)
+	reused := issuedToken(t, 1, time.Minute)
+	tooFast := issuedToken(t, 1, time.Second)
+	expired := issuedToken(t, 1, 2*maxTokenAge)
+	otherProject := issuedToken(t, 2, time.Minute)
+
+	// the nonce is only valid for its token
+	reusedNonce := solveProofOfWork(reused, ps.ProofOfWork)
+	if _, err := checkFormToken(1, ps, &fields{FormToken: reused, Nonce: reusedNonce}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	badNonce := "0"
+	for CheckProofOfWork(valid, badNonce, ps.ProofOfWork) {
+		badNonce += "0"
+	}
+
+	tests := []struct {
+		name   string
+		fields *fields
+		reason string
+	}{
+		{"missing", &fields{}, "form token missing"},
+		{"malformed", &fields{FormToken: "token"}, "form token invalid"},
+		{"other project", &fields{FormToken: otherProject}, "form token invalid"},
+		{"expired", &fields{FormToken: expired}, "form token invalid"},
+		{"too fast", &fields{FormToken: tooFast}, "form filled too fast"},
+		{"invalid proof of work", &fields{FormToken: valid, Nonce: badNonce}, "proof of work invalid"},
+		{"reused", &fields{FormToken: reused, Nonce: reusedNonce}, "form token reused"},
+		{"valid", &fields{FormToken: valid, Nonce: solveProofOfWork(valid, ps.ProofOfWork)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := checkFormToken(1, ps, tt.fields)
+			if reason != tt.reason {
+				t.Fatalf("reason = '%s', want '%s'", reason, tt.reason)
+			}
+
+			if (err != nil) != (tt.reason != "") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	t.Run("unset secret", func(t *testing.T) {
+		withSecret(t, unsetSecret)
+
+		if reason, _ := checkFormToken(1, ps, &fields{FormToken: valid}); reason != "form token unavailable" {
+			t.Fatalf("reason = '%s', want 'form token unavailable'", reason)
+		}
+	})
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	token := "token"
+	nonce := solveProofOfWork(token, 12)
+
+	tests := []struct {
+		name       string
+		nonce      string
+		difficulty int
+		want       bool
+	}{
+		{"disabled", "", 0, true},
+		{"solved", nonce, 12, true},
+		{"easier", nonce, 4, true},
+		{"impossible", nonce, 257, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckProofOfWork(token, tt.nonce, tt.difficulty); got != tt.want {
+				t.Fatalf("CheckProofOfWork = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if CheckProofOfWork("other", nonce, 12) && CheckProofOfWork("another", nonce, 12) {
+		t.Fatal("expected the nonce to depend on the token")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", 3); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("a", 3)
+	if ok {
+		t.Fatal("expected the fourth request to be refused")
+	}
+
+	if wait <= 0 || wait > 20*time.Minute {
+		t.Fatalf("unexpected wait %s", wait)
+	}
+
+	if ok, _ := l.Allow("b", 3); !ok {
+		t.Fatal("expected other keys to have their own bucket")
+	}
+
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("a", 0); !ok {
+			t.Fatal("expected no limit when perHour is 0")
+		}
+	}
+}
+
+// formRequest returns a subscribe request of an HTML form with the values
+func formRequest(values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/projects/1/subscriptions", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return r
+}
+
+func TestCheckProject(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   project.ProtectionSettings
+		request    func(p *project.Project) *http.Request
+		wantReason string
+		wantSilent bool
+	}{
+		{
+			name:     "no checks",
+			settings: project.ProtectionSettings{RatePerHour: 5},
+			request: func(p *project.Project) *http.Request {
+				return formRequest(url.Values{"email": {"jane@example.com"}})
+			},
+		},
+		{
+			name:     "honeypot",
+			settings: project.ProtectionSettings{RatePerHour: 5},
+			request: func(p *project.Project) *http.Request {
+				return formRequest(url.Values{"email": {"jane@example.com"}, HoneypotField: {"https://spam.example.com"}})
+			},
+			wantReason: "honeypot",
+			wantSilent: true,
+		},
+		{
+			name:     "honeypot in JSON",
+			settings: project.ProtectionSettings{RatePerHour: 5},
+			request: func(p *project.Project) *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "jane@example.com", "website": "x"}`))
+				r.Header.Set("Content-Type", "application/json")
+				return r
+			},
+			wantReason: "honeypot",
+			wantSilent: true,
+		},
+		{
+			name:     "token required",
+			settings: project.ProtectionSettings{RatePerHour: 5, MinFillTime: 1},
+			request: func(p *project.Project) *http.Request {
+				return formRequest(url.Values{"email": {"jane@example.com"}})
+			},
+			wantReason: "form token missing",
+		},
+		{
+			name:     "valid token",
+			settings: project.ProtectionSettings{RatePerHour: 5, MinFillTime: 1},
+			request: func(p *project.Project) *http.Request {
+				return formRequest(url.Values{"email": {"jane@example.com"}, "form_token": {issuedToken(t, p.ID, time.Minute)}})
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &project.Project{ID: int64(1000 + i)}
+			p.Settings.Protection = tt.settings
+
+			b, err := checkProject(tt.request(p), p, "192.0.2.1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantReason == "" {
+				if b != nil {
+					t.Fatalf("unexpected block: %s", b.Reason)
+				}
+
+				return
+			}
+
+			if b == nil || b.Reason != tt.wantReason || b.Silent != tt.wantSilent {
+				t.Fatalf("block = %+v, want reason '%s' and silent %v", b, tt.wantReason, tt.wantSilent)
+			}
+		})
+	}
+}
+
+func TestCheckProjectRateLimit(t *testing.T) {
+	p := &project.Project{ID: 2000}
+	p.Settings.Protection = project.ProtectionSettings{RatePerHour: 2, MinFillTime: 1}
+
+	// bots without a valid token don't use the limit of the project
+	for i := 0; i < 5; i++ {
+		b, err := checkProject(formRequest(url.Values{"form_token": {"forged"}}), p, "192.0.2.1")
+		if err != nil || b == nil || b.Reason != "form token invalid" {
+			t.Fatalf("expected the forged token to be refused, got %+v, %v", b, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		values := url.Values{"form_token": {issuedToken(t, p.ID, time.Minute)}}
+		if b, err := checkProject(formRequest(values), p, "192.0.2.1"); err != nil || b != nil {
+			t.Fatalf("request %d refused: %+v, %v", i+1, b, err)
+		}
+	}
+
+	values := url.Values{"form_token": {issuedToken(t, p.ID, time.Minute)}}
+	b, err := checkProject(formRequest(values), p, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b == nil || b.Reason != "project rate limit" || b.RetryAfter <= 0 {
+		t.Fatalf("expected the project rate limit, got %+v", b)
+	}
+}
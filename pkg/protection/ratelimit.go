@@ -0,0 +1,84 @@
+package protection
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that are full again are removed
+const sweepInterval = 10 * time.Minute
+
+// bucket is a token bucket that refills at a constant rate up to its
+// capacity
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter limits the events of many keys, like client addresses, with a
+// token bucket per key. Buckets live in memory, so the limits are
+// enforced per process.
+type limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// newLimiter returns an empty limiter
+func newLimiter() *limiter {
+	return &limiter{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+// Allow takes a token from the bucket of the key, whose capacity is
+// perHour tokens refilled along the hour. It says if there was a token,
+// and how long until the next one otherwise. Keys are never limited when
+// perHour is 0.
+func (l *limiter) Allow(key string, perHour int) (bool, time.Duration) {
+	if perHour <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(perHour)
+	rate := capacity / time.Hour.Seconds()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now, rate, capacity)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// sweep removes the buckets that refilled since their last event, which
+// are the same as new ones
+func (l *limiter) sweep(now time.Time, rate, capacity float64) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= capacity {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
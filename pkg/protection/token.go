@@ -0,0 +1,135 @@
+package protection
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+const (
+	// maxTokenAge is how long a form token can be used after it's issued
+	maxTokenAge = time.Hour
+
+	// unsetSecret is the default of FORM_SECRET, which is public, so the
+	// tokens signed with it could be forged
+	unsetSecret = "CHANGEME"
+)
+
+// errSecretUnset is returned while FORM_SECRET isn't configured, which
+// disables the form tokens
+var errSecretUnset = fmt.Errorf("form tokens are disabled until FORM_SECRET is configured")
+
+// secretIsSet says if FORM_SECRET was changed from its default
+func secretIsSet() bool {
+	return config.C.FormSecret != "" && config.C.FormSecret != unsetSecret
+}
+
+// FormToken is issued to a subscribe form when it's shown, so the
+// submission proves when the form was loaded. Tokens are used once.
+type FormToken struct {
+	ProjectID int64
+	IssuedAt  time.Time
+	nonce     string
+}
+
+// NewFormToken returns a token of the project issued now
+func NewFormToken(projectID int64) (*FormToken, error) {
+	if !secretIsSet() {
+		return nil, errSecretUnset
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate form token: %v", err)
+	}
+
+	return &FormToken{projectID, time.Now(), hex.EncodeToString(nonce)}, nil
+}
+
+// Encode returns the signed representation of the token
+func (t *FormToken) Encode() string {
+	payload := strings.Join([]string{
+		strconv.FormatInt(t.ProjectID, 36),
+		strconv.FormatInt(t.IssuedAt.UnixMilli(), 36),
+		t.nonce,
+	}, "-")
+
+	return payload + "." + sign(payload)
+}
+
+// DecodeFormToken verifies the token signature and returns its content
+func DecodeFormToken(token string) (*FormToken, error) {
+	if !secretIsSet() {
+		return nil, errSecretUnset
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed form token")
+	}
+
+	if !hmac.Equal([]byte(sign(parts[0])), []byte(parts[1])) {
+		return nil, fmt.Errorf("invalid form token signature")
+	}
+
+	fields := strings.Split(parts[0], "-")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("malformed form token")
+	}
+
+	projectID, err := strconv.ParseInt(fields[0], 36, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed form token: %v", err)
+	}
+
+	issuedAt, err := strconv.ParseInt(fields[1], 36, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed form token: %v", err)
+	}
+
+	return &FormToken{projectID, time.UnixMilli(issuedAt), fields[2]}, nil
+}
+
+// sign returns a truncated HMAC of the payload
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.C.FormSecret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// usedTokens are the form tokens already used, until they expire
+var usedTokens = struct {
+	sync.Mutex
+	tokens map[string]time.Time
+}{tokens: map[string]time.Time{}}
+
+// use marks the token as used, saying if it was used before
+func use(token string) bool {
+	usedTokens.Lock()
+	defer usedTokens.Unlock()
+
+	now := time.Now()
+	for t, expiry := range usedTokens.tokens {
+		if now.After(expiry) {
+			delete(usedTokens.tokens, t)
+		}
+	}
+
+	if _, ok := usedTokens.tokens[token]; ok {
+		return true
+	}
+
+	usedTokens.tokens[token] = now.Add(maxTokenAge)
+
+	return false
+}
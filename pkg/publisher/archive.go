@@ -0,0 +1,124 @@
+package publisher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/archive"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+// archiveIssue stores the web version of the issue of the publication,
+// rendered with its template for a reader that isn't a subscriber, and
+// returns the link of the issue in the archive, which is empty when the
+// project's archive is private
+func (w *Watcher) archiveIssue(pb *publication, issue int64) (string, error) {
+	pr, p := pb.project, pb.post
+
+	postItems := pb.postItems
+
+	moreItems := 0
+	if maxItems := pr.Settings.Content.MaxItems; maxItems > 0 && len(postItems) > maxItems {
+		moreItems = len(postItems) - maxItems
+		postItems = postItems[:maxItems]
+	}
+
+	browserLink := archive.IssueURL(pr.Slug, issue)
+
+	// the links of the subscriber point to the pages without a token,
+	// so they can be found and removed after rendering
+	tplData := &template.Data{
+		Title:           p.Title,
+		UnsubscribeLink: applicationLink("unsubscribe"),
+		PreferencesLink: applicationLink("preferences"),
+		BrowserLink:     browserLink,
+		InBrowser:       true,
+		Items:           pb.dataItems(postItems),
+		MoreItems:       moreItems,
+		MoreLink:        buildMoreLink(pr),
+		SenderName:      pr.Sender().Name,
+		Subscriber:      &template.DataSubscriber{Attributes: map[string]interface{}{}},
+	}
+
+	subject, err := pb.emailTemplate.RenderSubject(tplData)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := pb.emailTemplate.RenderContent(pr.EmailLayout, tplData)
+	if err != nil {
+		return "", err
+	}
+
+	if content, err = NewPostProcessor(pr.Settings.Processing).Process(content); err != nil {
+		return "", err
+	}
+
+	content, err = removeLinks(content, tplData.UnsubscribeLink, tplData.PreferencesLink, browserLink)
+	if err != nil {
+		return "", err
+	}
+
+	i := archive.New()
+	i.PostID = p.ID
+	i.ProjectID = pr.ID
+	i.Number = issue
+	i.Subject = subject
+	i.Content = content
+
+	if err := i.Save(); err != nil {
+		return "", err
+	}
+
+	if pr.Settings.Archive.Visibility == project.ArchivePrivate {
+		return "", nil
+	}
+
+	return browserLink, nil
+}
+
+// applicationLink returns the link to a page of the application
+func applicationLink(path string) string {
+	link := url.URL{
+		Scheme: "https",
+		Host:   config.C.ApplicationDomain,
+		Path:   path,
+	}
+
+	return link.String()
+}
+
+// removeLinks removes the links to any of the addresses from the HTML,
+// with the paragraph or list item around them, so sentences like "Don't
+// want these emails? Unsubscribe" go away with their link
+func removeLinks(content string, links ...string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing issue content: %v", err)
+	}
+
+	removed := map[string]bool{}
+	for _, link := range links {
+		removed[link] = true
+	}
+
+	doc.Find("a").Each(func(_ int, s *goquery.Selection) {
+		if href, _ := s.Attr("href"); !removed[href] {
+			return
+		}
+
+		if block := s.Closest("p, li"); block.Length() > 0 {
+			block.Remove()
+			return
+		}
+
+		s.Remove()
+	})
+
+	return goquery.OuterHtml(doc.Selection)
+}
@@ -0,0 +1,217 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/delivery"
+	"github.com/statictask/newsletter/pkg/post"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+// watchDigests executes an infinite loop that keeps checking if there
+// are digests to be sent
+func (w *Watcher) watchDigests() {
+	_log := log.L.With(zap.String("watcher", "publisher"), zap.String("stage", "digest"))
+	for {
+		time.Sleep(time.Minute)
+
+		if err := w.processDigests(); err != nil {
+			_log.Error("Failed processing digests.", zap.Error(err))
+		}
+	}
+}
+
+// processDigests sends the digests that are due. Posts published while
+// a subscriber waits for their digest are deferred in the ledger, and
+// the digest gathers all of them in a single email rendered with the
+// project's active template.
+func (w *Watcher) processDigests() error {
+	projects, err := project.NewProjects().AllEnabled()
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range projects {
+		_log := log.L.With(zap.Int64("project_id", pr.ID))
+
+		subscriptions, err := pr.Subscriptions().AwaitingDigest()
+		if err != nil {
+			_log.Error("Failed loading Subscriptions awaiting digest. Skipping.", zap.Error(err))
+			continue
+		}
+
+		if len(subscriptions) == 0 {
+			continue
+		}
+
+		emailTemplate, err := pr.EmailTemplates().GetActive()
+		if err != nil {
+			_log.Error("Failed loading Project's EmailTemplate. Skipping.", zap.Error(err))
+			continue
+		}
+
+		limiter := w.projectLimiter(pr)
+
+		for _, s := range subscriptions {
+			// the deferred posts of dry runs stay deferred, so each
+			// digest is sent once
+			if w.dryRunDelivered(fmt.Sprintf("digest:%d", s.ID)) {
+				continue
+			}
+
+			w.sendDigest(pr, s, emailTemplate, limiter, _log.With(zap.Int64("subscription_id", s.ID)))
+		}
+	}
+
+	return nil
+}
+
+// sendDigest sends the deferred posts of the subscription in a single
+// email, and gives each of them the status of the digest once it's sent
+// or skipped
+func (w *Watcher) sendDigest(pr *project.Project, s *subscription.Subscription, et *template.EmailTemplate, limiter *RateLimiter, _log *zap.Logger) {
+	deferred, err := delivery.NewSubscriptionDeliveries(pr.ID, s.ID).Deferred()
+	if err != nil {
+		_log.Error("Failed loading deferred Deliveries.", zap.Error(err))
+		return
+	}
+
+	var posts []*post.Post
+	for _, d := range deferred {
+		p, err := pr.Posts().Get(d.PostID)
+		if err != nil {
+			_log.Error("Failed loading deferred Post.", zap.Error(err), zap.Int64("post_id", d.PostID))
+			return
+		}
+
+		if p != nil {
+			posts = append(posts, p)
+		}
+	}
+
+	digest := delivery.New()
+	digest.SubscriptionID = s.ID
+	digest.Status = delivery.Failed
+
+	email, err := w.buildDigestEmail(pr, s, posts, et)
+	if errors.Is(err, errNothingToRead) {
+		digest.Status = delivery.Skipped
+	} else if err != nil {
+		digest.Error = err.Error()
+	} else {
+		w.sendWithRetries(digest, email, limiter, _log)
+	}
+
+	// posts of a digest that wasn't sent stay deferred, so the next
+	// attempt gathers them again
+	for _, d := range deferred {
+		if digest.Status == delivery.Sent || digest.Status == delivery.Skipped {
+			d.Status = digest.Status
+		}
+
+		d.Attempts = digest.Attempts
+		d.Error = digest.Error
+
+		if err := w.saveDelivery(d); err != nil {
+			_log.Error("Failed saving Delivery.", zap.Error(err), zap.Int64("post_id", d.PostID))
+		}
+	}
+
+	switch digest.Status {
+	case delivery.Sent:
+		// dry runs don't delay the next digest
+		if !w.dryRun {
+			if err := s.MarkDigestSent(); err != nil {
+				_log.Error("Failed marking digest as sent.", zap.Error(err))
+			}
+		}
+
+		_log.Info("Digest sent.", zap.Int("posts", len(posts)))
+	case delivery.Skipped:
+		_log.Info("Digest skipped, no items match the subscriber's topics.")
+	default:
+		_log.Error(
+			"Failed sending digest.",
+			zap.String("error", digest.Error),
+			zap.String("delivery_status", string(digest.Status)),
+			zap.Int("attempts", digest.Attempts),
+		)
+	}
+}
+
+// buildDigestEmail renders the digest of the posts for a single
+// subscription. Items above the project's limit are counted from the
+// oldest post on, and opens and clicks are tracked in the latest post.
+func (w *Watcher) buildDigestEmail(pr *project.Project, s *subscription.Subscription, posts []*post.Post, et *template.EmailTemplate) (*Email, error) {
+	if len(posts) == 0 {
+		return nil, errNothingToRead
+	}
+
+	title := buildDigestTitle(pr, s)
+	tagger := NewUTMTagger(pr.Settings.UTM, title, 0)
+	tracker := NewTracker(pr.Settings.Tracking, posts[len(posts)-1].ID)
+	maxItems := pr.Settings.Content.MaxItems
+
+	tplData := &template.Data{
+		Title:    title,
+		Items:    []*template.DataItem{},
+		MoreLink: buildMoreLink(pr),
+	}
+
+	for _, p := range posts {
+		allPostItems, err := p.PostItems().All()
+		if err != nil {
+			return nil, err
+		}
+
+		postItems, err := readableItems(s, allPostItems)
+		if errors.Is(err, errNothingToRead) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if maxItems > 0 && len(tplData.Items)+len(postItems) > maxItems {
+			keep := maxItems - len(tplData.Items)
+			tplData.MoreItems += len(postItems) - keep
+			postItems = postItems[:keep]
+		}
+
+		if len(postItems) == 0 {
+			continue
+		}
+
+		items, err := w.buildItems(pr, postItems, tagger)
+		if err != nil {
+			return nil, err
+		}
+
+		tplData.Posts = append(tplData.Posts, &template.DataPost{Title: p.Title, Date: p.CreatedAt, Items: items})
+		tplData.Items = append(tplData.Items, items...)
+	}
+
+	if len(tplData.Items) == 0 {
+		return nil, errNothingToRead
+	}
+
+	return w.renderEmail(pr, s, et, tplData, tracker)
+}
+
+// buildDigestTitle returns the title of the subscriber's digest, like
+// "Project: weekly digest"
+func buildDigestTitle(pr *project.Project, s *subscription.Subscription) string {
+	if s.ReadsDigests() {
+		return fmt.Sprintf("%s: %s digest", pr.Name, s.Frequency)
+	}
+
+	return fmt.Sprintf("%s: digest", pr.Name)
+}
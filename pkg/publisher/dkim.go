@@ -0,0 +1,358 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dkimSignedHeaders are signed when present in the message
+var dkimSignedHeaders = []string{
+	"From", "Reply-To", "To", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type",
+}
+
+// signatureRegexp matches the b= tag of DKIM-Signature headers
+var signatureRegexp = regexp.MustCompile(`(^|[;:]\s*)b=[^;]*`)
+
+// DKIMSigner signs messages sent from a domain using one of its selectors
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	key      *rsa.PrivateKey
+}
+
+// NewDKIMSigner returns a DKIMSigner for the domain using the RSA private
+// key in PEM format, either PKCS #1 or PKCS #8
+func NewDKIMSigner(domain, selector string, keyPEM []byte) (*DKIMSigner, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("invalid DKIM key for %s: no PEM data", domain)
+	}
+
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else {
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DKIM key for %s: %v", domain, err)
+		}
+
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid DKIM key for %s: only RSA keys are supported", domain)
+		}
+
+		key = rsaKey
+	}
+
+	return &DKIMSigner{Domain: strings.ToLower(domain), Selector: selector, key: key}, nil
+}
+
+// DNSName returns the name of the TXT record holding the public key
+func (s *DKIMSigner) DNSName() string {
+	return fmt.Sprintf("%s._domainkey.%s", s.Selector, s.Domain)
+}
+
+// DNSRecord returns the value of the TXT record holding the public key
+func (s *DKIMSigner) DNSRecord() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed encoding DKIM public key: %v", err)
+	}
+
+	return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+}
+
+// Sign returns the message with a DKIM-Signature header, using the
+// relaxed canonicalization for both headers and body
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	headers, body, err := splitMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+
+	var names []string
+	var signed []string
+	for _, name := range dkimSignedHeaders {
+		if h, ok := lastHeader(headers, name, nil); ok {
+			names = append(names, strings.ToLower(name))
+			signed = append(signed, h)
+		}
+	}
+
+	tags := fmt.Sprintf(
+		"v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Domain,
+		s.Selector,
+		time.Now().Unix(),
+		strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+
+	h := sha256.New()
+	for _, header := range signed {
+		h.Write([]byte(canonicalHeader(header) + "\r\n"))
+	}
+
+	h.Write([]byte(canonicalHeader("DKIM-Signature: " + tags)))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed signing message: %v", err)
+	}
+
+	header := "DKIM-Signature: " + tags + foldBase64(base64.StdEncoding.EncodeToString(signature))
+
+	return append([]byte(header+"\r\n"), message...), nil
+}
+
+// DKIMKeyring holds the DKIM signers of every domain
+type DKIMKeyring struct {
+	signers map[string]*DKIMSigner
+}
+
+// LoadDKIMKeyring parses a comma separated list of domain:selector:key
+// entries, in which key is a PEM encoded private key or the path of a
+// file containing it
+func LoadDKIMKeyring(spec string) (*DKIMKeyring, error) {
+	kr := &DKIMKeyring{signers: map[string]*DKIMSigner{}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid DKIM key entry, use domain:selector:key")
+		}
+
+		domain, selector, key := parts[0], parts[1], strings.TrimSpace(parts[2])
+
+		keyPEM := []byte(key)
+		if !strings.HasPrefix(key, "-----BEGIN") {
+			var err error
+			if keyPEM, err = os.ReadFile(key); err != nil {
+				return nil, fmt.Errorf("unable to read DKIM key of %s: %v", domain, err)
+			}
+		}
+
+		signer, err := NewDKIMSigner(domain, selector, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		kr.signers[signer.Domain] = signer
+	}
+
+	return kr, nil
+}
+
+// Sign signs the message with the key of the sender's domain, messages
+// of domains without keys are returned untouched
+func (kr *DKIMKeyring) Sign(from string, message []byte) ([]byte, error) {
+	if kr == nil {
+		return message, nil
+	}
+
+	signer, ok := kr.signers[domainOf(from)]
+	if !ok {
+		return message, nil
+	}
+
+	return signer.Sign(message)
+}
+
+// VerifyDKIM checks the DKIM-Signature of the message against the value
+// of the TXT record published by the signing domain
+func VerifyDKIM(message []byte, record string) error {
+	headers, body, err := splitMessage(message)
+	if err != nil {
+		return err
+	}
+
+	sigHeader, ok := lastHeader(headers, "DKIM-Signature", nil)
+	if !ok {
+		return fmt.Errorf("message isn't signed")
+	}
+
+	tags := parseTags(sigHeader[strings.Index(sigHeader, ":")+1:])
+	if tags["a"] != "rsa-sha256" || tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unsupported DKIM algorithm or canonicalization")
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return fmt.Errorf("body hash doesn't match")
+	}
+
+	der, err := base64.StdEncoding.DecodeString(parseTags(record)["p"])
+	if err != nil {
+		return fmt.Errorf("invalid DKIM record: %v", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("invalid DKIM record: %v", err)
+	}
+
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("invalid DKIM record: only RSA keys are supported")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	// headers are taken from the bottom, each instance used only once
+	h := sha256.New()
+	used := map[int]bool{}
+	for _, name := range strings.Split(tags["h"], ":") {
+		if header, ok := lastHeader(headers, strings.TrimSpace(name), used); ok {
+			h.Write([]byte(canonicalHeader(header) + "\r\n"))
+		}
+	}
+
+	// the signature is computed with an empty b= tag
+	unsigned := signatureRegexp.ReplaceAllString(sigHeader, "${1}b=")
+	h.Write([]byte(canonicalHeader(unsigned)))
+
+	if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, h.Sum(nil), signature); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	return nil
+}
+
+// splitMessage returns the header fields, unfolded lines included, and
+// the body of the message
+func splitMessage(message []byte) ([]string, []byte, error) {
+	i := bytes.Index(message, []byte("\r\n\r\n"))
+	if i < 0 {
+		return nil, nil, fmt.Errorf("message without body separator")
+	}
+
+	var headers []string
+	for _, line := range strings.Split(string(message[:i]), "\r\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(headers) > 0 {
+			headers[len(headers)-1] += "\r\n" + line
+			continue
+		}
+
+		headers = append(headers, line)
+	}
+
+	return headers, message[i+4:], nil
+}
+
+// lastHeader returns the last header field with the given name that
+// wasn't used yet
+func lastHeader(headers []string, name string, used map[int]bool) (string, bool) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		colon := strings.Index(headers[i], ":")
+		if colon < 0 || used[i] || !strings.EqualFold(strings.TrimSpace(headers[i][:colon]), name) {
+			continue
+		}
+
+		if used != nil {
+			used[i] = true
+		}
+
+		return headers[i], true
+	}
+
+	return "", false
+}
+
+// canonicalHeader applies the relaxed header canonicalization
+func canonicalHeader(header string) string {
+	colon := strings.Index(header, ":")
+	name := strings.ToLower(strings.TrimSpace(header[:colon]))
+
+	value := strings.ReplaceAll(header[colon+1:], "\r\n", "")
+	value = strings.TrimSpace(spacesRegexp.ReplaceAllString(value, " "))
+
+	return name + ":" + value
+}
+
+// canonicalBody applies the relaxed body canonicalization
+func canonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(spacesRegexp.ReplaceAllString(l, " "), " ")
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// parseTags parses a tag=value list of DKIM headers and records
+func parseTags(list string) map[string]string {
+	tags := map[string]string{}
+
+	for _, tag := range strings.Split(list, ";") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		// folding whitespace is allowed anywhere in values
+		value := strings.Join(strings.Fields(kv[1]), "")
+		tags[strings.TrimSpace(kv[0])] = value
+	}
+
+	return tags
+}
+
+// foldBase64 splits long signatures in lines of 72 characters
+func foldBase64(s string) string {
+	var out strings.Builder
+
+	for i := 0; i < len(s); i += 72 {
+		if i > 0 {
+			out.WriteString("\r\n ")
+		}
+
+		end := i + 72
+		if end > len(s) {
+			end = len(s)
+		}
+
+		out.WriteString(s[i:end])
+	}
+
+	return out.String()
+}
+
+// domainOf returns the lower case domain of the address
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return strings.ToLower(address[i+1:])
+	}
+
+	return ""
+}
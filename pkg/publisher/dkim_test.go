@@ -0,0 +1,126 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) *DKIMSigner {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed generating key: %v", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	signer, err := NewDKIMSigner("Example.com", "news", keyPEM)
+	if err != nil {
+		t.Fatalf("failed creating signer: %v", err)
+	}
+
+	return signer
+}
+
+func TestDKIMSignAndVerify(t *testing.T) {
+	signer := newTestSigner(t)
+
+	record, err := signer.DNSRecord()
+	if err != nil {
+		t.Fatalf("failed building DNS record: %v", err)
+	}
+
+	e := NewEmail(
+		NewEmailAddress("Example", "news@example.com"),
+		NewEmailAddress("Reader", "reader@example.org"),
+		"Issue 42",
+		"<p>Hello, reader.</p>",
+	)
+
+	message, err := e.Message(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed building message: %v", err)
+	}
+
+	signed, err := signer.Sign(message)
+	if err != nil {
+		t.Fatalf("failed signing message: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func([]byte) []byte
+		wantErr bool
+	}{
+		{
+			name:   "unmodified",
+			modify: func(m []byte) []byte { return m },
+		},
+		{
+			name: "unsigned header added",
+			modify: func(m []byte) []byte {
+				return append([]byte("X-Mailer: test\r\n"), m...)
+			},
+		},
+		{
+			name: "signed header modified",
+			modify: func(m []byte) []byte {
+				return bytes.Replace(m, []byte("Issue 42"), []byte("Issue 43"), 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "body modified",
+			modify: func(m []byte) []byte {
+				return bytes.Replace(m, []byte("Hello, reader."), []byte("Hello, world."), 1)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.modify(append([]byte(nil), signed...))
+
+			err := VerifyDKIM(m, record)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected the verification to fail")
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDKIMVerifyWithOtherKey(t *testing.T) {
+	signer := newTestSigner(t)
+
+	record, err := newTestSigner(t).DNSRecord()
+	if err != nil {
+		t.Fatalf("failed building DNS record: %v", err)
+	}
+
+	e := NewEmail(NewEmailAddress("", "news@example.com"), NewEmailAddress("", "reader@example.org"), "Issue 42", "<p>Hello</p>")
+
+	message, err := e.Message(time.Now())
+	if err != nil {
+		t.Fatalf("failed building message: %v", err)
+	}
+
+	signed, err := signer.Sign(message)
+	if err != nil {
+		t.Fatalf("failed signing message: %v", err)
+	}
+
+	if err := VerifyDKIM(signed, record); err == nil {
+		t.Fatal("expected the verification with another key to fail")
+	}
+}
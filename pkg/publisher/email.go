@@ -8,13 +8,17 @@ type EmailAddress struct {
 type Email struct {
 	From *EmailAddress
 	To *EmailAddress
+	// ReplyTo is optional, replies go to From when it's nil
+	ReplyTo *EmailAddress
 	Subject string
 	Content string
-
+	// Text is the plain text version of Content, it's generated from the
+	// HTML when empty
+	Text string
 }
 
 func NewEmail(from, to *EmailAddress, subject, content string) *Email {
-	return &Email{from, to, subject, content}
+	return &Email{From: from, To: to, Subject: subject, Content: content}
 }
 
 func NewEmailAddress(name, address string) *EmailAddress {
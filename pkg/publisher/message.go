@@ -0,0 +1,188 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+	spacesRegexp     = regexp.MustCompile(`[ \t]+`)
+)
+
+// textBlockTags end a line when the HTML is converted to plain text
+var textBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "hr": true, "ul": true, "ol": true,
+}
+
+// Message builds the complete RFC 5322 message of the email. The content
+// is sent as multipart/alternative, with a plain text version generated
+// from the HTML when the email doesn't have one.
+func (e *Email) Message(date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	text := e.Text
+	if text == "" {
+		var err error
+		if text, err = htmlToText(e.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	alternatives := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", e.Content},
+	}
+
+	for _, a := range alternatives {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed building email part: %v", err)
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(toCRLF(a.content))); err != nil {
+			return nil, fmt.Errorf("failed encoding email content: %v", err)
+		}
+
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed encoding email content: %v", err)
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed building email: %v", err)
+	}
+
+	headers := [][2]string{
+		{"From", formatAddress(e.From)},
+		{"To", formatAddress(e.To)},
+	}
+
+	if e.ReplyTo != nil {
+		headers = append(headers, [2]string{"Reply-To", formatAddress(e.ReplyTo)})
+	}
+
+	headers = append(headers, [][2]string{
+		{"Subject", mime.QEncoding.Encode("utf-8", e.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.From.Address)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", parts.Boundary())},
+	}...)
+
+	var msg bytes.Buffer
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// formatAddress formats the address as "Name <address>", encoding
+// names that aren't ASCII
+func formatAddress(a *EmailAddress) string {
+	return (&mail.Address{Name: a.Name, Address: a.Address}).String()
+}
+
+// messageID returns a unique Message-ID in the domain of the sender
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
+
+// toCRLF converts every line break to CRLF, as required by RFC 5322
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// htmlToText converts the HTML content into readable plain text, keeping
+// the URLs of links next to their text
+func htmlToText(content string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing email content: %v", err)
+	}
+
+	var out strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			out.WriteString(spacesRegexp.ReplaceAllString(strings.ReplaceAll(n.Data, "\n", " "), " "))
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "head", "script", "style", "title", "img":
+				return
+			}
+		}
+
+		if n.Type == html.ElementNode && n.Data == "li" {
+			out.WriteString("\n- ")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		if n.Type != html.ElementNode {
+			return
+		}
+
+		if n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key == "href" && strings.HasPrefix(a.Val, "http") {
+					fmt.Fprintf(&out, " (%s)", a.Val)
+				}
+			}
+		}
+
+		if textBlockTags[n.Data] {
+			out.WriteString("\n\n")
+		}
+	}
+
+	walk(doc)
+
+	lines := strings.Split(out.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+
+	text := blankLinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+	return strings.TrimSpace(text) + "\n", nil
+}
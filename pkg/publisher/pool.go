@@ -0,0 +1,284 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/pkg/campaign"
+	"github.com/statictask/newsletter/pkg/delivery"
+	"github.com/statictask/newsletter/pkg/post"
+	"github.com/statictask/newsletter/pkg/postitem"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/task"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+const (
+	// sendTimeout limits each call to the email provider
+	sendTimeout = 60 * time.Second
+	// progressInterval is the minimum interval between progress updates
+	// of the task in the database
+	progressInterval = 5 * time.Second
+)
+
+// publication holds everything needed to deliver a post to the
+// subscriptions of a project. The items of the post are processed once
+// for all subscriptions, items holds their template data by ID.
+type publication struct {
+	task          *task.Task
+	project       *project.Project
+	post          *post.Post
+	campaign      *campaign.Campaign
+	emailTemplate *template.EmailTemplate
+	subscriptions []*subscription.Subscription
+	postItems     []*postitem.PostItem
+	items         map[int64]*template.DataItem
+	tagger        *UTMTagger
+	tracker       *Tracker
+	browserLink   string
+	limiter       *RateLimiter
+	dryRun        bool
+	log           *zap.Logger
+
+	mu        sync.Mutex
+	lastFlush time.Time
+}
+
+// dataItems returns the template data of the post items
+func (pb *publication) dataItems(postItems []*postitem.PostItem) []*template.DataItem {
+	items := make([]*template.DataItem, len(postItems))
+	for i, pi := range postItems {
+		items[i] = pb.items[pi.ID]
+	}
+
+	return items
+}
+
+// runPublication delivers the post using a bounded pool of workers.
+// Subscriptions that already received the post are skipped, so running
+// the publication again only sends the missing emails.
+func (w *Watcher) runPublication(pb *publication) {
+	concurrency := pb.project.Settings.Delivery.Concurrency
+	if concurrency <= 0 {
+		concurrency = int(w.concurrency)
+	}
+
+	// without workers the subscriptions would wait forever
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sent, err := delivery.NewPostDeliveries(pb.post.ID).Sent()
+	if err != nil {
+		pb.log.Error("Failed loading Post deliveries.", zap.Error(err))
+		sent = map[int64]bool{}
+	}
+
+	pb.task.Sent, pb.task.Failed, pb.task.Rejected = 0, 0, 0
+	pb.task.Total = int64(len(pb.subscriptions))
+
+	var pending []*subscription.Subscription
+	for _, s := range pb.subscriptions {
+		if sent[s.ID] {
+			pb.task.Sent++
+			continue
+		}
+
+		pending = append(pending, s)
+	}
+
+	pb.flushProgress(true)
+
+	jobs := make(chan *subscription.Subscription)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for s := range jobs {
+				d := w.deliver(pb, s)
+				if d.Status == delivery.Failed || d.Status == delivery.Rejected {
+					pb.log.Error(
+						"Failed sending email.",
+						zap.String("error", d.Error),
+						zap.String("delivery_status", string(d.Status)),
+						zap.Int("attempts", d.Attempts),
+						zap.Int64("subscription_id", s.ID),
+					)
+				}
+
+				if err := w.saveDelivery(d); err != nil {
+					pb.log.Error("Failed saving Delivery.", zap.Error(err), zap.Int64("subscription_id", s.ID))
+				}
+
+				pb.recordDelivery(d.Status)
+			}
+		}()
+	}
+
+	for _, s := range pending {
+		jobs <- s
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	pb.flushProgress(true)
+}
+
+// deliver builds and sends the email of a single subscription
+func (w *Watcher) deliver(pb *publication, s *subscription.Subscription) *delivery.Delivery {
+	d := delivery.New()
+	d.PostID = pb.post.ID
+	d.SubscriptionID = s.ID
+	d.Status = delivery.Failed
+
+	// posts wait for the next digest of subscribers who read digests,
+	// campaigns are announcements and are sent right away
+	if s.ReadsDigests() && pb.campaign == nil {
+		d.Status = delivery.Deferred
+		return d
+	}
+
+	email, err := w.buildEmail(pb, s)
+	if errors.Is(err, errNothingToRead) {
+		d.Status = delivery.Skipped
+		return d
+	}
+
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	w.sendWithRetries(d, email, pb.limiter, pb.log)
+
+	return d
+}
+
+// sendWithRetries sends the email and records the result in the delivery,
+// waiting for the project and provider rate limits before each attempt.
+// Transient errors are retried according to the retry policy.
+func (w *Watcher) sendWithRetries(d *delivery.Delivery, email *Email, limiter *RateLimiter, _log *zap.Logger) {
+	for {
+		if err := limiter.Wait(context.Background()); err != nil {
+			d.Error = err.Error()
+			return
+		}
+
+		if err := w.providerLimiter.Wait(context.Background()); err != nil {
+			d.Error = err.Error()
+			return
+		}
+
+		d.Attempts++
+
+		err := w.send(email)
+		if err == nil {
+			d.Status = delivery.Sent
+			d.Error = ""
+			return
+		}
+
+		d.Error = err.Error()
+
+		if IsPermanent(err) {
+			d.Status = delivery.Rejected
+			return
+		}
+
+		if d.Attempts >= w.retryPolicy.MaxAttempts {
+			return
+		}
+
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			// backpressure: every worker of every project waits before
+			// sending again to the provider
+			_log.Warn("Email provider is rate limiting us.", zap.Duration("retry_after", rateLimitErr.RetryAfter))
+			w.providerLimiter.Pause(rateLimitErr.RetryAfter)
+			continue
+		}
+
+		backoff := w.retryPolicy.Backoff(d.Attempts)
+		_log.Debug(
+			"Retrying email after transient error.",
+			zap.Error(err),
+			zap.Int("attempt", d.Attempts),
+			zap.Duration("backoff", backoff),
+			zap.Int64("subscription_id", d.SubscriptionID),
+		)
+
+		time.Sleep(backoff)
+	}
+}
+
+// send calls the email provider with a timeout
+func (w *Watcher) send(email *Email) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	return w.sender.Send(ctx, email)
+}
+
+// recordDelivery counts the result of a delivery in the task progress
+func (pb *publication) recordDelivery(status delivery.Status) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	switch status {
+	case delivery.Sent:
+		pb.task.Sent++
+	case delivery.Skipped, delivery.Deferred:
+		// skipped and deferred subscriptions don't count in the progress
+		// of the task, deferred ones count in their digest
+		pb.task.Total--
+	case delivery.Rejected:
+		pb.task.Rejected++
+	default:
+		pb.task.Failed++
+	}
+
+	pb.flushProgressLocked(false)
+}
+
+// flushProgress stores the task progress in the database
+func (pb *publication) flushProgress(force bool) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.flushProgressLocked(force)
+}
+
+// flushProgressLocked stores the task progress in the database when
+// forced or when progressInterval elapsed since the last update
+func (pb *publication) flushProgressLocked(force bool) {
+	if !force && time.Since(pb.lastFlush) < progressInterval {
+		return
+	}
+
+	pb.lastFlush = time.Now()
+
+	// dry runs only log the progress
+	if !pb.dryRun {
+		if err := pb.task.UpdateProgress(); err != nil {
+			pb.log.Error("Failed updating Task progress.", zap.Error(err))
+			return
+		}
+	}
+
+	pb.log.Info(
+		"Publish task progress.",
+		zap.String("progress", pb.task.Progress()),
+		zap.Int64("failed", pb.task.Failed),
+		zap.Int64("rejected", pb.task.Rejected),
+	)
+}
@@ -0,0 +1,133 @@
+package publisher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/vanng822/go-premailer/premailer"
+
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+// urlAttributes are the attributes rewritten to absolute URLs in
+// the content of feed items
+var urlAttributes = map[string]string{
+	"a":      "href",
+	"img":    "src",
+	"source": "src",
+	"video":  "poster",
+	"audio":  "src",
+	"iframe": "src",
+}
+
+// PostProcessor applies the steps enabled in the project settings
+// to the HTML of emails after the template is rendered
+type PostProcessor struct {
+	settings project.ProcessingSettings
+}
+
+// NewPostProcessor returns a PostProcessor for the given settings
+func NewPostProcessor(settings project.ProcessingSettings) *PostProcessor {
+	return &PostProcessor{settings}
+}
+
+// ProcessItemContent rewrites relative URLs in the content of a feed
+// item to absolute ones based on the given item or site URL
+func (pp *PostProcessor) ProcessItemContent(content, baseURL string) (string, error) {
+	if !pp.settings.AbsoluteURLs || content == "" || baseURL == "" {
+		return content, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL '%s': %v", baseURL, err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing item content: %v", err)
+	}
+
+	for tag, attr := range urlAttributes {
+		doc.Find(tag).Each(func(_ int, s *goquery.Selection) {
+			value, ok := s.Attr(attr)
+			if !ok {
+				return
+			}
+
+			if abs := absoluteURL(base, value); abs != value {
+				s.SetAttr(attr, abs)
+			}
+		})
+	}
+
+	// item content is a fragment, so only the body content is returned
+	return doc.Find("body").Html()
+}
+
+// Process runs the enabled steps over the rendered email content
+func (pp *PostProcessor) Process(content string) (string, error) {
+	if pp.settings.LinkAttributes {
+		var err error
+		if content, err = pp.setLinkAttributes(content); err != nil {
+			return "", err
+		}
+	}
+
+	if pp.settings.InlineCSS {
+		p, err := premailer.NewPremailerFromString(content, premailer.NewOptions())
+		if err != nil {
+			return "", fmt.Errorf("failed parsing email content: %v", err)
+		}
+
+		if content, err = p.Transform(); err != nil {
+			return "", fmt.Errorf("failed inlining email CSS: %v", err)
+		}
+	}
+
+	return content, nil
+}
+
+// ExceedsMaxSize says if the content is bigger than the maximum message
+// size configured for the project
+func (pp *PostProcessor) ExceedsMaxSize(content string) bool {
+	return pp.settings.MaxMessageSize > 0 && len(content) > pp.settings.MaxMessageSize
+}
+
+// setLinkAttributes adds the target and rel attributes to every link
+func (pp *PostProcessor) setLinkAttributes(content string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing email content: %v", err)
+	}
+
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		if pp.settings.LinkTarget != "" {
+			s.SetAttr("target", pp.settings.LinkTarget)
+		}
+
+		if pp.settings.LinkRel != "" {
+			s.SetAttr("rel", pp.settings.LinkRel)
+		}
+	})
+
+	return goquery.OuterHtml(doc.Selection)
+}
+
+// absoluteURL resolves the reference against the base URL, keeping
+// anchors, mailto links and invalid values untouched
+func absoluteURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "{{") {
+		return ref
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+
+	return base.ResolveReference(u).String()
+}
@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderSMTP     = "smtp"
+	ProviderFile     = "file"
+	ProviderLog      = "log"
+)
+
+var Providers = []string{ProviderSendGrid, ProviderSMTP, ProviderFile, ProviderLog}
+
+// NewEmailSender returns the EmailSender of the given provider
+func NewEmailSender(provider string) (EmailSender, error) {
+	switch provider {
+	case ProviderSendGrid:
+		return NewSender(), nil
+	case ProviderSMTP:
+		keyring, err := LoadDKIMKeyring(config.C.DKIMKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewSMTPSender(keyring), nil
+	case ProviderFile:
+		return NewFileSender(config.C.EmailFileDir, config.C.EmailFileFormat)
+	case ProviderLog:
+		return NewLogSender(), nil
+	}
+
+	return nil, fmt.Errorf("invalid email provider '%s', use one of %v", provider, Providers)
+}
@@ -0,0 +1,142 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket that refills at a constant rate up to its
+// capacity
+type bucket struct {
+	capacity float64
+	tokens   float64
+	// rate is the number of tokens added per second
+	rate float64
+	last time.Time
+}
+
+// refill adds the tokens accumulated since the last refill
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	b.last = now
+}
+
+// wait returns how long it takes for the bucket to have a token
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	// rounding must never turn a missing token into no wait at all
+	d := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+
+	return d
+}
+
+// RateLimiter limits the number of messages sent per second, hour and
+// day using one token bucket for each window. Buckets start full and
+// live in memory, so the limits are enforced per process.
+type RateLimiter struct {
+	mu          sync.Mutex
+	buckets     []*bucket
+	pausedUntil time.Time
+}
+
+// NewRateLimiter returns a RateLimiter with the given limits, 0 means
+// the window isn't limited
+func NewRateLimiter(perSecond, perHour, perDay int) *RateLimiter {
+	rl := &RateLimiter{}
+	now := time.Now()
+
+	windows := []struct {
+		limit  int
+		period time.Duration
+	}{
+		{perSecond, time.Second},
+		{perHour, time.Hour},
+		{perDay, 24 * time.Hour},
+	}
+
+	for _, w := range windows {
+		if w.limit <= 0 {
+			continue
+		}
+
+		rl.buckets = append(rl.buckets, &bucket{
+			capacity: float64(w.limit),
+			tokens:   float64(w.limit),
+			rate:     float64(w.limit) / w.period.Seconds(),
+			last:     now,
+		})
+	}
+
+	return rl
+}
+
+// Wait blocks until a message can be sent according to every limit, or
+// until the context is done
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := rl.reserve()
+		if d == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause stops sending messages for the given duration, it's used when
+// the provider asks us to slow down
+func (rl *RateLimiter) Pause(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(rl.pausedUntil) {
+		rl.pausedUntil = until
+	}
+}
+
+// reserve takes a token from every bucket if all of them have one,
+// otherwise it returns how long to wait before trying again
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(rl.pausedUntil) {
+		return rl.pausedUntil.Sub(now)
+	}
+
+	var longest time.Duration
+	for _, b := range rl.buckets {
+		b.refill(now)
+		if d := b.wait(); d > longest {
+			longest = d
+		}
+	}
+
+	if longest > 0 {
+		return longest
+	}
+
+	for _, b := range rl.buckets {
+		b.tokens--
+	}
+
+	return 0
+}
@@ -0,0 +1,54 @@
+package publisher
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// RetryPolicy says how many times and how often emails that failed with
+// transient errors are sent again
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// NewRetryPolicy returns the RetryPolicy defined in the configuration
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: int(config.C.SendMaxAttempts),
+		BaseDelay:   config.C.SendRetryBaseDelay,
+		MaxDelay:    config.C.SendRetryMaxDelay,
+	}
+}
+
+// Backoff returns how long to wait after the given failed attempt,
+// starting from 1. The delay doubles at each attempt up to MaxDelay and
+// a random jitter avoids retrying every failed email at the same time.
+func (rp *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := rp.BaseDelay
+	for i := 1; i < attempt && d < rp.MaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > rp.MaxDelay {
+		d = rp.MaxDelay
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	// "equal jitter": half of the delay is fixed, half is random
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsPermanent says if the error won't go away by sending the email
+// again, errors that weren't classified by the sender are transient
+func IsPermanent(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent
+}
@@ -0,0 +1,137 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+)
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unclassified", errors.New("boom"), false},
+		{"transient", &SendError{Err: errors.New("timeout")}, false},
+		{"permanent", &SendError{Err: errors.New("rejected"), Permanent: true}, true},
+		{"wrapped permanent", fmt.Errorf("failed: %w", &SendError{Err: errors.New("rejected"), Permanent: true}), true},
+		{"rate limited", &RateLimitError{time.Second}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Fatalf("IsPermanent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rp := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				// half of the delay is random
+				if d := rp.Backoff(tt.attempt); d < tt.delay/2 || d > tt.delay {
+					t.Fatalf("Backoff = %s, want between %s and %s", d, tt.delay/2, tt.delay)
+				}
+			}
+		})
+	}
+
+	if d := (&RetryPolicy{}).Backoff(1); d != 0 {
+		t.Fatalf("Backoff without delays = %s, want 0", d)
+	}
+}
+
+// fakeClient answers every email with the response or the error
+type fakeClient struct {
+	response *rest.Response
+	err      error
+}
+
+func (c *fakeClient) SendWithContext(ctx context.Context, email *mail.SGMailV3) (*rest.Response, error) {
+	return c.response, c.err
+}
+
+func TestSenderClassification(t *testing.T) {
+	log.L = zap.NewNop()
+
+	tests := []struct {
+		name          string
+		client        *fakeClient
+		wantErr       bool
+		wantPermanent bool
+		wantRateLimit time.Duration
+	}{
+		{"sent", &fakeClient{response: &rest.Response{StatusCode: http.StatusAccepted}}, false, false, 0},
+		{"network error", &fakeClient{err: errors.New("connection reset")}, true, false, 0},
+		{"provider error", &fakeClient{response: &rest.Response{StatusCode: http.StatusBadGateway}}, true, false, 0},
+		{"rejected", &fakeClient{response: &rest.Response{StatusCode: http.StatusBadRequest}}, true, true, 0},
+		{"unauthorized", &fakeClient{response: &rest.Response{StatusCode: http.StatusUnauthorized}}, true, true, 0},
+		{
+			"rate limited",
+			&fakeClient{response: &rest.Response{StatusCode: http.StatusTooManyRequests, Headers: map[string][]string{"Retry-After": {"30"}}}},
+			true, false, 30 * time.Second,
+		},
+		{
+			"rate limited without headers",
+			&fakeClient{response: &rest.Response{StatusCode: http.StatusTooManyRequests}},
+			true, false, defaultRetryAfter,
+		},
+	}
+
+	e := NewEmail(
+		NewEmailAddress("Example", "news@example.com"),
+		NewEmailAddress("Jane", "jane@example.com"),
+		"Hello",
+		"<p>Hello</p>",
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Sender{tt.client}).Send(context.Background(), e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Fatalf("IsPermanent = %v, want %v", IsPermanent(err), tt.wantPermanent)
+			}
+
+			var rateLimitErr *RateLimitError
+			if errors.As(err, &rateLimitErr) != (tt.wantRateLimit > 0) {
+				t.Fatalf("error = %v, want rate limit %v", err, tt.wantRateLimit > 0)
+			}
+
+			if rateLimitErr != nil && rateLimitErr.RetryAfter != tt.wantRateLimit {
+				t.Fatalf("RetryAfter = %s, want %s", rateLimitErr.RetryAfter, tt.wantRateLimit)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package publisher
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	sendgrid "github.com/sendgrid/sendgrid-go"
@@ -14,6 +17,35 @@ import (
 
 )
 
+// defaultRetryAfter is used when the provider rate limits us without
+// saying for how long
+const defaultRetryAfter = 5 * time.Second
+
+// RateLimitError is returned when the provider refuses emails because
+// they're being sent too fast
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by the email provider, retry after %s", e.RetryAfter)
+}
+
+// SendError is an error of the email provider. Permanent errors, like
+// invalid addresses, fail again if the email is sent one more time.
+type SendError struct {
+	Err       error
+	Permanent bool
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
 type EmailServiceClient interface {
 	SendWithContext(ctx context.Context, email *mail.SGMailV3) (*rest.Response, error)
 }
@@ -37,22 +69,49 @@ func (s *Sender) Send(ctx context.Context, e *Email) error {
 	subject := e.Subject
 	htmlContent := e.Content
 
-
-	fmt.Println("------------------------")
-	fmt.Println(e.Subject)
-	fmt.Println("------------------------")
-	fmt.Println(e.Content)
-	fmt.Println("------------------------")
-
 	message := mail.NewSingleEmail(from, subject, to, "", htmlContent)
+	if e.ReplyTo != nil {
+		message.SetReplyTo(mail.NewEmail(e.ReplyTo.Name, e.ReplyTo.Address))
+	}
 
 	response, err := s.Client.SendWithContext(ctx, message)
 	if err != nil {
+		// network errors and timeouts don't say anything about the email
 		_log.Info("Failed sending email.", zap.Error(err))
-		return err
-	} else {
-		_log.Info("Sendgrid email successfuly sent.", zap.Int("status_code", response.StatusCode))
+		return &SendError{Err: err}
+	}
+
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{retryAfter(response.Headers)}
+	case response.StatusCode >= 500:
+		return &SendError{Err: fmt.Errorf("provider error %d: %s", response.StatusCode, response.Body)}
+	case response.StatusCode >= 400:
+		return &SendError{
+			Err:       fmt.Errorf("email rejected with status %d: %s", response.StatusCode, response.Body),
+			Permanent: true,
+		}
 	}
 
+	_log.Info("Sendgrid email successfuly sent.", zap.Int("status_code", response.StatusCode))
+
 	return nil
 }
+
+// retryAfter reads how long to wait from the headers of a rate limited
+// response. SendGrid sends the time the limit resets as a Unix timestamp.
+func retryAfter(headers map[string][]string) time.Duration {
+	h := http.Header(headers)
+
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if d := time.Until(time.Unix(reset, 0)); d > 0 {
+			return d
+		}
+	}
+
+	if seconds, err := strconv.Atoi(h.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultRetryAfter
+}
@@ -0,0 +1,141 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+)
+
+const (
+	FormatEML  = "eml"
+	FormatMbox = "mbox"
+)
+
+var (
+	unsafeFileNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	mboxFromLineRegexp   = regexp.MustCompile(`(?m)^(>*From )`)
+)
+
+// FileSender writes emails into a directory instead of sending them,
+// either one .eml file per email or all of them in a single mbox file
+type FileSender struct {
+	dir    string
+	format string
+
+	mu sync.Mutex
+}
+
+// NewFileSender returns a FileSender that writes into dir using the
+// given format
+func NewFileSender(dir, format string) (*FileSender, error) {
+	if format != FormatEML && format != FormatMbox {
+		return nil, fmt.Errorf("invalid file format '%s', use %s or %s", format, FormatEML, FormatMbox)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory '%s': %v", dir, err)
+	}
+
+	return &FileSender{dir: dir, format: format}, nil
+}
+
+// Send writes the email in the directory
+func (s *FileSender) Send(ctx context.Context, e *Email) error {
+	now := time.Now()
+
+	message, err := e.Message(now)
+	if err != nil {
+		return &SendError{Err: err, Permanent: true}
+	}
+
+	var path string
+	if s.format == FormatMbox {
+		path, err = s.appendMbox(e, now, message)
+	} else {
+		path, err = s.writeEML(e, now, message)
+	}
+
+	if err != nil {
+		return &SendError{Err: err}
+	}
+
+	log.L.Info("Email written to file.", zap.String("target", e.To.Address), zap.String("path", path))
+
+	return nil
+}
+
+// writeEML writes the message into its own file
+func (s *FileSender) writeEML(e *Email, now time.Time, message []byte) (string, error) {
+	name := fmt.Sprintf(
+		"%s-%s.eml",
+		now.Format("20060102T150405.000000000"),
+		unsafeFileNameRegexp.ReplaceAllString(e.To.Address, "_"),
+	)
+
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, message, 0o644); err != nil {
+		return "", fmt.Errorf("unable to write email: %v", err)
+	}
+
+	return path, nil
+}
+
+// appendMbox appends the message to the mbox file, escaping lines that
+// would be confused with the separator of messages
+func (s *FileSender) appendMbox(e *Email, now time.Time, message []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, "outbox.mbox")
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("unable to open mbox: %v", err)
+	}
+
+	defer f.Close()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", e.From.Address, now.UTC().Format(time.ANSIC))
+	buf.Write(mboxFromLineRegexp.ReplaceAll(bytes.ReplaceAll(message, []byte("\r\n"), []byte("\n")), []byte(">$1")))
+	buf.WriteString("\n")
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return "", fmt.Errorf("unable to write email: %v", err)
+	}
+
+	return path, nil
+}
+
+// LogSender logs emails instead of sending them. The content is only
+// logged in debug level.
+type LogSender struct{}
+
+// NewLogSender returns a LogSender
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send logs the email
+func (s *LogSender) Send(ctx context.Context, e *Email) error {
+	log.L.Info(
+		"Email logged.",
+		zap.String("from", e.From.Address),
+		zap.String("target", e.To.Address),
+		zap.String("subject", e.Subject),
+		zap.Int("size", len(e.Content)),
+	)
+
+	log.L.Debug("Email content.", zap.String("target", e.To.Address), zap.String("content", e.Content))
+
+	return nil
+}
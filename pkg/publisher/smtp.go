@@ -0,0 +1,150 @@
+package publisher
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+)
+
+// SMTPSender delivers emails to an SMTP server, signing them with DKIM
+// when the keyring has a key for the sender's domain
+type SMTPSender struct {
+	host    string
+	addr    string
+	auth    smtp.Auth
+	keyring *DKIMKeyring
+}
+
+// NewSMTPSender returns an SMTPSender for the server in the config
+func NewSMTPSender(keyring *DKIMKeyring) *SMTPSender {
+	host := config.C.SMTPHost
+
+	var auth smtp.Auth
+	if config.C.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", config.C.SMTPUsername, config.C.SMTPPassword, host)
+	}
+
+	return &SMTPSender{
+		host:    host,
+		addr:    net.JoinHostPort(host, strconv.FormatInt(config.C.SMTPPort, 10)),
+		auth:    auth,
+		keyring: keyring,
+	}
+}
+
+// Send builds the message of the email and delivers it to the server
+func (s *SMTPSender) Send(ctx context.Context, e *Email) error {
+	message, err := e.Message(time.Now())
+	if err != nil {
+		return &SendError{Err: err, Permanent: true}
+	}
+
+	if message, err = s.keyring.Sign(e.From.Address, message); err != nil {
+		return &SendError{Err: err, Permanent: true}
+	}
+
+	if err := s.sendMail(ctx, e.From.Address, e.To.Address, message); err != nil {
+		log.L.Info("Failed sending email.", zap.String("target", e.To.Address), zap.Error(err))
+
+		// only 5xx replies are permanent, 4xx ones and network errors
+		// may succeed later
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			return &SendError{Err: fmt.Errorf("email rejected: %v", err), Permanent: true}
+		}
+
+		return &SendError{Err: err}
+	}
+
+	log.L.Info("SMTP email successfuly sent.", zap.String("target", e.To.Address))
+
+	return nil
+}
+
+// sendMail delivers the message like smtp.SendMail, but dialing the
+// server with the context and giving up on the connection when the
+// context is done
+func (s *SMTPSender) sendMail(ctx context.Context, from, to string, message []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// interrupt the exchange when the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,97 @@
+package publisher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/tracking"
+)
+
+// Tracker injects the open pixel and rewrites links to the click redirect
+// service in the emails of a post
+type Tracker struct {
+	settings project.TrackingSettings
+	post     *tracking.PostTracking
+	postID   int64
+}
+
+// NewTracker returns a Tracker for the post using the given settings
+func NewTracker(settings project.TrackingSettings, postID int64) *Tracker {
+	return &Tracker{settings, tracking.NewPostTracking(postID), postID}
+}
+
+// Track returns the email content with tracking enabled for the
+// subscriber. Nothing is tracked while TRACKING_SECRET isn't configured.
+func (t *Tracker) Track(content string, subscriptionID int64) (string, error) {
+	if !tracking.Enabled() || (!t.settings.Opens && !t.settings.Clicks) {
+		return content, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing email content: %v", err)
+	}
+
+	if t.settings.Clicks {
+		var linkErr error
+
+		doc.Find("a[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			href, _ := s.Attr("href")
+			if !isTrackableURL(href) {
+				return true
+			}
+
+			l, err := t.post.Link(strings.TrimSpace(href))
+			if err != nil {
+				linkErr = err
+				return false
+			}
+
+			token := tracking.NewToken(subscriptionID, t.postID, l.ID)
+			s.SetAttr("href", trackingURL("r", token))
+
+			return true
+		})
+
+		if linkErr != nil {
+			return "", linkErr
+		}
+	}
+
+	if t.settings.Opens {
+		token := tracking.NewToken(subscriptionID, t.postID, 0)
+		doc.Find("body").AppendHtml(fmt.Sprintf(
+			`<img src="%s" width="1" height="1" alt="" style="display:none;border:0;">`,
+			trackingURL("o", token),
+		))
+	}
+
+	return goquery.OuterHtml(doc.Selection)
+}
+
+// trackingURL returns the URL of the tracking service for the token
+func trackingURL(path string, token *tracking.Token) string {
+	u := url.URL{
+		Scheme: "https",
+		Host:   config.C.ApplicationDomain,
+		Path:   fmt.Sprintf("%s/%s", path, token.Encode()),
+	}
+
+	return u.String()
+}
+
+// isTrackableURL accepts absolute http(s) URLs, except the ones served
+// by the application itself, like unsubscribe links
+func isTrackableURL(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	return !strings.EqualFold(u.Host, config.C.ApplicationDomain)
+}
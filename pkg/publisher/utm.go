@@ -0,0 +1,108 @@
+package publisher
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+var slugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// UTMTagger adds the UTM parameters configured for the project to the
+// links of a post
+type UTMTagger struct {
+	settings project.UTMSettings
+	params   url.Values
+}
+
+// NewUTMTagger returns a UTMTagger for the post with the given title
+// and issue number
+func NewUTMTagger(settings project.UTMSettings, title string, issue int64) *UTMTagger {
+	campaign := strings.NewReplacer(
+		"{title}", title,
+		"{slug}", slugify(title),
+		"{issue}", strconv.FormatInt(issue, 10),
+	).Replace(settings.Campaign)
+
+	params := url.Values{}
+	params.Set("utm_source", settings.Source)
+	params.Set("utm_medium", settings.Medium)
+	params.Set("utm_campaign", campaign)
+
+	return &UTMTagger{settings, params}
+}
+
+// TagURL returns the link with the UTM parameters. Links that already
+// define a parameter keep their value.
+func (t *UTMTagger) TagURL(link string) string {
+	if !t.settings.Enabled {
+		return link
+	}
+
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || t.isExcluded(u.Hostname()) {
+		return link
+	}
+
+	query := u.Query()
+	for key := range t.params {
+		if query.Get(key) == "" {
+			query.Set(key, t.params.Get(key))
+		}
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// TagContent tags the links inside the content of a feed item when the
+// project enabled it
+func (t *UTMTagger) TagContent(content string) (string, error) {
+	if !t.settings.Enabled || !t.settings.Content || content == "" {
+		return content, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing item content: %v", err)
+	}
+
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		if tagged := t.TagURL(href); tagged != href {
+			s.SetAttr("href", tagged)
+		}
+	})
+
+	// item content is a fragment, so only the body content is returned
+	return doc.Find("body").Html()
+}
+
+// isExcluded says if the host is the application itself or one of the
+// domains excluded by the project
+func (t *UTMTagger) isExcluded(host string) bool {
+	host = strings.ToLower(host)
+
+	domains := append([]string{config.C.ApplicationDomain}, t.settings.ExcludedDomains...)
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// slugify converts the text to lower case words separated by dashes
+func slugify(text string) string {
+	return strings.Trim(slugRegexp.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
@@ -2,38 +2,92 @@ package publisher
 
 import (
 	"time"
+	"errors"
 	"fmt"
+	"sync"
 	"context"
 	"net/url"
+	tpl "html/template"
 
 	"go.uber.org/zap"
 
 	"github.com/statictask/newsletter/internal/log"
 	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/pkg/campaign"
+	"github.com/statictask/newsletter/pkg/delivery"
 	"github.com/statictask/newsletter/pkg/task"
 	"github.com/statictask/newsletter/pkg/post"
+	"github.com/statictask/newsletter/pkg/postitem"
 	"github.com/statictask/newsletter/pkg/project"
 	"github.com/statictask/newsletter/pkg/subscription"
 	"github.com/statictask/newsletter/pkg/template"
 )
 
+// errNothingToRead is returned when none of the items of a post match
+// the topics of the subscriber
+var errNothingToRead = errors.New("no items match the subscriber's topics")
+
 type EmailSender interface {
 	Send (ctx context.Context, e *Email) error
 }
 
 type Watcher struct {
 	sender EmailSender
+	concurrency int64
+	retryPolicy *RetryPolicy
+
+	// providerLimiter is shared by all projects, since they use the
+	// same account in the email provider
+	providerLimiter *RateLimiter
+
+	mu sync.Mutex
+	projectLimiters map[int64]*projectLimiter
+
+	// dryRun keeps the ledger and the tasks as they are, dryRunSent
+	// remembers what was delivered instead, so it isn't delivered again
+	dryRun bool
+	dryRunSent map[string]bool
 }
 
-func New() *Watcher {
-	sender := NewSender()
-	return &Watcher{sender}
+// projectLimiter is the rate limiter of a project and the settings
+// used to create it
+type projectLimiter struct {
+	settings project.DeliverySettings
+	limiter *RateLimiter
+}
+
+// New returns a Watcher that sends emails using the configured provider
+func New() (*Watcher, error) {
+	sender, err := NewEmailSender(config.C.EmailProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	providerLimiter := NewRateLimiter(
+		int(config.C.ProviderRatePerSecond),
+		int(config.C.ProviderRatePerHour),
+		int(config.C.ProviderRatePerDay),
+	)
+
+	return &Watcher{
+		sender: sender,
+		concurrency: config.C.PublisherConcurrency,
+		retryPolicy: NewRetryPolicy(),
+		providerLimiter: providerLimiter,
+		projectLimiters: map[int64]*projectLimiter{},
+		dryRun: config.C.DryRun,
+		dryRunSent: map[string]bool{},
+	}, nil
 }
 
 // Run executes an infinite loop that keeps checking if there are
 // new posts to be sent
 func (w *Watcher) Run() {
 	_log := log.L.With(zap.String("watcher", "publisher"))
+
+	go w.watchWelcomes()
+	go w.watchDigests()
+
 	for {
 		time.Sleep(10 * time.Second)
 
@@ -69,6 +123,13 @@ func (w *Watcher) processWaitingTasks() error {
 			continue
 		}
 
+		// Broadcast pipelines don't scrape, their campaign makes the
+		// Publish task ready when it's queued
+		if scrapeTask == nil {
+			_log.Debug("Pipeline has no scrape task.")
+			continue
+		}
+
 		if scrapeTask.Status != task.Finished {
 			_log.Debug("Scrape task is not finished.", zap.Error(err))
 			continue
@@ -99,6 +160,11 @@ func (w *Watcher) processReadyTasks() error {
 			zap.Int64("pipeline_id", t.PipelineID),
 		)
 
+		// the tasks of dry runs stay Ready, so they are published once
+		if w.dryRunDelivered(fmt.Sprintf("task:%d", t.ID)) {
+			continue
+		}
+
 		pipelinePosts := post.NewPipelinePosts(t.PipelineID)	
 		lastPost, err := pipelinePosts.Last()
 		if err != nil {
@@ -121,113 +187,437 @@ func (w *Watcher) processReadyTasks() error {
 
 		_log = _log.With(zap.Int64("project_id", taskProject.ID))
 
-		subscriptions, err := taskProject.Subscriptions().All()
+		c, err := campaign.NewCampaigns().GetByPipelineID(t.PipelineID)
+		if err != nil {
+			_log.Error("Failed loading the Task's Campaign. Skipping.", zap.Error(err))
+			continue
+		}
+
+		// suppressed addresses bounced or complained, in any project
+		subscriptions, err := w.loadAudience(taskProject, c)
 		if err != nil {
 			_log.Error("Failed loading Project's Subscriptions. Skipping.", zap.Error(err))
 			continue
 		}
 
-		activeEmailTemplate, err := taskProject.EmailTemplates().GetActive()
+		emailTemplate, issue, err := w.loadEmailTemplate(taskProject, c, lastPost)
 		if err != nil {
-			_log.Error("Failed loading Project's active EmailTemplate. Skipping", zap.Error(err))
+			_log.Error("Failed loading the Task's EmailTemplate. Skipping.", zap.Error(err))
 			continue
 		}
 
-		deliveryCount := 0
-		deliveryTotal := len(subscriptions)
+		postItems, err := lastPost.PostItems().All()
+		if err != nil {
+			_log.Error("Failed loading the Post's items. Skipping.", zap.Error(err))
+			continue
+		}
 
-		for _, s := range subscriptions {
-			__log := _log.With(zap.Int64("subscription_id", s.ID))
+		// the items are the same for every subscriber, only the ones
+		// they read change
+		tagger := NewUTMTagger(taskProject.Settings.UTM, lastPost.Title, issue)
+		dataItems, err := w.buildItems(taskProject, postItems, tagger)
+		if err != nil {
+			_log.Error("Failed processing the Post's items. Skipping.", zap.Error(err))
+			continue
+		}
 
-			if err := w.sendEmail(s, lastPost, activeEmailTemplate); err != nil {
-				__log.Error("failed sending email", zap.Error(err))
-				continue
-			}
+		items := make(map[int64]*template.DataItem, len(postItems))
+		for i, pi := range postItems {
+			items[pi.ID] = dataItems[i]
+		}
 
-			deliveryCount += 1
-			__log.Info(fmt.Sprintf("email was sent (%d/%d)", deliveryCount, deliveryTotal))
+		t.Status = task.Running
+		if err := w.updateTask(t); err != nil {
+			_log.Error("Failed to mark Task as Running. Skipping.", zap.Error(err))
+			continue
 		}
 
-		if deliveryCount != len(subscriptions) {
-			_log.Error(
-				"failed to send one or more emails",
-				zap.Int("failed", deliveryTotal - deliveryCount),
-				zap.Int("delivered", deliveryCount),
-			)
+		pb := &publication{
+			task:          t,
+			project:       taskProject,
+			post:          lastPost,
+			campaign:      c,
+			emailTemplate: emailTemplate,
+			subscriptions: subscriptions,
+			postItems:     postItems,
+			items:         items,
+			tagger:        tagger,
+			tracker:       NewTracker(taskProject.Settings.Tracking, lastPost.ID),
+			limiter:       w.projectLimiter(taskProject),
+			dryRun:        w.dryRun,
+			log:           _log,
+		}
 
-			t.Status = task.Failed
-			if err := t.Update(); err != nil {
-				_log.Error("failed to mark task as failed", zap.Error(err))
-				continue
+		// campaigns aren't numbered issues, so only the posts of the feed
+		// are archived
+		if c == nil {
+			if pb.browserLink, err = w.archiveIssue(pb, issue); err != nil {
+				_log.Error("Failed archiving the issue.", zap.Error(err))
 			}
 		}
 
-		t.Status = task.Finished
-		if err := t.Update(); err != nil {
-			_log.Error("failed updating task", zap.Error(err))
-			continue
+		// tasks run in the background, so a big project doesn't delay
+		// the publications of the other ones
+		go w.publish(pb)
+	}
+
+	return nil
+}
+
+// loadAudience returns the deliverable subscriptions that receive the
+// emails of the task: the ones of the campaign's segment, or else of the
+// project's audience segment, or else all of them. A segment that can't
+// be found fails the task instead of reaching the whole list.
+func (w *Watcher) loadAudience(pr *project.Project, c *campaign.Campaign) ([]*subscription.Subscription, error) {
+	if c != nil && c.SegmentID != nil {
+		s, err := c.Segment()
+		if err != nil {
+			return nil, err
 		}
 
-		_log.Info("publish task is finished")
+		return s.Subscriptions()
 	}
 
-	return nil
+	s, err := pr.Audience()
+	if err != nil {
+		return nil, err
+	}
+
+	if s != nil {
+		return s.Subscriptions()
+	}
+
+	return pr.Subscriptions().Deliverable()
 }
 
-func (w *Watcher) sendEmail(s *subscription.Subscription, p *post.Post, et *template.EmailTemplate) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+// loadEmailTemplate returns the template of the emails sent by the task
+// and the issue number of its post. Campaigns use their own content,
+// compiled once for all emails, and aren't numbered issues.
+func (w *Watcher) loadEmailTemplate(pr *project.Project, c *campaign.Campaign, p *post.Post) (*template.EmailTemplate, int64, error) {
+	if c != nil {
+		et := c.EmailTemplate()
+
+		content, err := et.Compile(pr.EmailLayout)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		et.Format = template.FormatHTML
+		et.Content = content
 
-	// Build unique links for users to unsubscribe the newsletter
-	unsubscribeToken, err := s.Encrypt()
+		return et, 0, nil
+	}
+
+	et, err := pr.EmailTemplates().GetActive()
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	unsubscribeLink := url.URL{
-		Scheme: "https",
-		Host: config.C.ApplicationDomain,
-		Path: "unsubscribe",
-		RawQuery: fmt.Sprintf("token=%s", unsubscribeToken),
+	issue, err := p.IssueNumber()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return et, issue, nil
+}
+
+// publish delivers the post and updates the task status when it's done.
+// Rejected emails don't fail the task, since sending them again won't
+// help, but emails that exhausted their retries do.
+func (w *Watcher) publish(pb *publication) {
+	t, _log := pb.task, pb.log
+
+	w.runPublication(pb)
+
+	_log = _log.With(
+		zap.String("progress", t.Progress()),
+		zap.Int64("failed", t.Failed),
+		zap.Int64("rejected", t.Rejected),
+	)
+
+	t.Status = task.Finished
+	if t.Failed > 0 {
+		t.Status = task.Failed
+	}
+
+	if err := w.updateTask(t); err != nil {
+		_log.Error("Failed updating Task status.", zap.Error(err))
+		return
+	}
+
+	if t.Failed > 0 {
+		_log.Error("Publish task failed to send one or more emails.")
+		return
+	}
+
+	_log.Info("Publish task is finished.")
+}
+
+// updateTask stores the status of the task, which dry runs leave as it was
+func (w *Watcher) updateTask(t *task.Task) error {
+	if w.dryRun {
+		return nil
+	}
+
+	return t.Update()
+}
+
+// saveDelivery records the delivery in the ledger, except in dry runs
+func (w *Watcher) saveDelivery(d *delivery.Delivery) error {
+	if w.dryRun {
+		return nil
+	}
+
+	return d.Save()
+}
+
+// dryRunDelivered says if a dry run already delivered what the key
+// identifies, and remembers it as delivered otherwise. It's always false
+// outside of dry runs, where the ledger is the memory.
+func (w *Watcher) dryRunDelivered(key string) bool {
+	if !w.dryRun {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.dryRunSent[key] {
+		return true
 	}
 
-	// Create post items array to be processed
-	postItems, err := p.PostItems().All()
+	w.dryRunSent[key] = true
+
+	return false
+}
+
+// projectLimiter returns the rate limiter of the project, which is kept
+// between tasks while the project's delivery settings don't change
+func (w *Watcher) projectLimiter(pr *project.Project) *RateLimiter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	settings := pr.Settings.Delivery
+	if pl, ok := w.projectLimiters[pr.ID]; ok && pl.settings == settings {
+		return pl.limiter
+	}
+
+	limiter := NewRateLimiter(settings.RatePerSecond, settings.RatePerHour, settings.RatePerDay)
+	w.projectLimiters[pr.ID] = &projectLimiter{settings, limiter}
+
+	return limiter
+}
+
+// buildEmail renders the email of the publication for a single
+// subscription, with the items of the post it reads
+func (w *Watcher) buildEmail(pb *publication, s *subscription.Subscription) (*Email, error) {
+	pr := pb.project
+
+	postItems, err := readableItems(s, pb.postItems)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Items above the project's limit are replaced by a link
+	moreItems := 0
+	if maxItems := pr.Settings.Content.MaxItems; maxItems > 0 && len(postItems) > maxItems {
+		moreItems = len(postItems) - maxItems
+		postItems = postItems[:maxItems]
+	}
+
+	tplData := &template.Data{
+		Title: pb.post.Title,
+		Items: pb.dataItems(postItems),
+		MoreItems: moreItems,
+		MoreLink: buildMoreLink(pr),
+		BrowserLink: pb.browserLink,
+	}
+
+	return w.renderEmail(pr, s, pb.emailTemplate, tplData, pb.tracker)
+}
+
+// readableItems returns the items of a post the subscriber reads.
+// Subscribers only get the items of the topics they chose, and no
+// email at all when none of the post's items match.
+func readableItems(s *subscription.Subscription, allPostItems []*postitem.PostItem) ([]*postitem.PostItem, error) {
+	var postItems []*postitem.PostItem
+	for _, pi := range allPostItems {
+		if s.Reads(pi.Categories) {
+			postItems = append(postItems, pi)
+		}
+	}
+
+	if len(allPostItems) > 0 && len(postItems) == 0 {
+		return nil, errNothingToRead
 	}
 
+	return postItems, nil
+}
+
+// buildMoreLink returns where the items left out of an email because of
+// the project's limit can be read
+func buildMoreLink(pr *project.Project) string {
+	if pr.Settings.Content.MoreLink != "" {
+		return pr.Settings.Content.MoreLink
+	}
+
+	return pr.SiteURL()
+}
+
+// buildItems returns the template data of the post items, with their
+// content processed and their links tagged
+func (w *Watcher) buildItems(pr *project.Project, postItems []*postitem.PostItem, tagger *UTMTagger) ([]*template.DataItem, error) {
+	processor := NewPostProcessor(pr.Settings.Processing)
+
 	tplDataItems := []*template.DataItem{}
 	for _, pi := range postItems {
+		// relative URLs in the item content are relative to the item page,
+		// or to the site serving the feed if the item has no link
+		baseURL := pi.Link
+		if baseURL == "" {
+			baseURL = pr.FeedURL
+		}
+
+		content, err := processor.ProcessItemContent(pi.Content, baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if content, err = tagger.TagContent(content); err != nil {
+			return nil, err
+		}
+
 		item := &template.DataItem{
 			Title: pi.Title,
-			Link: pi.Link,
-			Content: pi.Content,
+			Link: tagger.TagURL(pi.Link),
+			Content: itemContent(pi, content),
+			Categories: pi.Categories,
 		}
 
 		tplDataItems = append(tplDataItems, item)
 	}
-	
-	tplData := &template.Data{
-		Title: p.Title,
-		UnsubscribeLink: unsubscribeLink.String(),
-		Items: tplDataItems,
+
+	return tplDataItems, nil
+}
+
+// itemContent returns the content of a post item as HTML, escaping the
+// content of the items scraped before it was sanitized
+func itemContent(pi *postitem.PostItem, content string) tpl.HTML {
+	if !pi.Sanitized {
+		return tpl.HTML(tpl.HTMLEscapeString(content))
+	}
+
+	return tpl.HTML(content)
+}
+
+// renderEmail fills the subscriber's fields of the template data and
+// renders the email of a single subscription
+func (w *Watcher) renderEmail(pr *project.Project, s *subscription.Subscription, et *template.EmailTemplate, tplData *template.Data, tracker *Tracker) (*Email, error) {
+	unsubscribeLink, err := buildUnsubscribeLink(s)
+	if err != nil {
+		return nil, err
 	}
 
+	preferencesLink, err := buildPreferencesLink(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := pr.Sender()
+
+	tplData.SenderName = sender.Name
+	tplData.UnsubscribeLink = unsubscribeLink
+	tplData.PreferencesLink = preferencesLink
+	tplData.Subscriber = newDataSubscriber(s)
+
 	// Build email to be sent
 	emailSubject, err := et.RenderSubject(tplData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	emailContent, err := et.RenderContent(tplData)
+	emailContent, err := et.RenderContent(pr.EmailLayout, tplData)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	processor := NewPostProcessor(pr.Settings.Processing)
+	if emailContent, err = processor.Process(emailContent); err != nil {
+		return nil, err
+	}
+
+	// subscribers who opted out receive emails without tracking
+	if !s.TrackingOptOut {
+		if emailContent, err = tracker.Track(emailContent, s.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if processor.ExceedsMaxSize(emailContent) {
+		log.L.Warn(
+			"Email content exceeds the maximum message size.",
+			zap.Int64("project_id", pr.ID),
+			zap.Int64("subscription_id", s.ID),
+			zap.Int("size", len(emailContent)),
+			zap.Int("max_size", pr.Settings.Processing.MaxMessageSize),
+		)
 	}
 
-	emailFrom := NewEmailAddress(config.C.PublisherName, config.C.PublisherEmail)
-	emailTo := NewEmailAddress("Reader", s.Email)
-	email := NewEmail(emailFrom, emailTo, emailSubject, emailContent)
+	return newProjectEmail(sender, s, emailSubject, emailContent), nil
+}
+
+// buildUnsubscribeLink builds the unique link for the subscriber to
+// unsubscribe the newsletter
+func buildUnsubscribeLink(s *subscription.Subscription) (string, error) {
+	return buildTokenLink(s, "unsubscribe")
+}
+
+// buildPreferencesLink builds the unique link for the subscriber to
+// choose the topics of the newsletter
+func buildPreferencesLink(s *subscription.Subscription) (string, error) {
+	return buildTokenLink(s, "preferences")
+}
+
+// buildTokenLink builds a link to a page of the application that
+// identifies the subscriber by its token
+func buildTokenLink(s *subscription.Subscription, path string) (string, error) {
+	token, err := s.Encrypt()
+	if err != nil {
+		return "", err
+	}
+
+	link := url.URL{
+		Scheme: "https",
+		Host: config.C.ApplicationDomain,
+		Path: path,
+		RawQuery: fmt.Sprintf("token=%s", token),
+	}
+
+	return link.String(), nil
+}
+
+// newDataSubscriber returns the template data of the subscriber
+func newDataSubscriber(s *subscription.Subscription) *template.DataSubscriber {
+	return &template.DataSubscriber{
+		Email: s.Email,
+		Name: s.Name,
+		FirstName: s.FirstName(),
+		Locale: s.Locale,
+		Timezone: s.Timezone,
+		Attributes: s.Attributes,
+	}
+}
+
+// newProjectEmail returns the email sent by the project's sender to
+// the subscription
+func newProjectEmail(sender *project.Sender, s *subscription.Subscription, subject, content string) *Email {
+	emailFrom := NewEmailAddress(sender.Name, sender.Email)
+	emailTo := NewEmailAddress(s.Name, s.Email)
+
+	email := NewEmail(emailFrom, emailTo, subject, content)
+	if sender.ReplyTo != "" {
+		email.ReplyTo = NewEmailAddress("", sender.ReplyTo)
+	}
 
-	return w.sender.Send(ctx, email)
+	return email
 }
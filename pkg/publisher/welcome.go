@@ -0,0 +1,200 @@
+package publisher
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/delivery"
+	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/template"
+)
+
+// watchWelcomes executes an infinite loop that keeps checking if there
+// are new subscriptions to be welcomed
+func (w *Watcher) watchWelcomes() {
+	_log := log.L.With(zap.String("watcher", "publisher"), zap.String("stage", "welcome"))
+	for {
+		time.Sleep(10 * time.Second)
+
+		if err := w.processWelcomes(); err != nil {
+			_log.Error("Failed processing welcome emails.", zap.Error(err))
+		}
+	}
+}
+
+// processWelcomes sends the welcome email of every project with an active
+// welcome template to its new subscriptions. The ledger has a single
+// welcome delivery per subscription, so nobody is welcomed twice, and
+// subscriptions older than WelcomeMaxAge are left out, so activating a
+// template doesn't welcome the whole list.
+func (w *Watcher) processWelcomes() error {
+	projects, err := project.NewProjects().AllEnabled()
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range projects {
+		_log := log.L.With(zap.Int64("project_id", pr.ID))
+
+		welcomeTemplate, err := pr.EmailTemplates().GetActiveOfKind(template.KindWelcome)
+		if err != nil {
+			_log.Error("Failed loading Project's welcome EmailTemplate. Skipping.", zap.Error(err))
+			continue
+		}
+
+		if welcomeTemplate == nil {
+			continue
+		}
+
+		subscriptions, err := pr.Subscriptions().AwaitingWelcome(config.C.WelcomeMaxAge)
+		if err != nil {
+			_log.Error("Failed loading Subscriptions awaiting welcome. Skipping.", zap.Error(err))
+			continue
+		}
+
+		if len(subscriptions) == 0 {
+			continue
+		}
+
+		recentPosts, err := w.buildRecentPosts(pr)
+		if err != nil {
+			_log.Error("Failed loading Project's recent Posts. Skipping.", zap.Error(err))
+			continue
+		}
+
+		limiter := w.projectLimiter(pr)
+
+		for _, s := range subscriptions {
+			if w.dryRunDelivered(fmt.Sprintf("welcome:%d", s.ID)) {
+				continue
+			}
+
+			d := delivery.New()
+			d.Kind = delivery.Welcome
+			d.SubscriptionID = s.ID
+			d.Status = delivery.Failed
+
+			email, err := w.buildWelcomeEmail(pr, s, welcomeTemplate, recentPosts)
+			if err != nil {
+				d.Error = err.Error()
+			} else {
+				w.sendWithRetries(d, email, limiter, _log)
+			}
+
+			_log := _log.With(zap.Int64("subscription_id", s.ID))
+
+			if err := w.saveDelivery(d); err != nil {
+				_log.Error("Failed saving Delivery.", zap.Error(err))
+			}
+
+			if d.Status != delivery.Sent {
+				_log.Error(
+					"Failed sending welcome email.",
+					zap.String("error", d.Error),
+					zap.String("delivery_status", string(d.Status)),
+					zap.Int("attempts", d.Attempts),
+				)
+				continue
+			}
+
+			_log.Info("Welcome email sent.")
+		}
+	}
+
+	return nil
+}
+
+// buildRecentPosts returns the template data of the last posts of the
+// project, according to its welcome settings
+func (w *Watcher) buildRecentPosts(pr *project.Project) ([]*template.DataPost, error) {
+	n := pr.Settings.Welcome.RecentPosts
+	if n == 0 {
+		return nil, nil
+	}
+
+	posts, err := pr.Posts().Recent(n)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := NewPostProcessor(pr.Settings.Processing)
+
+	var dataPosts []*template.DataPost
+	for _, p := range posts {
+		postItems, err := p.PostItems().All()
+		if err != nil {
+			return nil, err
+		}
+
+		dataPost := &template.DataPost{Title: p.Title, Date: p.CreatedAt}
+
+		for _, pi := range postItems {
+			baseURL := pi.Link
+			if baseURL == "" {
+				baseURL = pr.FeedURL
+			}
+
+			content, err := processor.ProcessItemContent(pi.Content, baseURL)
+			if err != nil {
+				return nil, err
+			}
+
+			dataPost.Items = append(dataPost.Items, &template.DataItem{
+				Title:   pi.Title,
+				Link:    pi.Link,
+				Content: itemContent(pi, content),
+			})
+		}
+
+		dataPosts = append(dataPosts, dataPost)
+	}
+
+	return dataPosts, nil
+}
+
+// buildWelcomeEmail renders the welcome email of a single subscription.
+// The title of welcome emails is the name of the project.
+func (w *Watcher) buildWelcomeEmail(pr *project.Project, s *subscription.Subscription, et *template.EmailTemplate, recentPosts []*template.DataPost) (*Email, error) {
+	unsubscribeLink, err := buildUnsubscribeLink(s)
+	if err != nil {
+		return nil, err
+	}
+
+	preferencesLink, err := buildPreferencesLink(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := pr.Sender()
+
+	tplData := &template.Data{
+		Title:           pr.Name,
+		SenderName:      sender.Name,
+		UnsubscribeLink: unsubscribeLink,
+		PreferencesLink: preferencesLink,
+		RecentPosts:     recentPosts,
+		Subscriber:      newDataSubscriber(s),
+	}
+
+	emailSubject, err := et.RenderSubject(tplData)
+	if err != nil {
+		return nil, err
+	}
+
+	emailContent, err := et.RenderContent(pr.EmailLayout, tplData)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := NewPostProcessor(pr.Settings.Processing)
+	if emailContent, err = processor.Process(emailContent); err != nil {
+		return nil, err
+	}
+
+	return newProjectEmail(sender, s, emailSubject, emailContent), nil
+}
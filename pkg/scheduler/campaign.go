@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/campaign"
+	"go.uber.org/zap"
+)
+
+type CampaignScheduler struct{}
+
+func NewCampaignScheduler() *CampaignScheduler {
+	return &CampaignScheduler{}
+}
+
+// Start creates a go routine to queue campaigns when they're due
+func (s *CampaignScheduler) Start() (chan Signal, error) {
+	ch := make(chan Signal)
+
+	go s.startCampaignReconcileLoop(ch)
+
+	return ch, nil
+}
+
+// startCampaignReconcileLoop creates the Broadcast pipelines of the
+// campaigns whose schedule is due
+func (s *CampaignScheduler) startCampaignReconcileLoop(stop chan Signal) {
+	log.L.Info("campaign reconcile loop started")
+	for {
+		time.Sleep(10 * time.Second)
+
+		select {
+		case <-stop:
+			log.L.Info("campaign reconcile loop stopped")
+			return
+
+		default:
+			campaigns, err := campaign.NewCampaigns().Due()
+			if err != nil {
+				log.L.Error("campaign reconcile loop failed to get due campaigns", zap.Error(err))
+				continue
+			}
+
+			for _, c := range campaigns {
+				_log := log.L.With(zap.Int64("project_id", c.ProjectID), zap.Int64("campaign_id", c.ID))
+
+				if err := c.Queue(); err != nil {
+					_log.Error("failed queueing campaign", zap.Error(err))
+					continue
+				}
+
+				_log.Info("campaign queued", zap.Int64("pipeline_id", *c.PipelineID))
+			}
+		}
+	}
+}
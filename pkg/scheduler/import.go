@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/importer"
+	"go.uber.org/zap"
+)
+
+type ImportScheduler struct{}
+
+func NewImportScheduler() *ImportScheduler {
+	return &ImportScheduler{}
+}
+
+// Start creates a go routine to run the imports waiting in the queue
+func (s *ImportScheduler) Start() (chan Signal, error) {
+	ch := make(chan Signal)
+
+	if err := importer.NewImports().FailRunning(); err != nil {
+		log.L.Error("failed marking interrupted imports as failed", zap.Error(err))
+	}
+
+	go s.startImportLoop(ch)
+
+	return ch, nil
+}
+
+// startImportLoop runs the waiting imports one at a time, the oldest
+// first
+func (s *ImportScheduler) startImportLoop(stop chan Signal) {
+	log.L.Info("import loop started")
+	for {
+		time.Sleep(10 * time.Second)
+
+		select {
+		case <-stop:
+			log.L.Info("import loop stopped")
+			return
+
+		default:
+			for {
+				i, err := importer.NewImports().Next()
+				if err != nil {
+					log.L.Error("import loop failed to get next import", zap.Error(err))
+					break
+				}
+
+				if i == nil {
+					break
+				}
+
+				_log := log.L.With(zap.Int64("project_id", i.ProjectID), zap.Int64("import_id", i.ID))
+				_log.Info("import started", zap.Bool("dry_run", i.Options.DryRun))
+
+				if err := i.Run(); err != nil {
+					_log.Error("failed updating import", zap.Error(err))
+					break
+				}
+
+				if i.Status == importer.Failed {
+					_log.Error("import failed", zap.String("error", i.Error))
+					continue
+				}
+
+				_log.Info(
+					"import finished",
+					zap.String("progress", i.Progress()),
+					zap.Int64("created", i.Created),
+					zap.Int64("updated", i.Updated),
+					zap.Int64("duplicates", i.Duplicates),
+					zap.Int64("invalid", i.Invalid),
+					zap.Int64("suppressed", i.Suppressed),
+					zap.Int64("failed", i.Failed),
+				)
+			}
+		}
+	}
+}
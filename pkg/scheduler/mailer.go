@@ -1,6 +1,12 @@
 package scheduler
 
-import "github.com/statictask/newsletter/pkg/publisher"
+import (
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/publisher"
+	"github.com/statictask/newsletter/pkg/task"
+)
 
 type PublisherJobScheduler struct{}
 
@@ -10,6 +16,16 @@ func NewPublisherJobScheduler() *PublisherJobScheduler {
 
 // Start creates a go routine to reconcile pipeline's tasks
 func (s *PublisherJobScheduler) Start() {
-	job := publisher.New()
+	job, err := publisher.New()
+	if err != nil {
+		log.L.Fatal("unable to start publisher", zap.Error(err))
+	}
+
+	// publications interrupted by a restart are sent again, skipping the
+	// subscriptions in the deliveries ledger
+	if err := task.NewTasks().RetryRunning(task.Publish); err != nil {
+		log.L.Error("failed retrying interrupted publish tasks", zap.Error(err))
+	}
+
 	go job.Run()
 }
@@ -0,0 +1,50 @@
+package scrapper
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/pkg/postitem"
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+// SanitizeLegacyItems sanitizes the content of the items scraped before
+// it was sanitized, with the sanitizer settings of their projects. Items
+// that fail keep being escaped when they are sent.
+func SanitizeLegacyItems() error {
+	projects, err := project.NewProjects().All()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range projects {
+		items, err := postitem.NewProjectPostItems(p.ID).Unsanitized()
+		if err != nil {
+			return err
+		}
+
+		_log := log.L.With(zap.Int64("project_id", p.ID))
+		sanitizer := NewSanitizer(p.Settings.Sanitizer)
+
+		sanitized := 0
+		for _, pi := range items {
+			content, err := sanitizer.Sanitize(pi.Content)
+			if err != nil {
+				_log.Error("failed sanitizing legacy post item content", zap.Error(err), zap.Int64("post_item_id", pi.ID))
+				continue
+			}
+
+			if err := pi.SetSanitizedContent(content); err != nil {
+				return err
+			}
+
+			sanitized++
+		}
+
+		if sanitized > 0 {
+			_log.Info("sanitized legacy post items", zap.Int("items", sanitized))
+		}
+	}
+
+	return nil
+}
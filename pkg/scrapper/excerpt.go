@@ -0,0 +1,209 @@
+package scrapper
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+var wordRegexp = regexp.MustCompile(`\S+`)
+
+// blockTags are the elements counted as paragraphs when excerpting
+var blockTags = map[string]bool{
+	"p": true, "ul": true, "ol": true, "dl": true, "blockquote": true,
+	"pre": true, "table": true, "figure": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// containerTags are the elements that wrap other blocks, they're only
+// counted as paragraphs when they hold inline content
+var containerTags = map[string]bool{
+	"div": true, "article": true, "section": true, "main": true,
+	"header": true, "footer": true, "aside": true,
+}
+
+// Excerpter reduces the content of feed items according to the
+// content mode of the project
+type Excerpter struct {
+	settings project.ContentSettings
+}
+
+// NewExcerpter returns an Excerpter for the given settings
+func NewExcerpter(settings project.ContentSettings) *Excerpter {
+	return &Excerpter{settings}
+}
+
+// Excerpt returns the part of the item that is sent to subscribers. Both
+// content and summary must be already sanitized. A "Read more" link to
+// the item is appended whenever the content isn't complete.
+func (e *Excerpter) Excerpt(content, summary, link string) (string, error) {
+	var excerpt string
+	var truncated bool
+	var err error
+
+	switch e.settings.Mode {
+	case project.ContentTitle:
+		return "", nil
+
+	case project.ContentSummary:
+		if strings.TrimSpace(summary) != "" {
+			excerpt, truncated = summary, summary != content
+			break
+		}
+
+		// feeds without descriptions fall back to the first words
+		excerpt, truncated, err = truncateWords(content, e.settings.Words)
+
+	case project.ContentWords:
+		excerpt, truncated, err = truncateWords(content, e.settings.Words)
+
+	case project.ContentParagraphs:
+		excerpt, truncated, err = truncateParagraphs(content, e.settings.Paragraphs)
+
+	default:
+		excerpt = content
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if truncated && link != "" && e.settings.ReadMoreText != "" {
+		excerpt += fmt.Sprintf(
+			`<p><a href="%s">%s</a></p>`,
+			html.EscapeString(link),
+			html.EscapeString(e.settings.ReadMoreText),
+		)
+	}
+
+	return excerpt, nil
+}
+
+// truncateWords keeps the first n words of the HTML content, closing
+// every tag that was open at the cut
+func truncateWords(content string, n int) (string, bool, error) {
+	root, err := parseFragment(content)
+	if err != nil {
+		return "", false, err
+	}
+
+	count := 0
+	truncated := false
+	var last *nethtml.Node
+
+	var walk func(*nethtml.Node)
+	walk = func(parent *nethtml.Node) {
+		for c := parent.FirstChild; c != nil; {
+			next := c.NextSibling
+
+			if truncated {
+				parent.RemoveChild(c)
+				c = next
+				continue
+			}
+
+			switch c.Type {
+			case nethtml.TextNode:
+				words := wordRegexp.FindAllStringIndex(c.Data, -1)
+				if count+len(words) > n {
+					truncated = true
+
+					// the limit was reached exactly at the previous text
+					if keep := n - count; keep == 0 && last != nil {
+						last.Data = strings.TrimRight(last.Data, " \t\r\n") + "…"
+						parent.RemoveChild(c)
+						c = next
+						continue
+					} else if keep > 0 {
+						c.Data = c.Data[:words[keep-1][1]] + "…"
+					}
+				}
+
+				count += len(words)
+				if len(words) > 0 {
+					last = c
+				}
+
+			case nethtml.ElementNode:
+				walk(c)
+			}
+
+			c = next
+		}
+	}
+
+	walk(root)
+
+	if !truncated {
+		return content, false, nil
+	}
+
+	excerpt, err := renderFragment(root)
+	return excerpt, true, err
+}
+
+// truncateParagraphs keeps the first n block elements of the HTML content.
+// Blocks are counted in document order, so content wrapped in containers
+// such as <div> or <article> is truncated as well.
+func truncateParagraphs(content string, n int) (string, bool, error) {
+	root, err := parseFragment(content)
+	if err != nil {
+		return "", false, err
+	}
+
+	blocks := collectBlocks(root, nil)
+	if len(blocks) <= n || n < 1 {
+		return content, false, nil
+	}
+
+	// everything after the last kept block goes, along with what follows
+	// each of the containers it's in
+	for c := blocks[n-1]; c != root; c = c.Parent {
+		for c.NextSibling != nil {
+			c.Parent.RemoveChild(c.NextSibling)
+		}
+	}
+
+	excerpt, err := renderFragment(root)
+	return excerpt, true, err
+}
+
+// collectBlocks appends the block elements under the parent in document
+// order. Containers holding other blocks are descended into instead of
+// being counted as a single block.
+func collectBlocks(parent *nethtml.Node, blocks []*nethtml.Node) []*nethtml.Node {
+	for c := parent.FirstChild; c != nil; c = c.NextSibling {
+		if !isBlock(c) {
+			continue
+		}
+
+		if containerTags[c.Data] && hasBlockAfter(c.FirstChild) {
+			blocks = collectBlocks(c, blocks)
+		} else {
+			blocks = append(blocks, c)
+		}
+	}
+
+	return blocks
+}
+
+// isBlock says if the node is a block element
+func isBlock(n *nethtml.Node) bool {
+	return n.Type == nethtml.ElementNode && (blockTags[n.Data] || containerTags[n.Data])
+}
+
+// hasBlockAfter says if there are more block elements from the given node on
+func hasBlockAfter(n *nethtml.Node) bool {
+	for ; n != nil; n = n.NextSibling {
+		if isBlock(n) {
+			return true
+		}
+	}
+
+	return false
+}
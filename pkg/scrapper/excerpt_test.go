@@ -0,0 +1,134 @@
+package scrapper
+
+import (
+	"testing"
+
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+func TestTruncateParagraphs(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		n             int
+		want          string
+		wantTruncated bool
+	}{
+		{
+			name:          "top level",
+			content:       "<p>one</p><p>two</p><p>three</p>",
+			n:             2,
+			want:          "<p>one</p><p>two</p>",
+			wantTruncated: true,
+		},
+		{
+			name:    "exact",
+			content: "<p>one</p><p>two</p>",
+			n:       2,
+			want:    "<p>one</p><p>two</p>",
+		},
+		{
+			name:    "shorter",
+			content: "<p>one</p>",
+			n:       3,
+			want:    "<p>one</p>",
+		},
+		{
+			name:          "wrapped in a div",
+			content:       "<div><p>one</p><p>two</p><p>three</p></div>",
+			n:             1,
+			want:          "<div><p>one</p></div>",
+			wantTruncated: true,
+		},
+		{
+			name:          "nested containers",
+			content:       "<article><section><h2>title</h2><p>one</p></section><section><p>two</p></section></article>",
+			n:             2,
+			want:          "<article><section><h2>title</h2><p>one</p></section></article>",
+			wantTruncated: true,
+		},
+		{
+			name:          "text after the cut",
+			content:       "<div><p>one</p>tail<p>two</p></div>after",
+			n:             1,
+			want:          "<div><p>one</p></div>",
+			wantTruncated: true,
+		},
+		{
+			name:          "div with inline content",
+			content:       "<div>one <b>bold</b></div><div>two</div>",
+			n:             1,
+			want:          "<div>one <b>bold</b></div>",
+			wantTruncated: true,
+		},
+		{
+			name:          "lists are a single block",
+			content:       "<ul><li>a</li><li>b</li></ul><p>after</p>",
+			n:             1,
+			want:          "<ul><li>a</li><li>b</li></ul>",
+			wantTruncated: true,
+		},
+		{
+			name:    "no blocks",
+			content: "just text",
+			n:       1,
+			want:    "just text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated, err := truncateParagraphs(tt.content, tt.n)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want || truncated != tt.wantTruncated {
+				t.Fatalf("truncateParagraphs = (%s, %v), want (%s, %v)", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		n             int
+		want          string
+		wantTruncated bool
+	}{
+		{"shorter", "<p>one two</p>", 3, "<p>one two</p>", false},
+		{"inside a text", "<p>one two three</p>", 2, "<p>one two…</p>", true},
+		{"closes tags", "<div><p>one <b>two three</b></p><p>four</p></div>", 2, "<div><p>one <b>two…</b></p></div>", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated, err := truncateWords(tt.content, tt.n)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want || truncated != tt.wantTruncated {
+				t.Fatalf("truncateWords = (%s, %v), want (%s, %v)", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	const content = "<div><p>one</p><p>two</p></div>"
+
+	settings := project.ContentSettings{Mode: project.ContentParagraphs, Paragraphs: 1, ReadMoreText: "Read more"}
+
+	got, err := NewExcerpter(settings).Excerpt(content, "", "https://example.com/?a=1&b=2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `<div><p>one</p></div><p><a href="https://example.com/?a=1&amp;b=2">Read more</a></p>`
+	if got != want {
+		t.Fatalf("Excerpt = %s, want %s", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package scrapper
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/mmcdole/gofeed"
@@ -19,6 +20,7 @@ type FeedItem struct {
 	Content     string
 	Link        string
 	PubDate     *time.Time
+	Categories  []string
 }
 
 func NewFeedReader(url string) *FeedReader {
@@ -43,6 +45,7 @@ func (fr *FeedReader) ReadFrom(ctx context.Context, dateLimit *time.Time, isFirs
 				Content:     i.Content,
 				Link:        i.Link,
 				PubDate:     i.PublishedParsed,
+				Categories:  normalizeCategories(i.Categories),
 			}
 
 			items = append(items, item)
@@ -67,3 +70,22 @@ func (fi *FeedItem) GetContent() string {
 func (fi *FeedItem) GetLink() string {
 	return fi.Link
 }
+
+// normalizeCategories trims the categories of a feed item, removing
+// empty and repeated ones regardless of their case
+func normalizeCategories(categories []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if c == "" || seen[strings.ToLower(c)] {
+			continue
+		}
+
+		seen[strings.ToLower(c)] = true
+		normalized = append(normalized, c)
+	}
+
+	return normalized
+}
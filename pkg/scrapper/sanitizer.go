@@ -0,0 +1,251 @@
+package scrapper
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+var (
+	// defaultAllowedTags are kept in feed items content unless the project
+	// defines its own list
+	defaultAllowedTags = []string{
+		"a", "abbr", "b", "blockquote", "br", "caption", "cite", "code",
+		"dd", "del", "dfn", "div", "dl", "dt", "em", "figcaption", "figure",
+		"h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img", "ins", "kbd",
+		"li", "mark", "ol", "p", "pre", "q", "s", "samp", "small", "span",
+		"strike", "strong", "sub", "sup", "table", "tbody", "td", "tfoot",
+		"th", "thead", "tr", "u", "ul",
+	}
+
+	// defaultAllowedAttributes are kept in allowed tags unless the project
+	// defines its own list
+	defaultAllowedAttributes = []string{
+		"href", "src", "alt", "title", "width", "height", "colspan",
+		"rowspan", "align", "cite", "datetime", "lang", "dir",
+	}
+
+	// droppedTags are removed along with their content, while other tags
+	// that aren't allowed are replaced by their content
+	droppedTags = map[string]bool{
+		"script": true, "noscript": true, "template": true, "object": true,
+		"embed": true, "applet": true, "form": true, "input": true,
+		"button": true, "select": true, "textarea": true, "svg": true,
+		"math": true, "head": true, "title": true, "meta": true, "link": true,
+		"base": true, "frame": true, "frameset": true,
+	}
+
+	// urlAttributes must contain URLs with one of the allowedSchemes
+	urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+	allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+	unsafeStyleRegexp = regexp.MustCompile(`(?i)expression\s*\(|javascript:|vbscript:|behavior\s*:|-moz-binding`)
+)
+
+// Sanitizer cleans the HTML content of feed items before it's stored
+// and sent to subscribers
+type Sanitizer struct {
+	policy       project.SanitizerSettings
+	allowedTags  map[string]bool
+	allowedAttrs map[string]bool
+}
+
+// NewSanitizer returns a Sanitizer that follows the given policy
+func NewSanitizer(policy project.SanitizerSettings) *Sanitizer {
+	tags := policy.AllowedTags
+	if len(tags) == 0 {
+		tags = defaultAllowedTags
+	}
+
+	attrs := policy.AllowedAttributes
+	if len(attrs) == 0 {
+		attrs = defaultAllowedAttributes
+	}
+
+	s := &Sanitizer{
+		policy:       policy,
+		allowedTags:  map[string]bool{},
+		allowedAttrs: map[string]bool{},
+	}
+
+	for _, t := range tags {
+		s.allowedTags[strings.ToLower(t)] = true
+	}
+
+	for _, a := range attrs {
+		s.allowedAttrs[strings.ToLower(a)] = true
+	}
+
+	if !policy.StripStyles {
+		s.allowedAttrs["style"] = true
+	}
+
+	if !policy.StripIframes {
+		s.allowedTags["iframe"] = true
+	}
+
+	return s
+}
+
+// Sanitize returns the content without the tags and attributes
+// forbidden by the policy
+func (s *Sanitizer) Sanitize(content string) (string, error) {
+	root, err := parseFragment(content)
+	if err != nil {
+		return "", err
+	}
+
+	// the <body> root isn't allowed, so it's replaced by its clean children
+	sanitized := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range s.clean(root) {
+		sanitized.AppendChild(n)
+	}
+
+	return renderFragment(sanitized)
+}
+
+// clean returns the nodes that replace n in the sanitized document
+func (s *Sanitizer) clean(n *html.Node) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{n}
+	case html.ElementNode:
+	default:
+		// comments, doctypes and others are always removed
+		return nil
+	}
+
+	tag := strings.ToLower(n.Data)
+	if s.isDropped(n, tag) {
+		return nil
+	}
+
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		children = append(children, s.clean(c)...)
+		c = next
+	}
+
+	// tags that aren't allowed are replaced by their content
+	if !s.allowedTags[tag] {
+		return children
+	}
+
+	n.Attr = s.cleanAttributes(n.Attr)
+	for _, c := range children {
+		n.AppendChild(c)
+	}
+
+	return []*html.Node{n}
+}
+
+// isDropped says if the element must be removed along with its content
+func (s *Sanitizer) isDropped(n *html.Node, tag string) bool {
+	switch {
+	case droppedTags[tag]:
+		return true
+	case tag == "style":
+		// <style> blocks are never allowed in feed items, styles are kept
+		// only as attributes because they'd affect the whole email
+		return true
+	case tag == "iframe":
+		return s.policy.StripIframes
+	case tag == "img":
+		return s.policy.RemoveImages || isTrackingPixel(n)
+	}
+
+	return false
+}
+
+// cleanAttributes keeps only allowed attributes with safe values
+func (s *Sanitizer) cleanAttributes(attrs []html.Attribute) []html.Attribute {
+	var clean []html.Attribute
+
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !s.allowedAttrs[key] {
+			continue
+		}
+
+		if urlAttributes[key] && !isSafeURL(a.Val) {
+			continue
+		}
+
+		if key == "style" && unsafeStyleRegexp.MatchString(a.Val) {
+			continue
+		}
+
+		clean = append(clean, html.Attribute{Key: key, Val: a.Val})
+	}
+
+	return clean
+}
+
+// isSafeURL accepts relative URLs and absolute ones using allowed schemes
+func isSafeURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "" || allowedSchemes[strings.ToLower(u.Scheme)]
+}
+
+// isTrackingPixel detects images of 1x1 pixels or smaller, commonly used
+// by feeds to track who reads their content
+func isTrackingPixel(n *html.Node) bool {
+	small := func(v string) bool {
+		v = strings.TrimSuffix(strings.TrimSpace(v), "px")
+		return v == "0" || v == "1"
+	}
+
+	var width, height string
+	for _, a := range n.Attr {
+		switch strings.ToLower(a.Key) {
+		case "width":
+			width = a.Val
+		case "height":
+			height = a.Val
+		}
+	}
+
+	return small(width) && small(height)
+}
+
+// parseFragment parses HTML content as children of a <body> element
+func parseFragment(content string) (*html.Node, error) {
+	root := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+
+	nodes, err := html.ParseFragment(strings.NewReader(content), root)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing content: %v", err)
+	}
+
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	return root, nil
+}
+
+// renderFragment renders the children of a node built by parseFragment
+func renderFragment(root *html.Node) (string, error) {
+	var out bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&out, c); err != nil {
+			return "", fmt.Errorf("failed rendering content: %v", err)
+		}
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,119 @@
+package scrapper
+
+import (
+	"testing"
+
+	"github.com/statictask/newsletter/pkg/project"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  project.SanitizerSettings
+		content string
+		want    string
+	}{
+		{
+			name:    "allowed content",
+			content: `<p>Hello <a href="https://example.com" title="x">world</a></p>`,
+			want:    `<p>Hello <a href="https://example.com" title="x">world</a></p>`,
+		},
+		{
+			name:    "scripts",
+			content: `<p>one</p><script>alert(1)</script><noscript>two</noscript>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "style blocks",
+			content: `<style>body { color: red }</style><p>one</p>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "unknown tags keep their content",
+			content: `<section><p>one <font color="red">two</font></p></section>`,
+			want:    `<p>one two</p>`,
+		},
+		{
+			name:    "event handlers",
+			content: `<p onclick="alert(1)" class="x">one</p>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "javascript URLs",
+			content: `<a href="javascript:alert(1)">one</a><a href=" JavaScript:alert(1)">two</a>`,
+			want:    `<a>one</a><a>two</a>`,
+		},
+		{
+			name:    "relative and mailto URLs",
+			content: `<a href="/post">one</a><a href="mailto:jane@example.com">two</a>`,
+			want:    `<a href="/post">one</a><a href="mailto:jane@example.com">two</a>`,
+		},
+		{
+			name:    "unsafe images",
+			content: `<img src="data:image/png;base64,AAAA" alt="x">`,
+			want:    `<img alt="x"/>`,
+		},
+		{
+			name:    "styles",
+			content: `<p style="color: red">one</p><p style="width: expression(alert(1))">two</p>`,
+			want:    `<p style="color: red">one</p><p>two</p>`,
+		},
+		{
+			name:    "stripped styles",
+			policy:  project.SanitizerSettings{StripStyles: true},
+			content: `<p style="color: red">one</p>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "iframes",
+			content: `<iframe src="https://example.com/video"></iframe>`,
+			want:    `<iframe src="https://example.com/video"></iframe>`,
+		},
+		{
+			name:    "stripped iframes",
+			policy:  project.SanitizerSettings{StripIframes: true},
+			content: `<p>one</p><iframe src="https://example.com/video"></iframe>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "tracking pixels",
+			content: `<img src="https://example.com/a.png" width="600"><img src="https://example.com/p.gif" width="1" height="1px">`,
+			want:    `<img src="https://example.com/a.png" width="600"/>`,
+		},
+		{
+			name:    "removed images",
+			policy:  project.SanitizerSettings{RemoveImages: true},
+			content: `<p>one<img src="https://example.com/a.png"></p>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "comments",
+			content: `<p>one<!-- secret --></p>`,
+			want:    `<p>one</p>`,
+		},
+		{
+			name:    "custom allowlists",
+			policy:  project.SanitizerSettings{AllowedTags: []string{"P", "a"}, AllowedAttributes: []string{"HREF"}},
+			content: `<p><b>one</b> <a href="https://example.com" title="x">two</a></p>`,
+			want:    `<p>one <a href="https://example.com">two</a></p>`,
+		},
+		{
+			name:    "text is escaped",
+			content: `<p>1 &lt; 2 &amp; <b>3</b></p>`,
+			want:    `<p>1 &lt; 2 &amp; <b>3</b></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSanitizer(tt.policy).Sanitize(tt.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("Sanitize = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
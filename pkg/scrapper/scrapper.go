@@ -148,12 +148,39 @@ func (s *Scrapper) processReadyTasks() error {
 		_log = _log.With(zap.Int64("post_id", newPost.ID))
 		_log.Info("successfully created feed post")
 
+		sanitizer := NewSanitizer(taskProject.Settings.Sanitizer)
+		excerpter := NewExcerpter(taskProject.Settings.Content)
+
+		// items whose content can't be sanitized are kept with their
+		// title and link only, and counted as failed in the task
+		t.Failed = 0
 		for _, i := range items {
+			content, err := sanitizer.Sanitize(i.GetContent())
+			if err != nil {
+				_log.Error("failed sanitizing post item content, keeping its title and link", zap.Error(err), zap.String("link", i.Link))
+				t.Failed++
+				content = ""
+			}
+
+			summary, err := sanitizer.Sanitize(i.Description)
+			if err != nil {
+				_log.Error("failed sanitizing post item description", zap.Error(err), zap.String("link", i.Link))
+				summary = ""
+			}
+
+			if excerpt, err := excerpter.Excerpt(content, summary, i.Link); err != nil {
+				_log.Error("failed excerpting post item content, keeping all of it", zap.Error(err), zap.String("link", i.Link))
+			} else {
+				content = excerpt
+			}
+
 			newPostItem := postitem.New()
 			newPostItem.PostID = newPost.ID
 			newPostItem.Title = i.Title
 			newPostItem.Link = i.Link
-			newPostItem.Content = i.Content
+			newPostItem.Content = content
+			newPostItem.OriginalContent = i.GetContent()
+			newPostItem.Categories = i.Categories
 
 			if err := newPostItem.Create(); err != nil {
 				_log.Error("failed creating new post item", zap.Error(err))
@@ -161,6 +188,12 @@ func (s *Scrapper) processReadyTasks() error {
 			}
 		}
 
+		if t.Failed > 0 {
+			if err := t.UpdateProgress(); err != nil {
+				_log.Error("failed recording post items that couldn't be sanitized", zap.Error(err))
+			}
+		}
+
 		t.Status = task.Finished
 		if err := t.Update(); err != nil {
 			_log.Error("failed building feed content", zap.Error(err))
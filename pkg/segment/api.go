@@ -0,0 +1,241 @@
+package segment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+// CreateSegment creates a segment in the project
+func CreateSegment(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	controller := NewProjectSegments(int64(projectID))
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	s := New()
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.Validate(); err != nil {
+		_log.Error("Invalid Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.Add(s); err != nil {
+		_log.Error("Failed adding new Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Segment created successfully.", zap.Int64("segment_id", s.ID))
+	utils.WriteJSONResponseData(w, http.StatusOK, s)
+}
+
+// GetSegments returns the segments of the project
+func GetSegments(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	segments, err := NewProjectSegments(int64(projectID)).All()
+	if err != nil {
+		_log.Error("Failed loading Segments.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, segments)
+}
+
+// GetSegment returns a single segment of the project
+func GetSegment(w http.ResponseWriter, r *http.Request) {
+	s, _log, ok := loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	_log.Info("Segment retrieved successfully.")
+	utils.WriteJSONResponseData(w, http.StatusOK, s)
+}
+
+// UpdateSegment changes the name or the rules of a segment
+func UpdateSegment(w http.ResponseWriter, r *http.Request) {
+	s, _log, ok := loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.Validate(); err != nil {
+		_log.Error("Invalid Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.Update(); err != nil {
+		_log.Error("Failed updating Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Segment updated successfully.")
+	utils.WriteJSONResponseData(w, http.StatusOK, s)
+}
+
+// DeleteSegment deletes a segment that isn't the audience of the project
+// or of a campaign that is still being sent
+func DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	s, _log, ok := loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	inUse, err := s.IsInUse()
+	if err != nil {
+		_log.Error("Failed deleting Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if inUse {
+		err := fmt.Errorf("segment %d is the audience of the project or of a campaign that wasn't sent yet", s.ID)
+		_log.Error("Failed deleting Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusConflict, err)
+		return
+	}
+
+	if err := s.Delete(); err != nil {
+		_log.Error("Failed deleting Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Segment deleted successfully.")
+	msg := "Segment deleted successfully."
+	utils.WriteJSONResponseMessage(w, http.StatusNoContent, msg)
+}
+
+// PreviewSegment counts the subscriptions of a saved segment
+func PreviewSegment(w http.ResponseWriter, r *http.Request) {
+	s, _log, ok := loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := NewProjectSegments(s.ProjectID).Preview(&s.Rules)
+	if err != nil {
+		_log.Error("Failed previewing Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, count)
+}
+
+// PreviewRules counts the subscriptions matched by rules that weren't
+// saved yet
+func PreviewRules(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	rules := &Rules{Match: MatchAll}
+	if err := json.NewDecoder(r.Body).Decode(rules); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := rules.Validate(); err != nil {
+		_log.Error("Invalid Segment rules.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	count, err := NewProjectSegments(int64(projectID)).Preview(rules)
+	if err != nil {
+		_log.Error("Failed previewing Segment rules.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, count)
+}
+
+// loadSegment loads the segment of the request URL, writing the error
+// response when it fails
+func loadSegment(w http.ResponseWriter, r *http.Request) (*Segment, *zap.Logger, bool) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	segmentID, err := strconv.Atoi(params["segment_id"])
+	if err != nil {
+		_log.Error("Failed parsing segment_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+
+	_log = _log.With(zap.Int64("segment_id", int64(segmentID)))
+
+	s, err := NewProjectSegments(int64(projectID)).Get(int64(segmentID))
+	if err != nil {
+		_log.Error("Failed getting Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+
+	if s == nil {
+		err := fmt.Errorf("segment %d not found", segmentID)
+		_log.Error("Failed getting Segment.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return nil, nil, false
+	}
+
+	return s, _log, true
+}
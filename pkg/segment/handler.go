@@ -0,0 +1,226 @@
+package segment
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// insertSegment inserts a segment in the database
+func insertSegment(s *Segment) error {
+	query := `
+		INSERT INTO segments (
+		  project_id,
+		  name,
+		  rules
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3
+		)
+		RETURNING
+		  segment_id,
+		  project_id,
+		  name,
+		  rules,
+		  created_at,
+		  updated_at
+	`
+
+	savedSegment, err := scanSegment(query, s.ProjectID, s.Name, s.Rules)
+	if err != nil {
+		return err
+	}
+
+	*s = *savedSegment
+
+	return nil
+}
+
+// updateSegment updates the name and the rules of a segment in the
+// database
+func updateSegment(s *Segment) error {
+	query := `
+		UPDATE
+		  segments
+		SET
+		  name = $1,
+		  rules = $2
+		WHERE
+		  segment_id = $3
+		  AND project_id = $4
+		RETURNING
+		  segment_id,
+		  project_id,
+		  name,
+		  rules,
+		  created_at,
+		  updated_at
+	`
+
+	savedSegment, err := scanSegment(query, s.Name, s.Rules, s.ID, s.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	if savedSegment == nil {
+		return fmt.Errorf("segment %d not found", s.ID)
+	}
+
+	*s = *savedSegment
+
+	return nil
+}
+
+// deleteSegment deletes a segment from the database
+func deleteSegment(segmentID, projectID int64) error {
+	query := `DELETE FROM segments WHERE segment_id = $1 AND project_id = $2`
+
+	if err := database.Exec(query, segmentID, projectID); err != nil {
+		return fmt.Errorf("unable to delete segment: %v", err)
+	}
+
+	return nil
+}
+
+// isSegmentInUse says if the segment is the audience of its project or
+// of a campaign that wasn't completely sent yet
+func isSegmentInUse(segmentID, projectID int64) (bool, error) {
+	query := `
+		SELECT
+		  EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      campaigns AS c
+		    LEFT JOIN tasks AS t
+		      ON t.pipeline_id = c.pipeline_id
+		    WHERE
+		      c.segment_id = $1
+		      AND (
+		        c.pipeline_id IS NULL
+		        OR t.task_status IN ('Waiting', 'Ready', 'Running')
+		      )
+		  )
+		  OR EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      projects
+		    WHERE
+		      project_id = $2
+		      AND settings->'audience'->>'segment_id' = $1::TEXT
+		  )
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return false, err
+	}
+
+	defer db.Close()
+
+	var inUse bool
+	if err := db.QueryRow(query, segmentID, projectID).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("unable to scan segment usage: %v", err)
+	}
+
+	return inUse, nil
+}
+
+// getSegment returns a single segment of the project
+func getSegment(projectID, segmentID int64) (*Segment, error) {
+	query := `
+		SELECT
+		  segment_id,
+		  project_id,
+		  name,
+		  rules,
+		  created_at,
+		  updated_at
+		FROM
+		  segments
+		WHERE
+		  project_id = $1
+		  AND segment_id = $2
+	`
+
+	return scanSegment(query, projectID, segmentID)
+}
+
+// getSegmentsByProjectID returns the segments of the project
+func getSegmentsByProjectID(projectID int64) ([]*Segment, error) {
+	query := `
+		SELECT
+		  segment_id,
+		  project_id,
+		  name,
+		  rules,
+		  created_at,
+		  updated_at
+		FROM
+		  segments
+		WHERE
+		  project_id = $1
+		ORDER BY
+		  name
+	`
+
+	return scanSegments(query, projectID)
+}
+
+// scanSegment returns a single segment that matches the given query
+func scanSegment(query string, params ...interface{}) (*Segment, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	s := New()
+
+	if err := row.Scan(&s.ID, &s.ProjectID, &s.Name, &s.Rules, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan segment row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return s, nil
+}
+
+// scanSegments returns multiple segments that match the given query
+func scanSegments(query string, params ...interface{}) ([]*Segment, error) {
+	var segments []*Segment
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return segments, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		s := New()
+
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Name, &s.Rules, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return segments, fmt.Errorf("unable to scan a segment row: %v", err)
+		}
+
+		segments = append(segments, s)
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,57 @@
+package segment
+
+import (
+	"fmt"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+)
+
+// ProjectSegments is the entity used for controlling
+// interactions with the segments of a project
+type ProjectSegments struct {
+	projectID int64
+}
+
+// NewProjectSegments returns a ProjectSegments controller
+func NewProjectSegments(projectID int64) *ProjectSegments {
+	return &ProjectSegments{projectID}
+}
+
+// All returns the segments of the project
+func (ps *ProjectSegments) All() ([]*Segment, error) {
+	segments, err := getSegmentsByProjectID(ps.projectID)
+	if err != nil {
+		return segments, fmt.Errorf("unable to get segments: %v", err)
+	}
+
+	return segments, nil
+}
+
+// Get returns a single segment of the project
+func (ps *ProjectSegments) Get(segmentID int64) (*Segment, error) {
+	segment, err := getSegment(ps.projectID, segmentID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get segment: %v", err)
+	}
+
+	return segment, nil
+}
+
+// Add creates a new segment in the project
+func (ps *ProjectSegments) Add(s *Segment) error {
+	// make sure the segment has the correct ProjectID before adding
+	s.ProjectID = ps.projectID
+
+	return s.Create()
+}
+
+// Preview counts the subscriptions of the project matched by the rules,
+// so segments can be checked before anything is sent to them
+func (ps *ProjectSegments) Preview(r *Rules) (*subscription.Count, error) {
+	f, err := r.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription.NewProjectSubscriptions(ps.projectID).Count(f)
+}
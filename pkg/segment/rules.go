@@ -0,0 +1,386 @@
+package segment
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+)
+
+// Match says if subscriptions must match all the conditions of a segment
+// or any of them
+type Match string
+
+const (
+	MatchAll Match = "all"
+	MatchAny Match = "any"
+)
+
+// Operator compares a field of the subscriptions with the value of a
+// condition
+type Operator string
+
+const (
+	OpHas           Operator = "has"
+	OpNotHas        Operator = "not_has"
+	OpEquals        Operator = "eq"
+	OpNotEquals     Operator = "neq"
+	OpContains      Operator = "contains"
+	OpExists        Operator = "exists"
+	OpNotExists     Operator = "not_exists"
+	OpBefore        Operator = "before"
+	OpAfter         Operator = "after"
+	OpWithinDays    Operator = "within_days"
+	OpNotWithinDays Operator = "not_within_days"
+)
+
+// Statuses of the subscriptions, suppressed addresses bounced or
+// complained and don't receive emails
+const (
+	StatusActive     = "active"
+	StatusSuppressed = "suppressed"
+)
+
+// attributesPrefix selects custom fields, like in "attributes.plan"
+const attributesPrefix = "attributes."
+
+// textColumns are the subscription fields compared as text
+var textColumns = map[string]string{
+	"email":    "s.normalized_email",
+	"name":     "s.name",
+	"locale":   "s.locale",
+	"timezone": "s.timezone",
+}
+
+// engagementEvents are the tracking events of the engagement fields
+var engagementEvents = map[string]string{
+	"opened":  "open",
+	"clicked": "click",
+}
+
+// suppressedCondition matches the subscriptions whose address is
+// suppressed
+const suppressedCondition = `EXISTS (
+	SELECT 1 FROM suppressions AS sp WHERE sp.email = s.normalized_email
+)`
+
+// Rules select the subscriptions of a segment, rules without conditions
+// select every subscription of the project
+type Rules struct {
+	Match      Match        `json:"match"`
+	Conditions []*Condition `json:"conditions"`
+}
+
+// Condition compares a field of the subscriptions with a value. Fields are
+// "tag", "status", "email", "name", "locale", "timezone", "created_at",
+// the engagement fields "opened" and "clicked", and custom fields like
+// "attributes.plan".
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator Operator    `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// Validate checks if the rules can be converted into a filter
+func (r *Rules) Validate() error {
+	_, err := r.Filter()
+	return err
+}
+
+// Filter converts the rules into a subscription filter
+func (r *Rules) Filter() (*subscription.Filter, error) {
+	join := " AND "
+	switch r.Match {
+	case MatchAll, "":
+	case MatchAny:
+		join = " OR "
+	default:
+		return nil, fmt.Errorf("invalid match '%s', use '%s' or '%s'", r.Match, MatchAll, MatchAny)
+	}
+
+	b := &filterBuilder{}
+
+	var conditions []string
+	for i, c := range r.Conditions {
+		condition, err := c.sql(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %d: %v", i+1, err)
+		}
+
+		conditions = append(conditions, "("+condition+")")
+	}
+
+	return &subscription.Filter{
+		Condition: strings.Join(conditions, join),
+		Params:    b.params,
+	}, nil
+}
+
+// sql returns the SQL condition that compares the field with the value,
+// adding the parameters it needs to the builder
+func (c *Condition) sql(b *filterBuilder) (string, error) {
+	switch {
+	case c.Field == "tag":
+		return c.tagSQL(b)
+	case c.Field == "status":
+		return c.statusSQL()
+	case c.Field == "created_at":
+		return c.createdAtSQL(b)
+	case engagementEvents[c.Field] != "":
+		return c.engagementSQL(b)
+	case textColumns[c.Field] != "":
+		return c.textSQL(b, textColumns[c.Field])
+	case strings.HasPrefix(c.Field, attributesPrefix):
+		key := strings.TrimPrefix(c.Field, attributesPrefix)
+		if key == "" {
+			return "", fmt.Errorf("custom field name is missing in '%s'", c.Field)
+		}
+
+		return c.textSQL(b, fmt.Sprintf("(s.attributes->>%s::TEXT)", b.param(key)))
+	}
+
+	return "", fmt.Errorf("unknown field '%s'", c.Field)
+}
+
+// tagSQL matches the subscriptions that have or don't have a tag
+func (c *Condition) tagSQL(b *filterBuilder) (string, error) {
+	value, err := c.stringValue()
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := subscription.NormalizeTags([]string{value})
+	if err != nil {
+		return "", err
+	}
+
+	if len(tags) == 0 {
+		return "", fmt.Errorf("tag is required")
+	}
+
+	has := fmt.Sprintf("s.tags @> ARRAY[%s::TEXT]", b.param(tags[0]))
+
+	switch c.Operator {
+	case OpHas:
+		return has, nil
+	case OpNotHas:
+		return "NOT " + has, nil
+	}
+
+	return "", c.invalidOperator(OpHas, OpNotHas)
+}
+
+// statusSQL matches the subscriptions that are active or suppressed
+func (c *Condition) statusSQL() (string, error) {
+	value, err := c.stringValue()
+	if err != nil {
+		return "", err
+	}
+
+	var suppressed bool
+	switch value {
+	case StatusActive:
+	case StatusSuppressed:
+		suppressed = true
+	default:
+		return "", fmt.Errorf("invalid status '%s', use '%s' or '%s'", value, StatusActive, StatusSuppressed)
+	}
+
+	switch c.Operator {
+	case OpEquals:
+	case OpNotEquals:
+		suppressed = !suppressed
+	default:
+		return "", c.invalidOperator(OpEquals, OpNotEquals)
+	}
+
+	if suppressed {
+		return suppressedCondition, nil
+	}
+
+	return "NOT " + suppressedCondition, nil
+}
+
+// createdAtSQL matches the subscriptions by their signup date, either
+// absolute or relative to now
+func (c *Condition) createdAtSQL(b *filterBuilder) (string, error) {
+	switch c.Operator {
+	case OpBefore, OpAfter:
+		t, err := c.timeValue()
+		if err != nil {
+			return "", err
+		}
+
+		if c.Operator == OpBefore {
+			return fmt.Sprintf("s.created_at < %s", b.param(t)), nil
+		}
+
+		return fmt.Sprintf("s.created_at >= %s", b.param(t)), nil
+	case OpWithinDays, OpNotWithinDays:
+		days, err := c.daysValue()
+		if err != nil {
+			return "", err
+		}
+
+		within := fmt.Sprintf("s.created_at >= CURRENT_TIMESTAMP - %s * INTERVAL '1 day'", b.param(days))
+		if c.Operator == OpNotWithinDays {
+			return "NOT " + within, nil
+		}
+
+		return within, nil
+	}
+
+	return "", c.invalidOperator(OpBefore, OpAfter, OpWithinDays, OpNotWithinDays)
+}
+
+// engagementSQL matches the subscriptions that opened or clicked an
+// email in the last days. Events of bots aren't engagement.
+func (c *Condition) engagementSQL(b *filterBuilder) (string, error) {
+	if c.Operator != OpWithinDays && c.Operator != OpNotWithinDays {
+		return "", c.invalidOperator(OpWithinDays, OpNotWithinDays)
+	}
+
+	days, err := c.daysValue()
+	if err != nil {
+		return "", err
+	}
+
+	engaged := fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM tracking_events AS te
+		WHERE te.subscription_id = s.subscription_id
+		  AND te.event_type = '%s'
+		  AND NOT te.is_bot
+		  AND te.created_at >= CURRENT_TIMESTAMP - %s * INTERVAL '1 day'
+	)`, engagementEvents[c.Field], b.param(days))
+
+	if c.Operator == OpNotWithinDays {
+		return "NOT " + engaged, nil
+	}
+
+	return engaged, nil
+}
+
+// textSQL compares a text column, case insensitively. Missing custom
+// fields are the same as empty ones.
+func (c *Condition) textSQL(b *filterBuilder, column string) (string, error) {
+	column = fmt.Sprintf("COALESCE(%s, '')", column)
+
+	switch c.Operator {
+	case OpExists:
+		return fmt.Sprintf("%s <> ''", column), nil
+	case OpNotExists:
+		return fmt.Sprintf("%s = ''", column), nil
+	}
+
+	value, err := c.stringValue()
+	if err != nil {
+		return "", err
+	}
+
+	switch c.Operator {
+	case OpEquals:
+		return fmt.Sprintf("LOWER(%s) = LOWER(%s)", column, b.param(value)), nil
+	case OpNotEquals:
+		return fmt.Sprintf("LOWER(%s) <> LOWER(%s)", column, b.param(value)), nil
+	case OpContains:
+		pattern := "%" + likeEscaper.Replace(value) + "%"
+		return fmt.Sprintf("%s ILIKE %s", column, b.param(pattern)), nil
+	}
+
+	return "", c.invalidOperator(OpEquals, OpNotEquals, OpContains, OpExists, OpNotExists)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// stringValue returns the value as text, numbers and booleans of custom
+// fields are compared by their JSON representation
+func (c *Condition) stringValue() (string, error) {
+	switch v := c.Value.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	return "", fmt.Errorf("field '%s' must be compared with a string, a number or a boolean", c.Field)
+}
+
+// daysValue returns the value as a positive number of days
+func (c *Condition) daysValue() (int64, error) {
+	v, ok := c.Value.(float64)
+	if !ok || v < 1 || v != float64(int64(v)) {
+		return 0, fmt.Errorf("operator '%s' must be used with a positive number of days", c.Operator)
+	}
+
+	return int64(v), nil
+}
+
+// timeValue returns the value as a UTC date or date and time
+func (c *Condition) timeValue() (time.Time, error) {
+	v, _ := c.Value.(string)
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("operator '%s' must be used with a date like '2023-01-31' or '2023-01-31T15:00:00Z'", c.Operator)
+}
+
+// invalidOperator returns the error of operators that can't be used with
+// the field
+func (c *Condition) invalidOperator(valid ...Operator) error {
+	return fmt.Errorf("invalid operator '%s' for field '%s', use one of %v", c.Operator, c.Field, valid)
+}
+
+// filterBuilder collects the parameters of a filter
+type filterBuilder struct {
+	params []interface{}
+}
+
+// param adds a parameter to the filter and returns its placeholder, which
+// starts at $2 because $1 is the id of the project
+func (b *filterBuilder) param(v interface{}) string {
+	b.params = append(b.params, v)
+	return fmt.Sprintf("$%d", len(b.params)+1)
+}
+
+// Scan loads the Rules from the JSON stored in the database
+func (r *Rules) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*r = Rules{Match: MatchAll}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan rules from %T", src)
+	}
+
+	if err := json.Unmarshal(data, r); err != nil {
+		return fmt.Errorf("unable to parse rules: %v", err)
+	}
+
+	return nil
+}
+
+// Value converts the Rules to JSON before storing them in the database
+func (r Rules) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize rules: %v", err)
+	}
+
+	return data, nil
+}
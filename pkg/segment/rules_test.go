@@ -0,0 +1,159 @@
+package segment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionFilter(t *testing.T) {
+	date := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		condition  Condition
+		wantSQL    string
+		wantParams []interface{}
+	}{
+		{"has tag", Condition{"tag", OpHas, " VIP "}, "s.tags @> ARRAY[$2::TEXT]", []interface{}{"vip"}},
+		{"not has tag", Condition{"tag", OpNotHas, "vip"}, "NOT s.tags @> ARRAY[$2::TEXT]", []interface{}{"vip"}},
+		{"active", Condition{"status", OpEquals, StatusActive}, "NOT " + suppressedCondition, nil},
+		{"suppressed", Condition{"status", OpEquals, StatusSuppressed}, suppressedCondition, nil},
+		{"not active", Condition{"status", OpNotEquals, StatusActive}, suppressedCondition, nil},
+		{"not suppressed", Condition{"status", OpNotEquals, StatusSuppressed}, "NOT " + suppressedCondition, nil},
+		{"email equals", Condition{"email", OpEquals, "Jane@Example.com"}, "LOWER(COALESCE(s.normalized_email, '')) = LOWER($2)", []interface{}{"Jane@Example.com"}},
+		{"name not equals", Condition{"name", OpNotEquals, "Jane"}, "LOWER(COALESCE(s.name, '')) <> LOWER($2)", []interface{}{"Jane"}},
+		{"locale contains", Condition{"locale", OpContains, "50%_off"}, "COALESCE(s.locale, '') ILIKE $2", []interface{}{`%50\%\_off%`}},
+		{"timezone exists", Condition{"timezone", OpExists, nil}, "COALESCE(s.timezone, '') <> ''", nil},
+		{"timezone not exists", Condition{"timezone", OpNotExists, nil}, "COALESCE(s.timezone, '') = ''", nil},
+		{"attribute equals", Condition{"attributes.plan", OpEquals, "pro"}, "LOWER(COALESCE((s.attributes->>$2::TEXT), '')) = LOWER($3)", []interface{}{"plan", "pro"}},
+		{"attribute number", Condition{"attributes.seats", OpEquals, float64(10)}, "LOWER($3)", []interface{}{"seats", "10"}},
+		{"attribute boolean", Condition{"attributes.trial", OpEquals, true}, "LOWER($3)", []interface{}{"trial", "true"}},
+		{"attribute exists", Condition{"attributes.plan", OpExists, nil}, "COALESCE((s.attributes->>$2::TEXT), '') <> ''", []interface{}{"plan"}},
+		{"created before", Condition{"created_at", OpBefore, "2023-01-31"}, "s.created_at < $2", []interface{}{date}},
+		{"created after", Condition{"created_at", OpAfter, "2023-01-31T00:00:00Z"}, "s.created_at >= $2", []interface{}{date}},
+		{"created within days", Condition{"created_at", OpWithinDays, float64(7)}, "s.created_at >= CURRENT_TIMESTAMP - $2 * INTERVAL '1 day'", []interface{}{int64(7)}},
+		{"created not within days", Condition{"created_at", OpNotWithinDays, float64(7)}, "NOT s.created_at >= CURRENT_TIMESTAMP - $2 * INTERVAL '1 day'", []interface{}{int64(7)}},
+		{"opened within days", Condition{"opened", OpWithinDays, float64(30)}, "te.event_type = 'open'", []interface{}{int64(30)}},
+		{"clicked not within days", Condition{"clicked", OpNotWithinDays, float64(30)}, "NOT EXISTS", []interface{}{int64(30)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rules{Conditions: []*Condition{&tt.condition}}
+
+			f, err := r.Filter()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.HasPrefix(f.Condition, "(") || !strings.Contains(f.Condition, tt.wantSQL) {
+				t.Fatalf("condition = %s, want it to contain %s", f.Condition, tt.wantSQL)
+			}
+
+			if !reflect.DeepEqual(f.Params, tt.wantParams) {
+				t.Fatalf("params = %#v, want %#v", f.Params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestConditionFilterErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{"unknown field", Condition{"age", OpEquals, "1"}},
+		{"empty custom field", Condition{"attributes.", OpEquals, "1"}},
+		{"invalid tag", Condition{"tag", OpHas, "two words"}},
+		{"empty tag", Condition{"tag", OpHas, " "}},
+		{"tag operator", Condition{"tag", OpEquals, "vip"}},
+		{"invalid status", Condition{"status", OpEquals, "bounced"}},
+		{"status operator", Condition{"status", OpContains, StatusActive}},
+		{"text operator", Condition{"email", OpHas, "jane"}},
+		{"text value", Condition{"email", OpEquals, []interface{}{"jane"}}},
+		{"date value", Condition{"created_at", OpBefore, "yesterday"}},
+		{"date operator", Condition{"created_at", OpEquals, "2023-01-31"}},
+		{"negative days", Condition{"created_at", OpWithinDays, float64(-1)}},
+		{"fractional days", Condition{"opened", OpWithinDays, 1.5}},
+		{"engagement operator", Condition{"clicked", OpExists, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rules{Conditions: []*Condition{&tt.condition}}
+
+			if _, err := r.Filter(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestRulesFilter(t *testing.T) {
+	conditions := []*Condition{
+		{"tag", OpHas, "vip"},
+		{"status", OpEquals, StatusActive},
+		{"attributes.plan", OpEquals, "pro"},
+		{"opened", OpWithinDays, float64(30)},
+	}
+
+	tests := []struct {
+		match Match
+		join  string
+	}{
+		{"", " AND "},
+		{MatchAll, " AND "},
+		{MatchAny, " OR "},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.match), func(t *testing.T) {
+			r := &Rules{Match: tt.match, Conditions: conditions}
+
+			f, err := r.Filter()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the parameters are numbered across conditions after the
+			// id of the project
+			wantParams := []interface{}{"vip", "plan", "pro", int64(30)}
+			if !reflect.DeepEqual(f.Params, wantParams) {
+				t.Fatalf("params = %#v, want %#v", f.Params, wantParams)
+			}
+
+			for _, placeholder := range []string{"ARRAY[$2::TEXT]", "s.attributes->>$3::TEXT", "LOWER($4)", "$5 * INTERVAL"} {
+				if !strings.Contains(f.Condition, placeholder) {
+					t.Fatalf("condition = %s, want it to contain %s", f.Condition, placeholder)
+				}
+			}
+
+			if got := strings.Count(f.Condition, ")"+tt.join+"("); got != len(conditions)-1 {
+				t.Fatalf("condition = %s, want conditions joined by '%s'", f.Condition, tt.join)
+			}
+		})
+	}
+
+	t.Run("invalid match", func(t *testing.T) {
+		if _, err := (&Rules{Match: "some"}).Filter(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("no conditions", func(t *testing.T) {
+		f, err := (&Rules{}).Filter()
+		if err != nil || f.Condition != "" || len(f.Params) != 0 {
+			t.Fatalf("filter = %+v, %v, want an empty filter", f, err)
+		}
+	})
+
+	t.Run("invalid condition", func(t *testing.T) {
+		r := &Rules{Conditions: []*Condition{{"tag", OpHas, "vip"}, {"age", OpEquals, "1"}}}
+
+		if _, err := r.Filter(); err == nil || !strings.Contains(err.Error(), "condition 2") {
+			t.Fatalf("error = %v, want it to name condition 2", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package segment
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/subscription"
+)
+
+// Segment is a saved selection of the subscriptions of a project, used to
+// send issues and campaigns to part of the list
+type Segment struct {
+	ID        int64     `json:"segment_id"`
+	ProjectID int64     `json:"project_id"`
+	Name      string    `json:"name"`
+	Rules     Rules     `json:"rules"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// New returns an empty Segment, which matches every subscription
+func New() *Segment {
+	return &Segment{Rules: Rules{Match: MatchAll, Conditions: []*Condition{}}}
+}
+
+// Create the segment in the database
+func (s *Segment) Create() error {
+	if err := insertSegment(s); err != nil {
+		return fmt.Errorf("unable to create segment: %v", err)
+	}
+
+	return nil
+}
+
+// Update the segment in the database
+func (s *Segment) Update() error {
+	if err := updateSegment(s); err != nil {
+		return fmt.Errorf("unable to update segment: %v", err)
+	}
+
+	return nil
+}
+
+// Delete the segment from the database
+func (s *Segment) Delete() error {
+	if err := deleteSegment(s.ID, s.ProjectID); err != nil {
+		return fmt.Errorf("unable to delete segment: %v", err)
+	}
+
+	return nil
+}
+
+// Validate checks if the segment has a name and valid rules
+func (s *Segment) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("segment name is required")
+	}
+
+	return s.Rules.Validate()
+}
+
+// IsInUse says if the segment is the audience of the project or of a
+// campaign that wasn't completely sent yet, in which case it can't be
+// deleted
+func (s *Segment) IsInUse() (bool, error) {
+	inUse, err := isSegmentInUse(s.ID, s.ProjectID)
+	if err != nil {
+		return false, fmt.Errorf("unable to check if segment is in use: %v", err)
+	}
+
+	return inUse, nil
+}
+
+// Subscriptions returns the deliverable subscriptions of the segment
+func (s *Segment) Subscriptions() ([]*subscription.Subscription, error) {
+	f, err := s.Rules.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription.NewProjectSubscriptions(s.ProjectID).DeliverableMatching(f)
+}
@@ -7,9 +7,18 @@ import (
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"github.com/statictask/newsletter/pkg/campaign"
+	"github.com/statictask/newsletter/pkg/delivery"
+	"github.com/statictask/newsletter/pkg/importer"
+	"github.com/statictask/newsletter/pkg/pages"
 	"github.com/statictask/newsletter/pkg/project"
+	"github.com/statictask/newsletter/pkg/protection"
+	"github.com/statictask/newsletter/pkg/segment"
 	"github.com/statictask/newsletter/pkg/subscription"
+	"github.com/statictask/newsletter/pkg/suppression"
+	"github.com/statictask/newsletter/pkg/task"
 	"github.com/statictask/newsletter/pkg/template"
+	"github.com/statictask/newsletter/pkg/tracking"
 )
 
 var (
@@ -50,14 +59,66 @@ func (s *Server) Listen(bind string) error {
 
 	// subscription routes
 	router.HandleFunc("/projects/{project_id}/subscriptions", subscription.GetSubscriptions).Methods("GET")
-	router.HandleFunc("/projects/{project_id}/subscriptions", subscription.CreateSubscription).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/subscriptions", protection.Subscribe(subscription.CreateSubscription)).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/subscriptions/_challenge", protection.GetChallenge).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/subscriptions/_tags", subscription.TagSubscriptions).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/subscriptions/_export", importer.ExportSubscriptions).Methods("GET")
 	router.HandleFunc("/projects/{project_id}/subscriptions/{subscription_id}", subscription.GetSubscription).Methods("GET")
 	router.HandleFunc("/projects/{project_id}/subscriptions/{subscription_id}", subscription.DeleteSubscription).Methods("DELETE")
 	router.HandleFunc("/projects/{project_id}/subscriptions/{subscription_id}", subscription.UpdateSubscription).Methods("UPDATE")
 	router.HandleFunc("/projects/{project_id}/subscriptions/{subscription_id}/_token", subscription.GetSubscriptionToken).Methods("GET")
-	router.HandleFunc("/unsubscribe", subscription.GetUnsubscribePage).Queries("token", "{token}").Methods("GET")
+	router.HandleFunc("/projects/{project_id}/subscriptions/{subscription_id}/deliveries", delivery.GetSubscriptionDeliveries).Methods("GET")
+	router.HandleFunc("/unsubscribe", pages.GetUnsubscribePage).Queries("token", "{token}").Methods("GET")
+	router.HandleFunc("/unsubscribe", pages.PostUnsubscribeForm).Queries("token", "{token}").Methods("POST")
 	router.HandleFunc("/unsubscribe", subscription.DeleteSubscriptionByToken).Queries("token", "{token}").Methods("DELETE")
 	router.HandleFunc("/goodbye", subscription.GetGoodbyePage).Methods("GET")
+	router.HandleFunc("/preferences", subscription.GetPreferencesPage).Queries("token", "{token}").Methods("GET")
+	router.HandleFunc("/preferences", subscription.UpdatePreferencesByToken).Queries("token", "{token}").Methods("UPDATE")
+
+	// hosted pages routes
+	router.HandleFunc("/projects/{project_id}/subscribe", pages.GetSubscribePage).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/subscribe", pages.PostSubscribeForm).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/confirm", pages.GetConfirmPage).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/goodbye", pages.GetGoodbyePage).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/embed.js", pages.GetEmbedScript).Methods("GET")
+	router.HandleFunc("/p/{slug}/archive", pages.GetArchivePage).Methods("GET")
+	router.HandleFunc("/p/{slug}/archive/{issue}", pages.GetArchiveIssue).Methods("GET")
+
+	// import routes
+	router.HandleFunc("/projects/{project_id}/imports", importer.GetImports).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/imports", importer.CreateImport).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/imports/{import_id}", importer.GetImport).Methods("GET")
+
+	// segment routes
+	router.HandleFunc("/projects/{project_id}/segments", segment.GetSegments).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/segments", segment.CreateSegment).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/segments/_preview", segment.PreviewRules).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/segments/{segment_id}", segment.GetSegment).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/segments/{segment_id}", segment.DeleteSegment).Methods("DELETE")
+	router.HandleFunc("/projects/{project_id}/segments/{segment_id}", segment.UpdateSegment).Methods("UPDATE")
+	router.HandleFunc("/projects/{project_id}/segments/{segment_id}/_preview", segment.PreviewSegment).Methods("GET")
+
+	// campaign routes
+	router.HandleFunc("/projects/{project_id}/campaigns", campaign.GetCampaigns).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/campaigns", campaign.CreateCampaign).Methods("POST")
+	router.HandleFunc("/projects/{project_id}/campaigns/{campaign_id}", campaign.GetCampaign).Methods("GET")
+	router.HandleFunc("/projects/{project_id}/campaigns/{campaign_id}", campaign.DeleteCampaign).Methods("DELETE")
+	router.HandleFunc("/projects/{project_id}/campaigns/{campaign_id}", campaign.UpdateCampaign).Methods("UPDATE")
+	router.HandleFunc("/projects/{project_id}/campaigns/{campaign_id}/_send", campaign.SendCampaign).Methods("POST")
+
+	// task routes
+	router.HandleFunc("/projects/{project_id}/tasks", task.GetProjectTasks).Methods("GET")
+
+	// suppression routes
+	router.HandleFunc("/suppressions", suppression.GetSuppressions).Methods("GET")
+	router.HandleFunc("/suppressions/{suppression_id}", suppression.DeleteSuppression).Methods("DELETE")
+	router.HandleFunc("/webhooks/sendgrid", suppression.ReceiveSendGridEvents).Methods("POST")
+	router.HandleFunc("/webhooks/events", suppression.ReceiveEvents).Methods("POST")
+
+	// tracking routes
+	router.HandleFunc("/projects/{project_id}/posts/{post_id}/stats", tracking.GetPostStats).Methods("GET")
+	router.HandleFunc("/o/{token}", tracking.GetOpenPixel).Methods("GET", "HEAD")
+	router.HandleFunc("/r/{token}", tracking.RedirectClick).Methods("GET", "HEAD")
 
 	s.L.With(zap.String("bind", bind)).Info("listening")
 
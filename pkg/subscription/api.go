@@ -2,14 +2,19 @@ package subscription
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"html/template"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/statictask/newsletter/internal/log"
 	"github.com/statictask/newsletter/internal/utils"
+	"github.com/statictask/newsletter/pkg/post"
+	"github.com/statictask/newsletter/pkg/validation"
 	"go.uber.org/zap"
 )
 
@@ -34,7 +39,30 @@ func CreateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = controller.Add(s); err != nil {
+	// only imports keep the signup date, and the checks of the address
+	// are made below
+	s.CreatedAt = time.Time{}
+	s.Validation = nil
+
+	if err = s.Validate(); err != nil {
+		_log.Error("Invalid Subscription.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !checkEmail(w, r, controller, s, _log) {
+		return
+	}
+
+	err = controller.Add(s)
+	if errors.Is(err, ErrAlreadySubscribed) {
+		// subscribing twice is harmless, the reader gets the same answer
+		_log.Info("Subscription already exists.", zap.Int64("subscription_id", s.ID))
+		utils.WriteJSONResponseData(w, http.StatusOK, s)
+		return
+	}
+
+	if err != nil {
 		_log.Error("Failed adding new Subscription.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
 		return
@@ -139,12 +167,42 @@ func UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the validation of the address can't be changed, only redone when
+	// the address changes
+	email, result := s.Email, s.Validation
+
 	if err = json.NewDecoder(r.Body).Decode(&s); err != nil {
 		_log.Error("Failed decoding request body.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
 		return
 	}
 
+	s.Validation = result
+
+	if err = s.Validate(); err != nil {
+		_log.Error("Invalid Subscription.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if s.Email != email && !checkEmail(w, r, controller, s, _log) {
+		return
+	}
+
+	other, err := controller.GetByEmail(s.Email)
+	if err != nil {
+		_log.Error("Failed getting Subscription.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if other != nil && other.ID != s.ID {
+		err := fmt.Errorf("email '%s' is already subscribed to the project", s.Email)
+		_log.Error("Invalid Subscription.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusConflict, err)
+		return
+	}
+
 	if err = s.Update(); err != nil {
 		_log.Error("Failed updating Subscription.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -155,6 +213,80 @@ func UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponseData(w, http.StatusOK, s)
 }
 
+// checkEmail validates the address of the subscription with the policies
+// of the project, writing the error response when it fails
+func checkEmail(w http.ResponseWriter, r *http.Request, controller *ProjectSubscriptions, s *Subscription, _log *zap.Logger) bool {
+	err := controller.CheckEmail(r.Context(), s)
+	if err == nil {
+		if s.Validation.Flagged() {
+			_log.Info("Subscription email flagged.", zap.Strings("flags", s.Validation.Flags))
+		}
+
+		return true
+	}
+
+	var rejected *validation.RejectedError
+	if errors.As(err, &rejected) {
+		_log.Info("Subscription email rejected.", zap.String("check", rejected.Check))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return false
+	}
+
+	_log.Error("Failed checking Subscription email.", zap.Error(err))
+	utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+
+	return false
+}
+
+// tagRequest selects subscriptions by their ids or emails and lists the
+// tags added to and removed from them
+type tagRequest struct {
+	SubscriptionIDs []int64  `json:"subscription_ids"`
+	Emails          []string `json:"emails"`
+	Add             []string `json:"add"`
+	Remove          []string `json:"remove"`
+}
+
+// TagSubscriptions adds and removes tags of many subscriptions at once
+func TagSubscriptions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	controller := NewProjectSubscriptions(int64(projectID))
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	req := &tagRequest{}
+	if err = json.NewDecoder(r.Body).Decode(req); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, tags := range [][]string{req.Add, req.Remove} {
+		if _, err = NormalizeTags(tags); err != nil {
+			_log.Error("Invalid tags.", zap.Error(err))
+			utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	changed, err := controller.Tag(req.SubscriptionIDs, req.Emails, req.Add, req.Remove)
+	if err != nil {
+		_log.Error("Failed tagging Subscriptions.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Subscriptions tagged successfully.", zap.Int64("changed", changed))
+	utils.WriteJSONResponseData(w, http.StatusOK, map[string]int64{"changed": changed})
+}
+
 // DeleteSubscription deletes a subscription entry from the project
 func DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
@@ -197,32 +329,6 @@ func DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponseMessage(w, http.StatusNoContent, msg)
 }
 
-// GetUnsubscribePage builds an HTML response with an unsbscribe page
-func GetUnsubscribePage(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Context-Type", "text/html")
-
-	token := r.URL.Query().Get("token")
-
-	s, err := Decrypt(token)
-	if err != nil {
-		tmpl :=	template.Must(template.ParseFiles("static/404/index.html"))
-        	tmpl.Execute(w, nil)
-
-		return
-	}
-
-	// Parse the template file
-        tmpl := template.Must(template.ParseFiles("static/unsubscribe/index.html"))
-
-	data := map[string]interface{} {
-		"token": token,
-		"email": s.Email,
-	}
-
-        // Execute the template and write it to the response writer
-        tmpl.Execute(w, data)
-}
-
 // DeleteUnsubscribe builds an HTML response with an unsbscribe page
 func DeleteSubscriptionByToken(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
@@ -258,6 +364,122 @@ func GetGoodbyePage(w http.ResponseWriter, r *http.Request) {
         tmpl.Execute(w, nil)
 }
 
+// preferencesTopic is a topic shown in the preferences page
+type preferencesTopic struct {
+	Name    string
+	Checked bool
+}
+
+// GetPreferencesPage builds an HTML response with the preferences page,
+// where subscribers choose the topics they read and how often
+func GetPreferencesPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	token := r.URL.Query().Get("token")
+
+	s, err := GetByToken(token)
+	if err != nil || s == nil {
+		w.WriteHeader(http.StatusNotFound)
+
+		tmpl := template.Must(template.ParseFiles("static/404/index.html"))
+		tmpl.Execute(w, nil)
+
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", s.ProjectID), zap.Int64("subscription_id", s.ID))
+
+	categories, err := post.NewProjectPosts(s.ProjectID).Categories()
+	if err != nil {
+		_log.Error("Failed loading Project categories.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	topics := []*preferencesTopic{}
+	seen := map[string]bool{}
+	for _, c := range categories {
+		seen[strings.ToLower(c)] = true
+		topics = append(topics, &preferencesTopic{Name: c, Checked: s.HasTopic(c)})
+	}
+
+	// topics that left the feed are still shown while they are chosen
+	for _, t := range s.Topics {
+		if !seen[strings.ToLower(t)] {
+			topics = append(topics, &preferencesTopic{Name: t, Checked: true})
+		}
+	}
+
+	tmpl := template.Must(template.ParseFiles("static/preferences/index.html"))
+
+	data := map[string]interface{}{
+		"token":  token,
+		"email":  s.Email,
+		"topics": topics,
+		"frequencies": []Frequency{Immediate, Weekly, Monthly},
+		"frequency": s.Frequency,
+	}
+
+	tmpl.Execute(w, data)
+}
+
+// preferences are the fields subscribers change in the preferences page
+type preferences struct {
+	Topics    []string  `json:"topics"`
+	Frequency Frequency `json:"frequency"`
+}
+
+// UpdatePreferencesByToken changes the preferences of the subscription
+// identified by the token
+func UpdatePreferencesByToken(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	s, err := GetByToken(token)
+	if err != nil {
+		log.L.Error("Failed to decrypt subscription token.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if s == nil {
+		err = fmt.Errorf("subscription not found")
+		log.L.Error("Failed getting Subscription by token.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusNotFound, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", s.ProjectID), zap.Int64("subscription_id", s.ID))
+
+	p := &preferences{}
+	if err = json.NewDecoder(r.Body).Decode(p); err != nil {
+		_log.Error("Failed decoding request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Topics = p.Topics
+
+	// the frequency is kept when it isn't sent
+	if p.Frequency != "" {
+		s.Frequency = p.Frequency
+	}
+
+	if err = s.Validate(); err != nil {
+		_log.Error("Invalid Subscription preferences.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = s.Update(); err != nil {
+		_log.Error("Failed updating Subscription preferences.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Subscription preferences updated successfully.")
+	utils.WriteJSONResponseData(w, http.StatusOK, &preferences{Topics: s.Topics, Frequency: s.Frequency})
+}
+
 // GetSubscriptionToken return a single subscription token
 func GetSubscriptionToken(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
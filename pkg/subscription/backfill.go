@@ -0,0 +1,57 @@
+package subscription
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+)
+
+// NormalizeLegacyEmails converts to punycode the domains of the normalized
+// emails backfilled by the database, which only trimmed them and changed
+// them to lowercase. When the address is subscribed to the project twice,
+// once with each form of the domain, the oldest subscription is kept.
+func NormalizeLegacyEmails() error {
+	subscriptions, err := getSubscriptionsWithUnicodeEmail()
+	if err != nil {
+		return err
+	}
+
+	normalized := 0
+	for _, s := range subscriptions {
+		_log := log.L.With(zap.Int64("project_id", s.ProjectID), zap.Int64("subscription_id", s.ID))
+
+		existing, err := getSubscriptionByEmail(s.ProjectID, s.Email)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil && existing.ID != s.ID {
+			duplicate := s
+			if existing.ID > s.ID {
+				duplicate = existing
+			}
+
+			if err := duplicate.Delete(); err != nil {
+				return err
+			}
+
+			_log.Info("deleted duplicated subscription", zap.Int64("duplicate_id", duplicate.ID))
+
+			if duplicate == s {
+				continue
+			}
+		}
+
+		if err := updateNormalizedEmail(s); err != nil {
+			return err
+		}
+
+		normalized++
+	}
+
+	if normalized > 0 {
+		log.L.Info("normalized legacy subscription emails", zap.Int("subscriptions", normalized))
+	}
+
+	return nil
+}
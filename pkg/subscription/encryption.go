@@ -7,13 +7,22 @@ import (
 	"golang.org/x/crypto/scrypt"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/statictask/newsletter/internal/config"
 )
 
 func (s *Subscription) Encrypt() (string, error) {
+	// Only the identity of the subscription goes in the token, so
+	// subscriber fields don't make links longer
+	identity := struct {
+		ID        int64  `json:"subscription_id"`
+		Email     string `json:"email"`
+		ProjectID int64  `json:"project_id"`
+	}{s.ID, s.Email, s.ProjectID}
+
 	// Convert the map to a JSON string
-	jsonData, err := json.Marshal(s)
+	jsonData, err := json.Marshal(identity)
 	if err != nil {
 		return "", fmt.Errorf("failed marshaling subscription: %v", err)
 	}
@@ -77,3 +86,23 @@ func Decrypt(token string) (*Subscription, error) {
 	return s, nil
 }
 
+
+// GetByToken returns the subscription identified by a token, nil if it
+// was deleted or its email changed since the token was created
+func GetByToken(token string) (*Subscription, error) {
+	identity, err := Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewProjectSubscriptions(identity.ProjectID).Get(identity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil || !strings.EqualFold(s.Email, identity.Email) {
+		return nil, nil
+	}
+
+	return s, nil
+}
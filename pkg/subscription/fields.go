@@ -0,0 +1,220 @@
+package subscription
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	// timezones are validated even where the system has no tz database
+	_ "time/tzdata"
+
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+const (
+	maxNameLength  = 300
+	maxTagLength   = 50
+	maxTopicLength = 100
+	maxTopics      = 100
+)
+
+// localeRegexp accepts BCP 47 language tags like "en", "pt-BR" or "zh-Hant-TW"
+var localeRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// tagRegexp accepts lowercase tags made of letters, digits, dashes,
+// underscores, dots and colons, like "vip" or "source:website"
+var tagRegexp = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}_.:-]*$`)
+
+// Frequency is how often a subscriber receives the posts of the project
+type Frequency string
+
+const (
+	// Immediate subscribers receive each post as it's published
+	Immediate Frequency = "immediate"
+	// Weekly and Monthly subscribers receive a digest of the posts
+	// published since their previous digest
+	Weekly  Frequency = "weekly"
+	Monthly Frequency = "monthly"
+)
+
+// Attributes are custom fields of a subscriber, stored as JSON
+type Attributes map[string]interface{}
+
+// Scan loads the Attributes from the JSON stored in the database
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = Attributes{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan attributes from %T", src)
+	}
+
+	if err := json.Unmarshal(data, a); err != nil {
+		return fmt.Errorf("unable to parse attributes: %v", err)
+	}
+
+	return nil
+}
+
+// Value converts the Attributes to JSON before storing them in the database
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize attributes: %v", err)
+	}
+
+	return data, nil
+}
+
+// Validate checks if the subscriber fields are well formed
+func (s *Subscription) Validate() error {
+	s.Email = strings.TrimSpace(s.Email)
+	if err := validation.CheckSyntax(s.Email); err != nil {
+		return err
+	}
+
+	s.Name = strings.TrimSpace(s.Name)
+	if len(s.Name) > maxNameLength {
+		return fmt.Errorf("name can't be longer than %d characters", maxNameLength)
+	}
+
+	if s.Locale != "" && !localeRegexp.MatchString(s.Locale) {
+		return fmt.Errorf("invalid locale '%s', use a language tag like 'en' or 'pt-BR'", s.Locale)
+	}
+
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone '%s', use an IANA name like 'America/Sao_Paulo'", s.Timezone)
+		}
+	}
+
+	tags, err := NormalizeTags(s.Tags)
+	if err != nil {
+		return err
+	}
+
+	s.Tags = tags
+
+	topics, err := NormalizeTopics(s.Topics)
+	if err != nil {
+		return err
+	}
+
+	s.Topics = topics
+
+	switch s.Frequency {
+	case "":
+		s.Frequency = Immediate
+	case Immediate, Weekly, Monthly:
+	default:
+		return fmt.Errorf("invalid frequency '%s', use '%s', '%s' or '%s'", s.Frequency, Immediate, Weekly, Monthly)
+	}
+
+	return nil
+}
+
+// ReadsDigests says if the subscriber receives digests instead of each
+// post as it's published
+func (s *Subscription) ReadsDigests() bool {
+	return s.Frequency == Weekly || s.Frequency == Monthly
+}
+
+// NormalizeTags lowercases and trims the tags, removing duplicates, and
+// checks if they are well formed
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+
+		if len(t) > maxTagLength || !tagRegexp.MatchString(t) {
+			return nil, fmt.Errorf("invalid tag '%s', use up to %d letters, digits, '-', '_', '.' or ':'", t, maxTagLength)
+		}
+
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+
+	return normalized, nil
+}
+
+// FirstName returns the first word of the subscriber's name
+func (s *Subscription) FirstName() string {
+	if fields := strings.Fields(s.Name); len(fields) > 0 {
+		return fields[0]
+	}
+
+	return ""
+}
+
+// NormalizeTopics trims the topics, removing empty and repeated ones
+// regardless of their case, since they are compared with the categories
+// of the feed case insensitively
+func NormalizeTopics(topics []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, t := range topics {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+
+		if len(t) > maxTopicLength {
+			return nil, fmt.Errorf("topic '%s' can't be longer than %d characters", t, maxTopicLength)
+		}
+
+		seen[strings.ToLower(t)] = true
+		normalized = append(normalized, t)
+	}
+
+	if len(normalized) > maxTopics {
+		return nil, fmt.Errorf("subscribers can't choose more than %d topics", maxTopics)
+	}
+
+	return normalized, nil
+}
+
+// Reads says if the subscriber reads items of the categories. Subscribers
+// without topics read everything, and so does everyone with items
+// without categories.
+func (s *Subscription) Reads(categories []string) bool {
+	if len(s.Topics) == 0 || len(categories) == 0 {
+		return true
+	}
+
+	for _, c := range categories {
+		if s.HasTopic(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTopic says if the subscriber chose the topic
+func (s *Subscription) HasTopic(topic string) bool {
+	for _, t := range s.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package subscription
+
+// Filter is a SQL condition over the subscriptions table, aliased as "s",
+// that selects part of the subscriptions of a project. Its placeholders
+// are numbered from $2 on, $1 being the id of the project.
+type Filter struct {
+	Condition string
+	Params    []interface{}
+}
+
+// Count is the number of subscriptions matched by a filter
+type Count struct {
+	Subscriptions int64 `json:"subscriptions"`
+	// Deliverable leaves out the addresses suppressed by bounces and
+	// complaints
+	Deliverable int64 `json:"deliverable"`
+}
+
+// condition returns the SQL condition of the filter, a nil filter
+// matches every subscription
+func (f *Filter) condition() string {
+	if f == nil || f.Condition == "" {
+		return "TRUE"
+	}
+
+	return f.Condition
+}
+
+// params returns the parameters of the query that selects the
+// subscriptions of the project matching the filter
+func (f *Filter) params(projectID int64) []interface{} {
+	params := []interface{}{projectID}
+	if f != nil {
+		params = append(params, f.Params...)
+	}
+
+	return params
+}
@@ -3,34 +3,109 @@ package subscription
 import (
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/statictask/newsletter/internal/database"
+	"github.com/statictask/newsletter/internal/utils"
+	"github.com/statictask/newsletter/pkg/validation"
 )
 
-// insertSubscription inserts a subscription in the database
+// insertSubscription inserts a subscription in the database. When the
+// address is already subscribed to the project the existing subscription
+// is loaded instead, returning ErrAlreadySubscribed.
 func insertSubscription(s *Subscription) error {
 	query := `
 		INSERT INTO subscriptions (
 		  project_id,
-		  email
+		  email,
+		  normalized_email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
+		  created_at
 	  	)
 		VALUES (
 		  $1,
-		  $2
+		  $2,
+		  $3,
+		  $4,
+		  $5,
+		  $6,
+		  $7,
+		  $8,
+		  COALESCE($9, '{}'::TEXT[]),
+		  COALESCE($10, '{}'::TEXT[]),
+		  $11,
+		  $12,
+		  COALESCE($13, CURRENT_TIMESTAMP)
 	  	)
+		ON CONFLICT (project_id, normalized_email) DO NOTHING
 		RETURNING
 		  subscription_id,
 		  project_id,
 		  email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
 		  created_at,
 		  updated_at
 	`
 
-	savedSubscription, err := scanSubscription(query, s.ProjectID, s.Email)
+	// imported subscriptions keep their original signup date
+	var createdAt interface{}
+	if !s.CreatedAt.IsZero() {
+		createdAt = s.CreatedAt
+	}
+
+	savedSubscription, err := scanSubscription(
+		query,
+		s.ProjectID,
+		s.Email,
+		utils.NormalizeEmail(s.Email),
+		s.TrackingOptOut,
+		s.Name,
+		s.Locale,
+		s.Timezone,
+		s.Attributes,
+		pq.Array(s.Tags),
+		pq.Array(s.Topics),
+		s.Frequency,
+		s.Validation,
+		createdAt,
+	)
 	if err != nil {
 		return err
 	}
 
+	if savedSubscription == nil {
+		existing, err := getSubscriptionByEmail(s.ProjectID, s.Email)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			return fmt.Errorf("subscription of '%s' conflicts but wasn't found", s.Email)
+		}
+
+		*s = *existing
+
+		return ErrAlreadySubscribed
+	}
+
 	*s = *savedSubscription
 
 	return nil
@@ -43,6 +118,15 @@ func getSubscriptions(projectID int64) ([]*Subscription, error) {
 		  subscription_id,
 		  project_id,
 		  email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
 		  created_at,
 		  updated_at
 		FROM
@@ -54,6 +138,304 @@ func getSubscriptions(projectID int64) ([]*Subscription, error) {
 	return scanSubscriptions(query, projectID)
 }
 
+// getDeliverableSubscriptions returns the subscriptions of the project
+// whose addresses aren't suppressed and that match the filter
+func getDeliverableSubscriptions(projectID int64, f *Filter) ([]*Subscription, error) {
+	query := fmt.Sprintf(`
+		SELECT
+		  s.subscription_id,
+		  s.project_id,
+		  s.email,
+		  s.tracking_opt_out,
+		  s.name,
+		  s.locale,
+		  s.timezone,
+		  s.attributes,
+		  s.tags,
+		  s.topics,
+		  s.frequency,
+		  s.validation,
+		  s.created_at,
+		  s.updated_at
+		FROM
+		  subscriptions AS s
+		WHERE
+		  s.project_id = $1
+		  AND (%s)
+		  AND NOT EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      suppressions AS sp
+		    WHERE
+		      sp.email = s.normalized_email
+		  )
+	`, f.condition())
+
+	return scanSubscriptions(query, f.params(projectID)...)
+}
+
+// eachSubscription calls fn with the subscriptions of the project that
+// match the filter, one row at a time
+func eachSubscription(projectID int64, f *Filter, fn func(*Subscription) error) error {
+	query := fmt.Sprintf(`
+		SELECT
+		  s.subscription_id,
+		  s.project_id,
+		  s.email,
+		  s.tracking_opt_out,
+		  s.name,
+		  s.locale,
+		  s.timezone,
+		  s.attributes,
+		  s.tags,
+		  s.topics,
+		  s.frequency,
+		  s.validation,
+		  s.created_at,
+		  s.updated_at
+		FROM
+		  subscriptions AS s
+		WHERE
+		  s.project_id = $1
+		  AND (%s)
+		ORDER BY
+		  s.subscription_id
+	`, f.condition())
+
+	db, err := database.Connect()
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, f.params(projectID)...)
+	if err != nil {
+		return fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		s := New()
+
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Email, &s.TrackingOptOut, &s.Name, &s.Locale, &s.Timezone, &s.Attributes, pq.Array(&s.Tags), pq.Array(&s.Topics), &s.Frequency, &s.Validation, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return fmt.Errorf("unable to scan a subscription row: %v", err)
+		}
+
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// countSubscriptions counts the subscriptions of the project that match
+// the filter and how many of them aren't suppressed
+func countSubscriptions(projectID int64, f *Filter) (*Count, error) {
+	query := fmt.Sprintf(`
+		SELECT
+		  COUNT(*),
+		  COUNT(*) FILTER (
+		    WHERE NOT EXISTS (
+		      SELECT
+		        1
+		      FROM
+		        suppressions AS sp
+		      WHERE
+		        sp.email = s.normalized_email
+		    )
+		  )
+		FROM
+		  subscriptions AS s
+		WHERE
+		  s.project_id = $1
+		  AND (%s)
+	`, f.condition())
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	c := &Count{}
+
+	row := db.QueryRow(query, f.params(projectID)...)
+	if err := row.Scan(&c.Subscriptions, &c.Deliverable); err != nil {
+		return nil, fmt.Errorf("unable to count subscriptions: %v", err)
+	}
+
+	return c, nil
+}
+
+// tagSubscriptions adds and removes tags of the subscriptions of the
+// project with the given ids or emails, returning how many were changed
+func tagSubscriptions(projectID int64, subscriptionIDs []int64, emails, add, remove []string) (int64, error) {
+	query := `
+		UPDATE
+		  subscriptions AS s
+		SET
+		  tags = ARRAY(
+		    SELECT DISTINCT
+		      t
+		    FROM
+		      unnest(array_cat(s.tags, $1::TEXT[])) AS t
+		    WHERE
+		      t <> ALL($2::TEXT[])
+		    ORDER BY
+		      t
+		  )
+		WHERE
+		  s.project_id = $3
+		  AND (
+		    s.subscription_id = ANY($4::INTEGER[])
+		    OR s.normalized_email = ANY($5::TEXT[])
+		  )
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	result, err := db.Exec(
+		query,
+		pq.Array(add),
+		pq.Array(remove),
+		projectID,
+		pq.Array(subscriptionIDs),
+		pq.Array(emails),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed tagging subscriptions: %v", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// getSubscriptionsAwaitingWelcome returns the deliverable subscriptions
+// of the project created in the last maxAge that didn't get a welcome
+// email yet
+func getSubscriptionsAwaitingWelcome(projectID int64, maxAge time.Duration) ([]*Subscription, error) {
+	query := `
+		SELECT
+		  s.subscription_id,
+		  s.project_id,
+		  s.email,
+		  s.tracking_opt_out,
+		  s.name,
+		  s.locale,
+		  s.timezone,
+		  s.attributes,
+		  s.tags,
+		  s.topics,
+		  s.frequency,
+		  s.validation,
+		  s.created_at,
+		  s.updated_at
+		FROM
+		  subscriptions AS s
+		WHERE
+		  s.project_id = $1
+		  AND s.created_at >= CURRENT_TIMESTAMP - $2 * INTERVAL '1 second'
+		  AND NOT EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      deliveries AS d
+		    WHERE
+		      d.subscription_id = s.subscription_id
+		      AND d.kind = 'welcome'
+		  )
+		  AND NOT EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      suppressions AS sp
+		    WHERE
+		      sp.email = s.normalized_email
+		  )
+		ORDER BY
+		  s.created_at
+	`
+
+	return scanSubscriptions(query, projectID, int64(maxAge.Seconds()))
+}
+
+// getSubscriptionsAwaitingDigest returns the deliverable subscriptions
+// of the project with deferred deliveries whose digest is due: a week or
+// a month after the previous digest, or after subscribing. Subscribers
+// who went back to immediate emails get their remaining posts right away.
+func getSubscriptionsAwaitingDigest(projectID int64) ([]*Subscription, error) {
+	query := `
+		SELECT
+		  s.subscription_id,
+		  s.project_id,
+		  s.email,
+		  s.tracking_opt_out,
+		  s.name,
+		  s.locale,
+		  s.timezone,
+		  s.attributes,
+		  s.tags,
+		  s.topics,
+		  s.frequency,
+		  s.validation,
+		  s.created_at,
+		  s.updated_at
+		FROM
+		  subscriptions AS s
+		WHERE
+		  s.project_id = $1
+		  AND (
+		    s.frequency = 'immediate'
+		    OR COALESCE(s.last_digest_at, s.created_at) + CASE s.frequency
+		      WHEN 'weekly' THEN INTERVAL '1 week'
+		      ELSE INTERVAL '1 month'
+		    END <= CURRENT_TIMESTAMP
+		  )
+		  AND EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      deliveries AS d
+		    WHERE
+		      d.subscription_id = s.subscription_id
+		      AND d.delivery_status = 'deferred'
+		  )
+		  AND NOT EXISTS (
+		    SELECT
+		      1
+		    FROM
+		      suppressions AS sp
+		    WHERE
+		      sp.email = s.normalized_email
+		  )
+		ORDER BY
+		  s.subscription_id
+	`
+
+	return scanSubscriptions(query, projectID)
+}
+
+// updateLastDigestAt records when the subscription received its last
+// digest
+func updateLastDigestAt(subscriptionID int64) error {
+	query := `UPDATE subscriptions SET last_digest_at = CURRENT_TIMESTAMP WHERE subscription_id = $1`
+
+	if err := database.Exec(query, subscriptionID); err != nil {
+		return fmt.Errorf("failed updating last digest: %v", err)
+	}
+
+	return nil
+}
+
 // getProjectSubscription returns a single subscription that match both
 // subscription and project id
 func getSubscription(projectID, subscriptionID int64) (*Subscription, error) {
@@ -62,6 +444,15 @@ func getSubscription(projectID, subscriptionID int64) (*Subscription, error) {
 		  subscription_id,
 		  project_id,
 		  email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
 		  created_at,
 		  updated_at
 		FROM
@@ -74,12 +465,139 @@ func getSubscription(projectID, subscriptionID int64) (*Subscription, error) {
 	return scanSubscription(query, projectID, subscriptionID)
 }
 
+// getSubscriptionByEmail returns the subscription of an address in the
+// project, comparing the normalized emails
+func getSubscriptionByEmail(projectID int64, email string) (*Subscription, error) {
+	query := `
+		SELECT
+		  subscription_id,
+		  project_id,
+		  email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
+		  created_at,
+		  updated_at
+		FROM
+		  subscriptions
+		WHERE
+		  project_id = $1
+		  AND normalized_email = $2
+	`
+
+	return scanSubscription(query, projectID, utils.NormalizeEmail(email))
+}
+
+// getSubscriptionsWithUnicodeEmail returns the subscriptions of every
+// project whose normalized email has a domain with characters outside of
+// ASCII, stored before domains were converted to punycode
+func getSubscriptionsWithUnicodeEmail() ([]*Subscription, error) {
+	query := `
+		SELECT
+		  subscription_id,
+		  project_id,
+		  email,
+		  tracking_opt_out,
+		  name,
+		  locale,
+		  timezone,
+		  attributes,
+		  tags,
+		  topics,
+		  frequency,
+		  validation,
+		  created_at,
+		  updated_at
+		FROM
+		  subscriptions
+		WHERE
+		  OCTET_LENGTH(SUBSTRING(normalized_email FROM '[^@]*$')) <> CHAR_LENGTH(SUBSTRING(normalized_email FROM '[^@]*$'))
+		ORDER BY
+		  subscription_id
+	`
+
+	return scanSubscriptions(query)
+}
+
+// updateNormalizedEmail normalizes the email of a subscription again
+func updateNormalizedEmail(s *Subscription) error {
+	query := `UPDATE subscriptions SET normalized_email = $1 WHERE subscription_id = $2`
+
+	if err := database.Exec(query, utils.NormalizeEmail(s.Email), s.ID); err != nil {
+		return fmt.Errorf("failed updating normalized email: %v", err)
+	}
+
+	return nil
+}
+
+// getValidationSettings returns the validation policies of the project,
+// which are part of its settings
+func getValidationSettings(projectID int64) (*validation.Settings, error) {
+	query := `SELECT settings->'validation' FROM projects WHERE project_id = $1`
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	settings := validation.DefaultSettings()
+	if err := db.QueryRow(query, projectID).Scan(&settings); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan validation settings: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return &settings, nil
+}
+
 // updateSubscription updates a subscription in the database
 func updateSubscription(s *Subscription) error {
-	// only allows updates to the email field, the other fields are immutable
-	query := `UPDATE subscriptions SET email=$1 WHERE subscription_id=$2`
+	// only allows updates to the email, tracking preferences, frequency
+	// and subscriber fields, the other fields are immutable
+	query := `
+		UPDATE
+		  subscriptions
+		SET
+		  email=$1,
+		  normalized_email=$11,
+		  tracking_opt_out=$2,
+		  name=$3,
+		  locale=$4,
+		  timezone=$5,
+		  attributes=$6,
+		  tags=COALESCE($7, '{}'::TEXT[]),
+		  topics=COALESCE($8, '{}'::TEXT[]),
+		  frequency=$9,
+		  validation=$12
+		WHERE
+		  subscription_id=$10
+	`
 
-	if err := database.Exec(query, s.Email, s.ID); err != nil {
+	if err := database.Exec(
+		query,
+		s.Email,
+		s.TrackingOptOut,
+		s.Name,
+		s.Locale,
+		s.Timezone,
+		s.Attributes,
+		pq.Array(s.Tags),
+		pq.Array(s.Topics),
+		s.Frequency,
+		s.ID,
+		utils.NormalizeEmail(s.Email),
+		s.Validation,
+	); err != nil {
 		return fmt.Errorf("failed updating subscription: %v", err)
 	}
 
@@ -109,7 +627,7 @@ func scanSubscription(query string, params ...interface{}) (*Subscription, error
 	row := db.QueryRow(query, params...)
 	s := New()
 
-	if err := row.Scan(&s.ID, &s.ProjectID, &s.Email, &s.CreatedAt, &s.UpdatedAt); err != nil {
+	if err := row.Scan(&s.ID, &s.ProjectID, &s.Email, &s.TrackingOptOut, &s.Name, &s.Locale, &s.Timezone, &s.Attributes, pq.Array(&s.Tags), pq.Array(&s.Topics), &s.Frequency, &s.Validation, &s.CreatedAt, &s.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to scan subscription row: %v", err)
 		}
@@ -141,7 +659,7 @@ func scanSubscriptions(query string, params ...interface{}) ([]*Subscription, er
 	for rows.Next() {
 		s := New()
 
-		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Email, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Email, &s.TrackingOptOut, &s.Name, &s.Locale, &s.Timezone, &s.Attributes, pq.Array(&s.Tags), pq.Array(&s.Topics), &s.Frequency, &s.Validation, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return subscriptions, fmt.Errorf("unable to scan a subscription row: %v", err)
 		}
 
@@ -1,6 +1,13 @@
 package subscription
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/statictask/newsletter/internal/utils"
+	"github.com/statictask/newsletter/pkg/validation"
+)
 
 // ProjectSubscriptions is the entity used for controlling
 // interactions with many subscriptions in the database
@@ -24,6 +31,93 @@ func (ps *ProjectSubscriptions) All() ([]*Subscription, error) {
 	return subscriptions, nil
 }
 
+// Deliverable returns the subscriptions of the project that can receive
+// emails, leaving out addresses suppressed by bounces and complaints
+func (ps *ProjectSubscriptions) Deliverable() ([]*Subscription, error) {
+	return ps.DeliverableMatching(nil)
+}
+
+// DeliverableMatching returns the deliverable subscriptions of the project
+// that match the filter, all of them when it's nil
+func (ps *ProjectSubscriptions) DeliverableMatching(f *Filter) ([]*Subscription, error) {
+	subscriptions, err := getDeliverableSubscriptions(ps.projectID, f)
+	if err != nil {
+		return subscriptions, fmt.Errorf("unable to get deliverable subscriptions: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// Each calls fn with every subscription of the project that matches the
+// filter, suppressed or not, while they are read from the database
+func (ps *ProjectSubscriptions) Each(f *Filter, fn func(*Subscription) error) error {
+	if err := eachSubscription(ps.projectID, f, fn); err != nil {
+		return fmt.Errorf("unable to read subscriptions: %v", err)
+	}
+
+	return nil
+}
+
+// Count returns how many subscriptions of the project match the filter,
+// all of them when it's nil
+func (ps *ProjectSubscriptions) Count(f *Filter) (*Count, error) {
+	count, err := countSubscriptions(ps.projectID, f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to count subscriptions: %v", err)
+	}
+
+	return count, nil
+}
+
+// Tag adds and removes tags of many subscriptions of the project at once,
+// selected by their ids or emails. It returns how many subscriptions
+// were changed.
+func (ps *ProjectSubscriptions) Tag(subscriptionIDs []int64, emails, add, remove []string) (int64, error) {
+	add, err := NormalizeTags(add)
+	if err != nil {
+		return 0, err
+	}
+
+	remove, err = NormalizeTags(remove)
+	if err != nil {
+		return 0, err
+	}
+
+	normalizedEmails := make([]string, len(emails))
+	for i, e := range emails {
+		normalizedEmails[i] = utils.NormalizeEmail(e)
+	}
+
+	changed, err := tagSubscriptions(ps.projectID, subscriptionIDs, normalizedEmails, add, remove)
+	if err != nil {
+		return 0, fmt.Errorf("unable to tag subscriptions: %v", err)
+	}
+
+	return changed, nil
+}
+
+// AwaitingWelcome returns the deliverable subscriptions created in the
+// last maxAge that didn't receive the welcome email of the project
+func (ps *ProjectSubscriptions) AwaitingWelcome(maxAge time.Duration) ([]*Subscription, error) {
+	subscriptions, err := getSubscriptionsAwaitingWelcome(ps.projectID, maxAge)
+	if err != nil {
+		return subscriptions, fmt.Errorf("unable to get subscriptions awaiting welcome: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// AwaitingDigest returns the deliverable subscriptions with deferred
+// posts whose digest is due
+func (ps *ProjectSubscriptions) AwaitingDigest() ([]*Subscription, error) {
+	subscriptions, err := getSubscriptionsAwaitingDigest(ps.projectID)
+	if err != nil {
+		return subscriptions, fmt.Errorf("unable to get subscriptions awaiting digest: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
 // Get a single subscription based on the project and the subscriptionID
 func (ps *ProjectSubscriptions) Get(subscriptionID int64) (*Subscription, error) {
 	subscription, err := getSubscription(ps.projectID, subscriptionID)
@@ -34,6 +128,40 @@ func (ps *ProjectSubscriptions) Get(subscriptionID int64) (*Subscription, error)
 	return subscription, nil
 }
 
+// GetByEmail returns the subscription of an address in the project,
+// ignoring the case and the encoding of its domain
+func (ps *ProjectSubscriptions) GetByEmail(email string) (*Subscription, error) {
+	subscription, err := getSubscriptionByEmail(ps.projectID, email)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get subscription by email: %v", err)
+	}
+
+	return subscription, nil
+}
+
+// CheckEmail validates the address of the subscription with the policies
+// of the project, storing the result in the subscription. Addresses
+// refused by a policy return a validation.RejectedError.
+func (ps *ProjectSubscriptions) CheckEmail(ctx context.Context, s *Subscription) error {
+	settings, err := getValidationSettings(ps.projectID)
+	if err != nil {
+		return fmt.Errorf("unable to get validation settings: %v", err)
+	}
+
+	if settings == nil {
+		return fmt.Errorf("project %d not found", ps.projectID)
+	}
+
+	result, err := validation.NewValidator(*settings, nil).Check(ctx, s.Email)
+	if err != nil {
+		return err
+	}
+
+	s.Validation = result
+
+	return nil
+}
+
 // Delete deletes a subscription based on its ID
 func (ps *ProjectSubscriptions) Delete(subscriptionID int64) error {
 	if err := deleteSubscription(subscriptionID, ps.projectID); err != nil {
@@ -43,7 +171,9 @@ func (ps *ProjectSubscriptions) Delete(subscriptionID int64) error {
 	return nil
 }
 
-// Add creates a new entry in the project's subscriptions
+// Add creates a new entry in the project's subscriptions. Adding an
+// address that is already subscribed loads its subscription and returns
+// ErrAlreadySubscribed.
 func (ps *ProjectSubscriptions) Add(s *Subscription) error {
 	// make sure the subscription has the corred ProjectID before adding
 	s.ProjectID = ps.projectID
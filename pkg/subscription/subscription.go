@@ -1,27 +1,55 @@
 package subscription
 
 import (
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/statictask/newsletter/pkg/validation"
 )
 
+// ErrAlreadySubscribed is returned when creating a subscription of an
+// address that is already subscribed to the project, which is loaded
+// into the subscription instead
+var ErrAlreadySubscribed = errors.New("email is already subscribed")
+
 type Subscription struct {
-	ID        int64     `json:"subscription_id"`
-	Email     string    `json:"email"`
-	ProjectID int64     `json:"project_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64  `json:"subscription_id"`
+	Email     string `json:"email"`
+	ProjectID int64  `json:"project_id"`
+	// TrackingOptOut disables open and click tracking for this subscriber
+	// even if it's enabled for the project
+	TrackingOptOut bool `json:"tracking_opt_out"`
+	// Name, Locale and Timezone personalize the subscriber's emails,
+	// Attributes are custom fields available to templates as well
+	Name       string     `json:"name"`
+	Locale     string     `json:"locale"`
+	Timezone   string     `json:"timezone"`
+	Attributes Attributes `json:"attributes"`
+	// Tags group subscribers, segments select them by their tags
+	Tags []string `json:"tags"`
+	// Topics are the feed categories the subscriber reads, subscribers
+	// without topics read everything
+	Topics []string `json:"topics"`
+	// Frequency says if the subscriber receives each post as it's
+	// published or a digest of them every week or month
+	Frequency Frequency `json:"frequency"`
+	// Validation has the checks of the address made when it subscribed,
+	// like the ones flagged by the policies of the project
+	Validation *validation.Result `json:"validation"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
 }
 
 // New returns an empty Subscription
 func New() *Subscription {
-	return &Subscription{}
+	return &Subscription{Attributes: Attributes{}, Tags: []string{}, Topics: []string{}, Frequency: Immediate}
 }
 
 // Create the subscription in the database
 func (s *Subscription) Create() error {
 	if err := insertSubscription(s); err != nil {
-		return fmt.Errorf("unable to create subscription: %v", err)
+		return fmt.Errorf("unable to create subscription: %w", err)
 	}
 
 	return nil
@@ -45,9 +73,23 @@ func (s *Subscription) Delete() error {
 	return nil
 }
 
-// GetName returns the Email
+// MarkDigestSent records that the subscriber received a digest now, so
+// the next one is due a week or a month later
+func (s *Subscription) MarkDigestSent() error {
+	if err := updateLastDigestAt(s.ID); err != nil {
+		return fmt.Errorf("unable to mark digest as sent: %v", err)
+	}
+
+	return nil
+}
+
+// GetName returns the Name, or the Email if the subscriber has no name
 func (s *Subscription) GetName() string {
-	return s.Email
+	if s.Name == "" {
+		return s.Email
+	}
+
+	return s.Name
 }
 
 // GetAddress returns the Email
@@ -0,0 +1,162 @@
+package suppression
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+// maxWebhookBodySize limits the size of webhook payloads, SendGrid
+// batches events in requests of a few megabytes at most
+const maxWebhookBodySize = 10 << 20
+
+// ReceiveSendGridEvents ingests bounces and spam reports sent by the
+// SendGrid Event Webhook
+func ReceiveSendGridEvents(w http.ResponseWriter, r *http.Request) {
+	_log := log.L.With(zap.String("source", "sendgrid"))
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		_log.Error("Failed reading request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	signature := r.Header.Get(SendGridSignatureHeader)
+	timestamp := r.Header.Get(SendGridTimestampHeader)
+
+	if err := VerifySendGridSignature(config.C.SendGridWebhookPublicKey, signature, timestamp, body); err != nil {
+		_log.Error("Failed verifying webhook signature.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	events, err := ParseSendGridEvents(body)
+	if err != nil {
+		_log.Error("Failed parsing webhook events.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	recordEvents(w, _log, events)
+}
+
+// ReceiveEvents ingests bounces and complaints in the generic format,
+// used by providers without a dedicated endpoint
+func ReceiveEvents(w http.ResponseWriter, r *http.Request) {
+	_log := log.L.With(zap.String("source", "webhook"))
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		_log.Error("Failed reading request body.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := VerifySignature(config.C.WebhookSecret, r.Header.Get(SignatureHeader), body); err != nil {
+		_log.Error("Failed verifying webhook signature.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	events, err := ParseEvents(body)
+	if err != nil {
+		_log.Error("Failed parsing webhook events.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	recordEvents(w, _log, events)
+}
+
+// GetSuppressions returns all the suppressed addresses
+func GetSuppressions(w http.ResponseWriter, r *http.Request) {
+	suppressions, err := NewSuppressions().All()
+	if err != nil {
+		log.L.Error("Failed getting Suppressions.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, suppressions)
+}
+
+// DeleteSuppression allows a suppressed address to receive emails again
+func DeleteSuppression(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	suppressionID, err := strconv.Atoi(params["suppression_id"])
+	if err != nil {
+		log.L.Error("Failed parsing suppression_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id := int64(suppressionID)
+	_log := log.L.With(zap.Int64("suppression_id", id))
+
+	controller := NewSuppressions()
+
+	s, err := controller.Get(id)
+	if err != nil {
+		_log.Error("Failed getting Suppression.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if s == nil {
+		_log.Debug("Suppression not found.")
+		utils.WriteJSONResponseError(w, http.StatusNotFound, fmt.Errorf("suppression not found"))
+		return
+	}
+
+	if err := controller.Delete(id); err != nil {
+		_log.Error("Failed deleting Suppression.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_log.Info("Suppression deleted successfully.")
+
+	utils.WriteJSONResponseMessage(w, http.StatusOK, "suppression deleted successfully")
+}
+
+// recordEvents applies the valid events and writes the response, the
+// invalid ones are skipped before anything is written. Providers retry
+// the whole batch on errors, and the events with an ID that were
+// recorded before the error are ignored then. Events without an ID,
+// which generic webhooks may send, count soft bounces again.
+func recordEvents(w http.ResponseWriter, _log *zap.Logger, events []*Event) {
+	var valid []*Event
+	for _, e := range events {
+		if err := e.Validate(); err != nil {
+			_log.Warn("Skipping invalid webhook event.", zap.Error(err), zap.String("event_id", e.ID))
+			continue
+		}
+
+		valid = append(valid, e)
+	}
+
+	controller := NewSuppressions()
+
+	for _, e := range valid {
+		if err := controller.Record(e); err != nil {
+			_log.Error("Failed recording webhook event.", zap.Error(err), zap.String("reason", string(e.Reason)), zap.String("event_id", e.ID))
+			utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	skipped := len(events) - len(valid)
+	_log.Info("Webhook events recorded successfully.", zap.Int("events", len(valid)), zap.Int("skipped", skipped))
+
+	utils.WriteJSONResponseMessage(w, http.StatusOK, fmt.Sprintf("%d events recorded, %d skipped", len(valid), skipped))
+}
@@ -0,0 +1,235 @@
+package suppression
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// upsertSuppression inserts a suppression in the database, updating
+// the existing one if the address was already suppressed
+func upsertSuppression(s *Suppression) error {
+	query := `
+		INSERT INTO suppressions (
+		  email,
+		  reason,
+		  source
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3
+		)
+		ON CONFLICT (email) DO UPDATE SET
+		  reason = EXCLUDED.reason,
+		  source = EXCLUDED.source
+		RETURNING
+		  suppression_id,
+		  email,
+		  reason,
+		  source,
+		  created_at,
+		  updated_at
+	`
+
+	savedSuppression, err := scanSuppression(query, s.Email, s.Reason, s.Source)
+	if err != nil {
+		return err
+	}
+
+	*s = *savedSuppression
+
+	return nil
+}
+
+// getSuppressions returns all suppressions in the database
+func getSuppressions() ([]*Suppression, error) {
+	query := `
+		SELECT
+		  suppression_id,
+		  email,
+		  reason,
+		  source,
+		  created_at,
+		  updated_at
+		FROM
+		  suppressions
+		ORDER BY
+		  created_at
+		DESC
+	`
+
+	return scanSuppressions(query)
+}
+
+// getSuppressionByID returns a single suppression based on its ID
+func getSuppressionByID(suppressionID int64) (*Suppression, error) {
+	query := `
+		SELECT
+		  suppression_id,
+		  email,
+		  reason,
+		  source,
+		  created_at,
+		  updated_at
+		FROM
+		  suppressions
+		WHERE
+		  suppression_id = $1
+	`
+
+	return scanSuppression(query, suppressionID)
+}
+
+// deleteSuppression deletes a suppression and the soft bounces of its
+// address from the database
+func deleteSuppression(suppressionID int64) error {
+	query := `
+		WITH deleted AS (
+		  DELETE FROM suppressions WHERE suppression_id=$1 RETURNING email
+		)
+		DELETE FROM soft_bounces WHERE email IN (SELECT email FROM deleted)
+	`
+
+	if err := database.Exec(query, suppressionID); err != nil {
+		return fmt.Errorf("failed deleting suppression: %v", err)
+	}
+
+	return nil
+}
+
+// incrementSoftBounces adds a soft bounce to the address and returns
+// how many it has
+func incrementSoftBounces(email string) (int64, error) {
+	query := `
+		INSERT INTO soft_bounces (
+		  email,
+		  bounces
+		)
+		VALUES (
+		  $1,
+		  1
+		)
+		ON CONFLICT (email) DO UPDATE SET
+		  bounces = soft_bounces.bounces + 1
+		RETURNING
+		  bounces
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	var bounces int64
+	if err := db.QueryRow(query, email).Scan(&bounces); err != nil {
+		return 0, fmt.Errorf("unable to scan soft bounces: %v", err)
+	}
+
+	return bounces, nil
+}
+
+// claimEvent records the ID of a provider event, returning false when it
+// was recorded already
+func claimEvent(source, eventID string) (bool, error) {
+	query := `
+		INSERT INTO webhook_events (
+		  source,
+		  event_id
+		)
+		VALUES (
+		  $1,
+		  $2
+		)
+		ON CONFLICT (source, event_id) DO NOTHING
+		RETURNING
+		  event_id
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return false, err
+	}
+
+	defer db.Close()
+
+	var id string
+	if err := db.QueryRow(query, source, eventID).Scan(&id); err != nil {
+		if err != sql.ErrNoRows {
+			return false, fmt.Errorf("unable to claim webhook event: %v", err)
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// releaseEvent forgets the ID of a provider event that failed to be
+// recorded, so it's recorded when the provider sends it again
+func releaseEvent(source, eventID string) error {
+	query := `DELETE FROM webhook_events WHERE source = $1 AND event_id = $2`
+
+	if err := database.Exec(query, source, eventID); err != nil {
+		return fmt.Errorf("unable to release webhook event: %v", err)
+	}
+
+	return nil
+}
+
+// scanSuppression returns a single suppression that matches the given query
+func scanSuppression(query string, params ...interface{}) (*Suppression, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	s := New()
+
+	if err := row.Scan(&s.ID, &s.Email, &s.Reason, &s.Source, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan suppression row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return s, nil
+}
+
+// scanSuppressions returns multiple suppressions that match the given query
+func scanSuppressions(query string, params ...interface{}) ([]*Suppression, error) {
+	var suppressions []*Suppression
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return suppressions, fmt.Errorf("unable to execute `%s`: %v", query, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		s := New()
+
+		if err := rows.Scan(&s.ID, &s.Email, &s.Reason, &s.Source, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return suppressions, fmt.Errorf("unable to scan a suppression row: %v", err)
+		}
+
+		suppressions = append(suppressions, s)
+	}
+
+	return suppressions, nil
+}
@@ -0,0 +1,63 @@
+package suppression
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+type Reason string
+
+const (
+	HardBounce Reason = "hard_bounce"
+	SoftBounce Reason = "soft_bounce"
+	Complaint  Reason = "complaint"
+)
+
+var Reasons = []Reason{HardBounce, SoftBounce, Complaint}
+
+// IsValid says if the reason is one of the supported Reasons
+func (r Reason) IsValid() bool {
+	for _, reason := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Suppression is an address that must not receive emails from any
+// project, because it bounced or complained about them
+type Suppression struct {
+	ID        int64     `json:"suppression_id"`
+	Email     string    `json:"email"`
+	Reason    Reason    `json:"reason"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// New returns an empty Suppression
+func New() *Suppression {
+	return &Suppression{}
+}
+
+// Create the Suppression in the database. Suppressing an address twice
+// updates the reason and the source of the existing entry.
+func (s *Suppression) Create() error {
+	s.Email = NormalizeEmail(s.Email)
+
+	if err := upsertSuppression(s); err != nil {
+		return fmt.Errorf("unable to create suppression: %v", err)
+	}
+
+	return nil
+}
+
+// NormalizeEmail returns the address in the form stored in suppressions,
+// which is the normalized email of the subscriptions
+func NormalizeEmail(email string) string {
+	return utils.NormalizeEmail(email)
+}
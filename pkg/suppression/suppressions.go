@@ -0,0 +1,102 @@
+package suppression
+
+import (
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// Suppressions is the entity used for controlling
+// interactions with many suppressions in the database
+type Suppressions struct{}
+
+// NewSuppressions returns a Suppressions controller
+func NewSuppressions() *Suppressions {
+	return &Suppressions{}
+}
+
+// All returns all the suppressed addresses
+func (ss *Suppressions) All() ([]*Suppression, error) {
+	suppressions, err := getSuppressions()
+	if err != nil {
+		return suppressions, fmt.Errorf("unable to get suppressions: %v", err)
+	}
+
+	return suppressions, nil
+}
+
+// Get returns a single suppression according to its ID
+func (ss *Suppressions) Get(suppressionID int64) (*Suppression, error) {
+	suppression, err := getSuppressionByID(suppressionID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get suppression: %v", err)
+	}
+
+	return suppression, nil
+}
+
+// Delete removes the suppression, allowing the address to receive
+// emails again, and resets its soft bounces
+func (ss *Suppressions) Delete(suppressionID int64) error {
+	if err := deleteSuppression(suppressionID); err != nil {
+		return fmt.Errorf("unable to delete suppression: %v", err)
+	}
+
+	return nil
+}
+
+// Record applies a bounce or complaint reported by an email provider.
+// Hard bounces and complaints suppress the address immediately, while
+// soft bounces do it once they reach the configured threshold. Events
+// with an ID are recorded once, the ones sent again are ignored.
+func (ss *Suppressions) Record(e *Event) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	if e.ID == "" {
+		return record(e)
+	}
+
+	claimed, err := claimEvent(e.Source, e.ID)
+	if err != nil {
+		return err
+	}
+
+	if !claimed {
+		return nil
+	}
+
+	if err := record(e); err != nil {
+		if releaseErr := releaseEvent(e.Source, e.ID); releaseErr != nil {
+			return fmt.Errorf("%v, %v", err, releaseErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// record applies a valid event
+func record(e *Event) error {
+	email := NormalizeEmail(e.Email)
+
+	if e.Reason == SoftBounce {
+		bounces, err := incrementSoftBounces(email)
+		if err != nil {
+			return fmt.Errorf("unable to count soft bounce: %v", err)
+		}
+
+		if bounces < config.C.SoftBounceThreshold {
+			return nil
+		}
+	}
+
+	s := New()
+	s.Email = email
+	s.Reason = e.Reason
+	s.Source = e.Source
+
+	return s.Create()
+}
@@ -0,0 +1,182 @@
+package suppression
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/statictask/newsletter/pkg/validation"
+)
+
+const (
+	// SendGridSignatureHeader and SendGridTimestampHeader are sent by the
+	// SendGrid Event Webhook when signature verification is enabled
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+
+	// SignatureHeader carries the HMAC-SHA256 of the body of generic
+	// webhooks, hex encoded and prefixed by "sha256="
+	SignatureHeader = "X-Newsletter-Signature"
+
+	// SendGridTimestampTolerance is how far the timestamp of SendGrid
+	// events can be from now, older requests may be replayed
+	SendGridTimestampTolerance = 5 * time.Minute
+
+	// unsetSecret is the default of WEBHOOK_SECRET, which is public and
+	// can't authenticate anything
+	unsetSecret = "CHANGEME"
+)
+
+// Event is a bounce or complaint reported by an email provider. The ID
+// is the one given by the provider, which is optional in generic
+// webhooks but makes the retries of a batch safe.
+type Event struct {
+	ID     string `json:"id"`
+	Email  string `json:"email"`
+	Reason Reason `json:"type"`
+	Source string `json:"source"`
+}
+
+// Validate checks if the event has a valid address and reason
+func (e *Event) Validate() error {
+	if err := validation.CheckSyntax(strings.TrimSpace(e.Email)); err != nil {
+		return err
+	}
+
+	if !e.Reason.IsValid() {
+		return fmt.Errorf("invalid event type '%s', use one of %v", e.Reason, Reasons)
+	}
+
+	return nil
+}
+
+// sendGridEvent holds the fields of SendGrid events used to build
+// Events, the others are ignored
+type sendGridEvent struct {
+	ID    string `json:"sg_event_id"`
+	Email string `json:"email"`
+	Event string `json:"event"`
+	Type  string `json:"type"`
+}
+
+// ParseSendGridEvents converts the body of the SendGrid Event Webhook
+// into Events. Events other than bounces and spam reports are ignored.
+func ParseSendGridEvents(body []byte) ([]*Event, error) {
+	var sgEvents []*sendGridEvent
+	if err := json.Unmarshal(body, &sgEvents); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid events: %v", err)
+	}
+
+	events := []*Event{}
+	for _, sge := range sgEvents {
+		e := &Event{ID: sge.ID, Email: sge.Email, Source: "sendgrid"}
+
+		switch sge.Event {
+		case "bounce":
+			// blocked messages were rejected for temporary reasons,
+			// like the server reputation or a full mailbox
+			if sge.Type == "blocked" {
+				e.Reason = SoftBounce
+			} else {
+				e.Reason = HardBounce
+			}
+		case "spamreport":
+			e.Reason = Complaint
+		default:
+			continue
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// ParseEvents converts the body of generic webhooks, a JSON array of
+// Events, for providers without a dedicated endpoint. The events aren't
+// validated, the invalid ones are skipped when they are recorded.
+func ParseEvents(body []byte) ([]*Event, error) {
+	var events []*Event
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("invalid events: %v", err)
+	}
+
+	for _, e := range events {
+		if e.Source == "" {
+			e.Source = "webhook"
+		}
+	}
+
+	return events, nil
+}
+
+// VerifySendGridSignature checks the ECDSA signature of the SendGrid
+// Event Webhook using the verification key of the account, refusing the
+// timestamps outside of SendGridTimestampTolerance
+func VerifySendGridSignature(publicKey, signature, timestamp string, body []byte) error {
+	if publicKey == "" {
+		return fmt.Errorf("SendGrid webhook verification key isn't configured")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp '%s'", timestamp)
+	}
+
+	if skew := time.Since(time.Unix(seconds, 0)); skew > SendGridTimestampTolerance || skew < -SendGridTimestampTolerance {
+		return fmt.Errorf("timestamp is outside of the tolerance of %s", SendGridTimestampTolerance)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid SendGrid verification key: %v", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("invalid SendGrid verification key: %v", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("SendGrid verification key isn't an ECDSA key")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.VerifyASN1(ecdsaKey, hash[:], sig) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// VerifySignature checks the HMAC signature of generic webhooks, which
+// are refused while the secret isn't configured
+func VerifySignature(secret, signature string, body []byte) error {
+	if secret == "" || secret == unsetSecret {
+		return fmt.Errorf("webhook secret isn't configured")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimPrefix(signature, "sha256="))) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
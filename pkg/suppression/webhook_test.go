@@ -0,0 +1,206 @@
+package suppression
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseSendGridEvents(t *testing.T) {
+	body := []byte(`[
+		{"sg_event_id": "a", "email": "hard@example.com", "event": "bounce", "type": "bounce"},
+		{"sg_event_id": "b", "email": "soft@example.com", "event": "bounce", "type": "blocked"},
+		{"sg_event_id": "c", "email": "spam@example.com", "event": "spamreport"},
+		{"sg_event_id": "d", "email": "open@example.com", "event": "open"}
+	]`)
+
+	events, err := ParseSendGridEvents(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*Event{
+		{ID: "a", Email: "hard@example.com", Reason: HardBounce, Source: "sendgrid"},
+		{ID: "b", Email: "soft@example.com", Reason: SoftBounce, Source: "sendgrid"},
+		{ID: "c", Email: "spam@example.com", Reason: Complaint, Source: "sendgrid"},
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+
+	if _, err := ParseSendGridEvents([]byte(`{"event": "bounce"}`)); err == nil {
+		t.Fatal("expected an error for a body that isn't an array")
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []*Event
+		wantErr bool
+	}{
+		{
+			name: "default source",
+			body: `[{"id": "1", "email": "jane@example.com", "type": "hard_bounce"}]`,
+			want: []*Event{{ID: "1", Email: "jane@example.com", Reason: HardBounce, Source: "webhook"}},
+		},
+		{
+			name: "given source",
+			body: `[{"email": "jane@example.com", "type": "complaint", "source": "postmark"}]`,
+			want: []*Event{{Email: "jane@example.com", Reason: Complaint, Source: "postmark"}},
+		},
+		{
+			// invalid events are only skipped when recorded
+			name: "invalid reason",
+			body: `[{"email": "jane@example.com", "type": "open"}]`,
+			want: []*Event{{Email: "jane@example.com", Reason: "open", Source: "webhook"}},
+		},
+		{name: "empty", body: `[]`, want: []*Event{}},
+		{name: "not an array", body: `{"email": "jane@example.com"}`, wantErr: true},
+		{name: "malformed", body: `[{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ParseEvents([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(events, tt.want) {
+				t.Fatalf("events = %+v, want %+v", events, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		wantErr bool
+	}{
+		{"valid", Event{Email: "jane@example.com", Reason: HardBounce}, false},
+		{"surrounding spaces", Event{Email: " jane@example.com ", Reason: Complaint}, false},
+		{"missing email", Event{Reason: SoftBounce}, true},
+		{"invalid email", Event{Email: "jane", Reason: SoftBounce}, true},
+		{"missing reason", Event{Email: "jane@example.com"}, true},
+		{"invalid reason", Event{Email: "jane@example.com", Reason: "open"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.event.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySendGridSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed generating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed encoding key: %v", err)
+	}
+
+	publicKey := base64.StdEncoding.EncodeToString(der)
+	body := []byte(`[{"email": "jane@example.com", "event": "bounce"}]`)
+
+	sign := func(timestamp string, body []byte) string {
+		hash := sha256.Sum256(append([]byte(timestamp), body...))
+
+		sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		if err != nil {
+			t.Fatalf("failed signing: %v", err)
+		}
+
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+
+	unix := time.Now().Unix()
+	now := strconv.FormatInt(unix, 10)
+	old := strconv.FormatInt(time.Now().Add(-2*SendGridTimestampTolerance).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(2*SendGridTimestampTolerance).Unix(), 10)
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherDER, _ := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		signature string
+		timestamp string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", publicKey, sign(now, body), now, body, false},
+		{"missing key", "", sign(now, body), now, body, true},
+		{"invalid key", "key", sign(now, body), now, body, true},
+		{"other key", base64.StdEncoding.EncodeToString(otherDER), sign(now, body), now, body, true},
+		{"changed body", publicKey, sign(now, body), now, []byte(`[]`), true},
+		{"changed timestamp", publicKey, sign(now, body), strconv.FormatInt(unix-1, 10), body, true},
+		{"invalid timestamp", publicKey, sign("now", body), "now", body, true},
+		{"old timestamp", publicKey, sign(old, body), old, body, true},
+		{"future timestamp", publicKey, sign(future, body), future, body, true},
+		{"invalid signature encoding", publicKey, "%%%", now, body, true},
+		{"missing signature", publicKey, "", now, body, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySendGridSignature(tt.publicKey, tt.signature, tt.timestamp, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySendGridSignature error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`[{"email": "jane@example.com", "type": "hard_bounce"}]`)
+
+	sign := func(secret string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		wantErr   bool
+	}{
+		{"valid", "secret", sign("secret", body), false},
+		{"without prefix", "secret", sign("secret", body)[len("sha256="):], false},
+		{"other secret", "secret", sign("other", body), true},
+		{"missing signature", "secret", "", true},
+		{"changed body", "secret", sign("secret", []byte(`[]`)), true},
+		{"empty secret", "", sign("", body), true},
+		{"default secret", unsetSecret, sign(unsetSecret, body), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifySignature(tt.secret, tt.signature, body); (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySignature error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -23,6 +23,10 @@ func insertTask(t *Task) error {
 		  pipeline_id,
 		  task_type,
 		  task_status,
+		  sent,
+		  failed,
+		  rejected,
+		  total,
 		  created_at,
 		  updated_at
 	`
@@ -45,6 +49,10 @@ func getTaskByTypeAndPipelineID(taskType string, pipelineID int64) (*Task, error
 		  pipeline_id,
 		  task_type,
 		  task_status,
+		  sent,
+		  failed,
+		  rejected,
+		  total,
 		  created_at,
 		  updated_at
 		FROM
@@ -65,6 +73,10 @@ func getTasksByPipelineID(pipelineID int64) ([]*Task, error) {
 		  pipeline_id,
 		  task_type,
 		  task_status,
+		  sent,
+		  failed,
+		  rejected,
+		  total,
 		  created_at,
 		  updated_at
 		FROM
@@ -84,6 +96,10 @@ func getTasksByTypeAndStatus(taskType, taskStatus string) ([]*Task, error) {
 		  pipeline_id,
 		  task_type,
 		  task_status,
+		  sent,
+		  failed,
+		  rejected,
+		  total,
 		  created_at,
 		  updated_at
 		FROM
@@ -96,6 +112,34 @@ func getTasksByTypeAndStatus(taskType, taskStatus string) ([]*Task, error) {
 	return scanTasks(query, taskType, taskStatus)
 }
 
+// getTasksByProjectID returns all tasks of the project's pipelines
+func getTasksByProjectID(projectID int64) ([]*Task, error) {
+	query := `
+		SELECT
+		  t.task_id,
+		  t.pipeline_id,
+		  t.task_type,
+		  t.task_status,
+		  t.sent,
+		  t.failed,
+		  t.rejected,
+		  t.total,
+		  t.created_at,
+		  t.updated_at
+		FROM
+		  tasks AS t
+		JOIN pipelines AS pl
+		  ON t.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = $1
+		ORDER BY
+		  t.created_at
+		DESC
+	`
+
+	return scanTasks(query, projectID)
+}
+
 // updateTask updates a Task in the database
 func updateTask(t *Task) error {
 	// only allows update to the task_status field, the other fields are immutable
@@ -108,6 +152,36 @@ func updateTask(t *Task) error {
 	return nil
 }
 
+// retryRunningTasks makes the running tasks of the type ready again
+func retryRunningTasks(taskType string) error {
+	query := `
+		UPDATE
+		  tasks
+		SET
+		  task_status = 'Ready'
+		WHERE
+		  task_type = $1
+		  AND task_status = 'Running'
+	`
+
+	if err := database.Exec(query, taskType); err != nil {
+		return fmt.Errorf("failed updating running tasks: %v", err)
+	}
+
+	return nil
+}
+
+// updateTaskProgress updates the delivery counters of a Task in the database
+func updateTaskProgress(t *Task) error {
+	query := `UPDATE tasks SET sent=$1, failed=$2, rejected=$3, total=$4 WHERE task_id=$5`
+
+	if err := database.Exec(query, t.Sent, t.Failed, t.Rejected, t.Total, t.ID); err != nil {
+		return fmt.Errorf("failed updating task progress: %v", err)
+	}
+
+	return nil
+}
+
 // scanTask returns a single task that matches the given query
 func scanTask(query string, params ...interface{}) (*Task, error) {
 	db, err := database.Connect()
@@ -120,7 +194,7 @@ func scanTask(query string, params ...interface{}) (*Task, error) {
 	row := db.QueryRow(query, params...)
 	t := &Task{}
 
-	if err := row.Scan(&t.ID, &t.PipelineID, &t.Type, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+	if err := row.Scan(&t.ID, &t.PipelineID, &t.Type, &t.Status, &t.Sent, &t.Failed, &t.Rejected, &t.Total, &t.CreatedAt, &t.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to scan task row: %v", err)
 		}
@@ -152,7 +226,7 @@ func scanTasks(query string, params ...interface{}) ([]*Task, error) {
 	for rows.Next() {
 		t := NewTask()
 
-		if err := rows.Scan(&t.ID, &t.PipelineID, &t.Type, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.PipelineID, &t.Type, &t.Status, &t.Sent, &t.Failed, &t.Rejected, &t.Total, &t.CreatedAt, &t.UpdatedAt); err != nil {
 			return ts, fmt.Errorf("unable to scan task row: %v", err)
 		}
 
@@ -2,6 +2,8 @@ package task
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,12 +28,20 @@ var (
 )
 
 type Task struct {
-	ID         int64
-	PipelineID int64
-	Type       TaskType
-	Status     TaskStatus
-	CreatedAt  *time.Time
-	UpdatedAt  *time.Time
+	ID         int64      `json:"task_id"`
+	PipelineID int64      `json:"pipeline_id"`
+	Type       TaskType   `json:"task_type"`
+	Status     TaskStatus `json:"task_status"`
+	// Sent, Failed, Rejected and Total count the deliveries of Publish
+	// tasks. Failed emails exhausted their retries on transient errors,
+	// while Rejected ones were refused for permanent reasons. Scrape
+	// tasks count the items whose content couldn't be sanitized in Failed.
+	Sent      int64      `json:"sent"`
+	Failed    int64      `json:"failed"`
+	Rejected  int64      `json:"rejected"`
+	Total     int64      `json:"total"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
 }
 
 func NewTask() *Task {
@@ -56,6 +66,20 @@ func (t *Task) Update() error {
 	return nil
 }
 
+// UpdateProgress stores the delivery counters of the Task in the database
+func (t *Task) UpdateProgress() error {
+	if err := updateTaskProgress(t); err != nil {
+		return fmt.Errorf("unable to update task progress: %v", err)
+	}
+
+	return nil
+}
+
+// Progress describes the deliveries of the Task, like "sent 4,210 of 20,000"
+func (t *Task) Progress() string {
+	return fmt.Sprintf("sent %s of %s", formatCount(t.Sent), formatCount(t.Total))
+}
+
 // IsFinished says if the status of the task is Finished or not
 func (t *Task) IsFinished() bool {
 	return t.Status == Finished
@@ -70,3 +94,19 @@ func (t *Task) IsScrape() bool {
 func (t *Task) IsPublish() bool {
 	return t.Type == Publish
 }
+
+// formatCount formats the number using commas as thousands separators
+func formatCount(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+
+	var out strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+
+		out.WriteRune(d)
+	}
+
+	return out.String()
+}
@@ -12,3 +12,25 @@ func NewTasks() *Tasks {
 func (ts *Tasks) Filter(taskType TaskType, taskStatus TaskStatus) ([]*Task, error) {
 	return getTasksByTypeAndStatus(string(taskType), string(taskStatus))
 }
+
+// RetryRunning makes the tasks of the type left running when the
+// application stopped ready again, so they run from where they stopped
+func (ts *Tasks) RetryRunning(taskType TaskType) error {
+	return retryRunningTasks(string(taskType))
+}
+
+// ProjectTasks is the entity used for lazy controlling
+// interactions with the Tasks of all pipelines of a project
+type ProjectTasks struct {
+	projectID int64
+}
+
+// NewProjectTasks returns a ProjectTasks controller
+func NewProjectTasks(projectID int64) *ProjectTasks {
+	return &ProjectTasks{projectID}
+}
+
+// All returns the project's tasks, the most recent first
+func (pt *ProjectTasks) All() ([]*Task, error) {
+	return getTasksByProjectID(pt.projectID)
+}
@@ -32,6 +32,19 @@ func CreateEmailTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	layout, err := controller.Layout()
+	if err != nil {
+		_log.Error("Failed getting project email layout.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = et.Validate(layout); err != nil {
+		_log.Error("Invalid EmailTemplate.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	if err = controller.Add(et); err != nil {
 		_log.Error("Failed adding new EmailTemplate.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -143,6 +156,19 @@ func UpdateEmailTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	layout, err := controller.Layout()
+	if err != nil {
+		_log.Error("Failed getting project email layout.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := et.Validate(layout); err != nil {
+		_log.Error("Invalid EmailTemplate.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	if err := et.Update(); err != nil {
 		_log.Error("Failed updating EmailTemplate.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -227,7 +253,8 @@ func ActivateEmailTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentActiveEt, err := controller.GetActive()
+	// a project has one active template of each kind
+	currentActiveEt, err := controller.GetActiveOfKind(newActiveEt.Kind)
 	if err != nil {
 		_log.Error("Failed getting current active EmailTemplate.", zap.Error(err))
 		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
@@ -0,0 +1,224 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+type TemplateFormat string
+
+const (
+	FormatHTML     TemplateFormat = "html"
+	FormatMarkdown TemplateFormat = "markdown"
+	FormatMJML     TemplateFormat = "mjml"
+
+	// layoutYield is replaced by the compiled Markdown content when
+	// it's wrapped by a layout
+	layoutYield = "{{ yield }}"
+)
+
+var TemplateFormats = []TemplateFormat{FormatHTML, FormatMarkdown, FormatMJML}
+
+// DefaultLayout wraps Markdown templates of projects that didn't
+// define their own email layout
+const DefaultLayout = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{ .Title }}</title>
+  </head>
+  <body style="margin:0;padding:0;background-color:#f4f4f4;">
+    <table role="presentation" width="100%" cellpadding="0" cellspacing="0" border="0" style="background-color:#f4f4f4;">
+      <tr>
+        <td align="center" style="padding:24px 12px;">
+          <table role="presentation" width="600" cellpadding="0" cellspacing="0" border="0" style="width:100%;max-width:600px;background-color:#ffffff;">
+            <tr>
+              <td style="padding:24px;font-family:Arial,Helvetica,sans-serif;font-size:16px;line-height:1.5;color:#333333;">
+                {{ with .BrowserLink }}<p style="font-size:12px;color:#888888;"><a href="{{ . }}" style="color:#888888;">View in browser</a></p>{{ end }}
+                {{ yield }}
+              </td>
+            </tr>
+            <tr>
+              <td style="padding:24px;font-family:Arial,Helvetica,sans-serif;font-size:12px;line-height:1.5;color:#888888;">
+                <p>This newsletter is powered by <a href="https://statictask.io" style="color:#888888;">statictask.io</a>.</p>
+                <p>Don't want to receive this email anymore? <a href="{{ .UnsubscribeLink }}" style="color:#888888;">Unsubscribe</a> or <a href="{{ .PreferencesLink }}" style="color:#888888;">choose your topics</a>.</p>
+              </td>
+            </tr>
+          </table>
+        </td>
+      </tr>
+    </table>
+  </body>
+</html>
+`
+
+var (
+	// templateActionRegexp matches Go template actions, which must
+	// survive the compilation untouched
+	templateActionRegexp = regexp.MustCompile(`(?s){{.*?}}`)
+
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	compiledCache = &compileCache{entries: map[compileKey]string{}}
+)
+
+// IsValid says if the format is one of the supported TemplateFormats
+func (f TemplateFormat) IsValid() bool {
+	for _, tf := range TemplateFormats {
+		if f == tf {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileKey identifies a compiled revision of an EmailTemplate
+type compileKey struct {
+	emailTemplateID int64
+	revision        int64
+	layout          uint64
+}
+
+// compileCache keeps the compiled content of EmailTemplate revisions
+// in memory, so the compilation runs only once per revision
+type compileCache struct {
+	mu      sync.RWMutex
+	entries map[compileKey]string
+}
+
+func (c *compileCache) get(key compileKey) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	content, ok := c.entries[key]
+	return content, ok
+}
+
+func (c *compileCache) set(key compileKey, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// older revisions of the same template are never used again
+	for k := range c.entries {
+		if k.emailTemplateID == key.emailTemplateID && k.revision < key.revision {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = content
+}
+
+// Compile converts the EmailTemplate content into an HTML Go template
+// according to its Format. Markdown is rendered inside the given layout
+// (or DefaultLayout if it's empty) and MJML is converted into table-based
+// responsive HTML. The result is cached per template revision.
+func (et *EmailTemplate) Compile(layout string) (string, error) {
+	if et.Format == "" || et.Format == FormatHTML {
+		return et.Content, nil
+	}
+
+	if layout == "" {
+		layout = DefaultLayout
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(layout))
+	key := compileKey{et.ID, et.Revision, h.Sum64()}
+
+	// templates that weren't saved yet don't have a stable revision
+	if et.ID != 0 {
+		if content, ok := compiledCache.get(key); ok {
+			return content, nil
+		}
+	}
+
+	content, err := compile(et.Format, et.Content, layout)
+	if err != nil {
+		return "", fmt.Errorf("Failed compiling %s EmailTemplate: %v", et.Format, err)
+	}
+
+	if et.ID != 0 {
+		compiledCache.set(key, content)
+	}
+
+	return content, nil
+}
+
+// compile converts the content to HTML according to the given format
+// keeping Go template actions untouched
+func compile(format TemplateFormat, content, layout string) (string, error) {
+	protected, actions := protectActions(content)
+
+	var compiled string
+	switch format {
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(protected), &buf); err != nil {
+			return "", err
+		}
+
+		if !strings.Contains(layout, layoutYield) {
+			return "", fmt.Errorf("layout must contain %s", layoutYield)
+		}
+
+		// actions written in their own paragraph, like {{ range .Items }},
+		// must not be wrapped by <p> tags
+		compiled = buf.String()
+		for i := range actions {
+			compiled = strings.ReplaceAll(compiled, "<p>"+actionPlaceholder(i)+"</p>", actionPlaceholder(i))
+		}
+
+		compiled = strings.Replace(layout, layoutYield, restoreActions(compiled, actions), 1)
+		return compiled, nil
+
+	case FormatMJML:
+		var err error
+		if compiled, err = compileMJML(protected); err != nil {
+			return "", err
+		}
+
+		return restoreActions(compiled, actions), nil
+
+	default:
+		return "", fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+// protectActions replaces Go template actions by placeholders that are
+// not touched by the Markdown and MJML compilers
+func protectActions(content string) (string, []string) {
+	var actions []string
+
+	protected := templateActionRegexp.ReplaceAllStringFunc(content, func(action string) string {
+		actions = append(actions, action)
+		return actionPlaceholder(len(actions) - 1)
+	})
+
+	return protected, actions
+}
+
+// restoreActions puts back the Go template actions replaced by protectActions
+func restoreActions(content string, actions []string) string {
+	for i, action := range actions {
+		content = strings.ReplaceAll(content, actionPlaceholder(i), action)
+	}
+
+	return content
+}
+
+func actionPlaceholder(i int) string {
+	return fmt.Sprintf("GOTPLACTION%dX", i)
+}
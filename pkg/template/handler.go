@@ -14,6 +14,8 @@ func insertEmailTemplate(et *EmailTemplate) error {
 		INSERT INTO email_templates (
 		  project_id,
 		  name,
+		  kind,
+		  format,
 		  subject,
 		  content
 	        )
@@ -21,13 +23,18 @@ func insertEmailTemplate(et *EmailTemplate) error {
 		  $1,
 		  $2,
 		  $3,
-		  $4
+		  $4,
+		  $5,
+		  $6
 	        )
 		RETURNING
 		  email_template_id,
 		  project_id,
 		  name,
 		  is_active,
+		  kind,
+		  format,
+		  revision,
 		  subject,
 		  content,
 		  created_at,
@@ -38,6 +45,8 @@ func insertEmailTemplate(et *EmailTemplate) error {
 		query,
 		et.ProjectID,
 		et.Name,
+		et.Kind,
+		et.Format,
 		et.Subject,
 		et.Content,
 	)
@@ -58,6 +67,9 @@ func getEmailTemplateByID(id int64) (*EmailTemplate, error) {
 		  project_id,
 		  name,
 		  is_active,
+		  kind,
+		  format,
+		  revision,
 		  subject,
 		  content,
 		  created_at,
@@ -80,6 +92,9 @@ func getEmailTemplatesByProjectID(projectID int64) ([]*EmailTemplate, error) {
 		  project_id,
 		  name,
 		  is_active,
+		  kind,
+		  format,
+		  revision,
 		  subject,
 		  content,
 		  created_at,
@@ -93,15 +108,18 @@ func getEmailTemplatesByProjectID(projectID int64) ([]*EmailTemplate, error) {
 	return scanEmailTemplates(query, projectID)
 }
 
-// getLastEmailTemplateByProjectID returns all email_templates in the database based
-// on a given expression
-func getActiveEmailTemplateByProjectID(projectID int64) (*EmailTemplate, error) {
+// getActiveEmailTemplateByProjectID returns the active email_template of
+// the given kind in the project
+func getActiveEmailTemplateByProjectID(projectID int64, kind TemplateKind) (*EmailTemplate, error) {
 	query := `
 		SELECT
 		  email_template_id,
 		  project_id,
 		  name,
 		  is_active,
+		  kind,
+		  format,
+		  revision,
 		  subject,
 		  content,
 		  created_at,
@@ -110,13 +128,16 @@ func getActiveEmailTemplateByProjectID(projectID int64) (*EmailTemplate, error)
 		  email_templates
 		WHERE
 		  project_id = $1
+		  AND kind = $2
 		  AND is_active = true
 	`
 
-	return scanEmailTemplate(query, projectID)
+	return scanEmailTemplate(query, projectID, kind)
 }
 
 // updateEmailTemplate updates a single email_templates row in the database
+// and bumps its revision, so compiled content cached for the previous
+// revision is not used anymore
 func updateEmailTemplate(et *EmailTemplate) error {
 	query := `
 		UPDATE
@@ -124,16 +145,47 @@ func updateEmailTemplate(et *EmailTemplate) error {
 		SET
 		  name=$1,
 		  is_active=$2,
-		  subject=$3,
-		  content=$4
+		  kind=$3,
+		  format=$4,
+		  subject=$5,
+		  content=$6,
+		  revision=revision + 1
 		WHERE
-		  email_template_id = $5
+		  email_template_id = $7
+		RETURNING
+		  email_template_id,
+		  project_id,
+		  name,
+		  is_active,
+		  kind,
+		  format,
+		  revision,
+		  subject,
+		  content,
+		  created_at,
+		  updated_at
 	`
 
-	if err := database.Exec(query, et.Name, et.IsActive, et.Subject, et.Content, et.ID); err != nil {
+	savedEmailTemplate, err := scanEmailTemplate(
+		query,
+		et.Name,
+		et.IsActive,
+		et.Kind,
+		et.Format,
+		et.Subject,
+		et.Content,
+		et.ID,
+	)
+	if err != nil {
 		return fmt.Errorf("failed updating email_template: %v", err)
 	}
 
+	if savedEmailTemplate == nil {
+		return fmt.Errorf("email_template %d not found", et.ID)
+	}
+
+	*et = *savedEmailTemplate
+
 	return nil
 }
 
@@ -148,6 +200,26 @@ func deleteEmailTemplate(id int64) error {
 	return nil
 }
 
+// getProjectEmailLayout returns the layout of the project's Markdown
+// email_templates, which is empty when the default one is used
+func getProjectEmailLayout(projectID int64) (string, error) {
+	query := `SELECT email_layout FROM projects WHERE project_id = $1`
+
+	db, err := database.Connect()
+	if err != nil {
+		return "", err
+	}
+
+	defer db.Close()
+
+	var layout string
+	if err := db.QueryRow(query, projectID).Scan(&layout); err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("Failed scanning email_layout: %v", err)
+	}
+
+	return layout, nil
+}
+
 // scanEmailTemplate returns a single email_template based on the given query
 func scanEmailTemplate(query string, params ...interface{}) (*EmailTemplate, error) {
 	db, err := database.Connect()
@@ -160,7 +232,7 @@ func scanEmailTemplate(query string, params ...interface{}) (*EmailTemplate, err
 	row := db.QueryRow(query, params...)
 	et := New()
 
-	if err := row.Scan(&et.ID, &et.ProjectID, &et.Name, &et.IsActive, &et.Subject, &et.Content, &et.CreatedAt, &et.UpdatedAt); err != nil {
+	if err := row.Scan(&et.ID, &et.ProjectID, &et.Name, &et.IsActive, &et.Kind, &et.Format, &et.Revision, &et.Subject, &et.Content, &et.CreatedAt, &et.UpdatedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("Failed scanning email_templates row: %v", err)
 		}
@@ -192,7 +264,7 @@ func scanEmailTemplates(query string, params ...interface{}) ([]*EmailTemplate,
 	for rows.Next() {
 		et := New()
 
-		if err := rows.Scan(&et.ID, &et.ProjectID, &et.Name, &et.IsActive, &et.Subject, &et.Content, &et.CreatedAt, &et.UpdatedAt); err != nil {
+		if err := rows.Scan(&et.ID, &et.ProjectID, &et.Name, &et.IsActive, &et.Kind, &et.Format, &et.Revision, &et.Subject, &et.Content, &et.CreatedAt, &et.UpdatedAt); err != nil {
 			return ets, fmt.Errorf("Failed scanning email_templates row: %v", err)
 		}
 
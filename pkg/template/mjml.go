@@ -0,0 +1,362 @@
+package template
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mjmlEndingTags are MJML elements whose content is raw HTML, which is
+// kept as it is instead of being parsed as MJML
+var mjmlEndingTags = []string{"mj-text", "mj-button", "mj-raw", "mj-title", "mj-preview", "mj-style"}
+
+var mjmlEndingTagRegexps = func() map[string]*regexp.Regexp {
+	regexps := map[string]*regexp.Regexp{}
+	for _, tag := range mjmlEndingTags {
+		regexps[tag] = regexp.MustCompile(`(?s)(<` + tag + `(?:\s[^>]*)?>)(.*?)(</` + tag + `\s*>)`)
+	}
+
+	return regexps
+}()
+
+// mjmlNode is an element of the MJML document. Nodes without a name
+// are text between elements, such as Go template actions.
+type mjmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*mjmlNode
+	text     string
+}
+
+// attr returns the value of the attribute or the given default
+func (n *mjmlNode) attr(name, fallback string) string {
+	if v, ok := n.attrs[name]; ok && v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+// child returns the first child element with the given name
+func (n *mjmlNode) child(name string) *mjmlNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// mjmlCompiler converts an MJML document into table-based HTML
+type mjmlCompiler struct {
+	raw []string
+	out strings.Builder
+}
+
+// compileMJML converts the subset of MJML supported by the newsletter
+// (sections, columns, text, images, buttons, dividers, spacers and raw
+// HTML) into table-based responsive HTML
+func compileMJML(content string) (string, error) {
+	c := &mjmlCompiler{}
+
+	root, err := c.parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	if root.name != "mjml" {
+		return "", fmt.Errorf("MJML documents must start with <mjml>, found <%s>", root.name)
+	}
+
+	if err := c.render(root); err != nil {
+		return "", err
+	}
+
+	return c.out.String(), nil
+}
+
+// parse builds the MJML tree. The content of ending tags is replaced
+// by placeholders beforehand so raw HTML doesn't need to be valid XML.
+func (c *mjmlCompiler) parse(content string) (*mjmlNode, error) {
+	for _, tag := range mjmlEndingTags {
+		content = mjmlEndingTagRegexps[tag].ReplaceAllStringFunc(content, func(m string) string {
+			parts := mjmlEndingTagRegexps[tag].FindStringSubmatch(m)
+			c.raw = append(c.raw, parts[2])
+			return parts[1] + c.rawPlaceholder(len(c.raw)-1) + parts[3]
+		})
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var root *mjmlNode
+	var stack []*mjmlNode
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid MJML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &mjmlNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("MJML documents must have a single root element")
+				}
+
+				root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected closing tag </%s>", t.Name.Local)
+			}
+
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(stack) == 0 {
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, &mjmlNode{text: text})
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("empty MJML document")
+	}
+
+	return root, nil
+}
+
+func (c *mjmlCompiler) rawPlaceholder(i int) string {
+	return fmt.Sprintf("MJMLRAW%dX", i)
+}
+
+// inner returns the raw content of an ending tag
+func (c *mjmlCompiler) inner(n *mjmlNode) string {
+	var content strings.Builder
+	for _, child := range n.children {
+		content.WriteString(child.text)
+	}
+
+	text := content.String()
+	for i, raw := range c.raw {
+		text = strings.ReplaceAll(text, c.rawPlaceholder(i), raw)
+	}
+
+	return text
+}
+
+func (c *mjmlCompiler) write(format string, args ...interface{}) {
+	fmt.Fprintf(&c.out, format, args...)
+}
+
+func (c *mjmlCompiler) render(root *mjmlNode) error {
+	title, preview, style := "", "", ""
+	if head := root.child("mj-head"); head != nil {
+		if n := head.child("mj-title"); n != nil {
+			title = c.inner(n)
+		}
+
+		if n := head.child("mj-preview"); n != nil {
+			preview = c.inner(n)
+		}
+
+		if n := head.child("mj-style"); n != nil {
+			style = c.inner(n)
+		}
+	}
+
+	body := root.child("mj-body")
+	if body == nil {
+		return fmt.Errorf("MJML documents must have a <mj-body>")
+	}
+
+	width := pixels(body.attr("width", "600px"))
+	background := body.attr("background-color", "#ffffff")
+
+	c.write(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style type="text/css">
+body { margin:0; padding:0; -webkit-text-size-adjust:100%%; -ms-text-size-adjust:100%%; }
+table, td { border-collapse:collapse; mso-table-lspace:0pt; mso-table-rspace:0pt; }
+img { border:0; height:auto; line-height:100%%; outline:none; text-decoration:none; }
+@media only screen and (max-width:%dpx) {
+  .mj-column { display:block !important; width:100%% !important; max-width:100%% !important; }
+}
+%s
+</style>
+</head>
+<body style="margin:0;padding:0;background-color:%s;">
+`, title, width, style, attr(background))
+
+	if preview != "" {
+		c.write(`<div style="display:none;font-size:1px;line-height:1px;max-height:0;max-width:0;opacity:0;overflow:hidden;">%s</div>
+`, preview)
+	}
+
+	c.write(`<table role="presentation" width="100%%" cellpadding="0" cellspacing="0" border="0" style="background-color:%s;">
+<tr><td align="center">
+<table role="presentation" width="%d" cellpadding="0" cellspacing="0" border="0" style="width:100%%;max-width:%dpx;">
+`, attr(background), width, width)
+
+	for _, n := range body.children {
+		var err error
+		switch n.name {
+		case "":
+			c.write("%s\n", n.text)
+		case "mj-section":
+			err = c.renderSection(n, width)
+		case "mj-raw":
+			c.write("%s\n", c.inner(n))
+		default:
+			err = fmt.Errorf("unsupported element <%s> in <mj-body>", n.name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	c.write("</table>\n</td></tr>\n</table>\n</body>\n</html>\n")
+
+	return nil
+}
+
+func (c *mjmlCompiler) renderSection(section *mjmlNode, width int) error {
+	columns := 0
+	for _, n := range section.children {
+		if n.name == "mj-column" {
+			columns++
+		}
+	}
+
+	c.write(`<tr><td style="background-color:%s;padding:%s;">
+<table role="presentation" width="100%%" cellpadding="0" cellspacing="0" border="0"><tr>
+`, attr(section.attr("background-color", "transparent")), attr(section.attr("padding", "20px 0")))
+
+	for _, n := range section.children {
+		var err error
+		switch n.name {
+		case "":
+			c.write("%s\n", n.text)
+		case "mj-column":
+			err = c.renderColumn(n, columns)
+		case "mj-raw":
+			c.write("%s\n", c.inner(n))
+		default:
+			err = fmt.Errorf("unsupported element <%s> in <mj-section>", n.name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	c.write("</tr></table>\n</td></tr>\n")
+
+	return nil
+}
+
+func (c *mjmlCompiler) renderColumn(column *mjmlNode, columns int) error {
+	width := fmt.Sprintf("%d%%", 100/columns)
+	if w := column.attr("width", ""); w != "" {
+		width = w
+	}
+
+	c.write(`<td class="mj-column" valign="%s" width="%s" style="width:%s;vertical-align:%s;background-color:%s;padding:%s;">
+<table role="presentation" width="100%%" cellpadding="0" cellspacing="0" border="0">
+`, attr(column.attr("vertical-align", "top")), attr(width), attr(width),
+		attr(column.attr("vertical-align", "top")), attr(column.attr("background-color", "transparent")),
+		attr(column.attr("padding", "0")))
+
+	for _, n := range column.children {
+		switch n.name {
+		case "":
+			c.write("%s\n", n.text)
+		case "mj-text":
+			c.write(`<tr><td align="%s" style="padding:%s;"><div style="font-family:%s;font-size:%s;line-height:%s;color:%s;text-align:%s;">%s</div></td></tr>
+`, attr(n.attr("align", "left")), attr(n.attr("padding", "10px 25px")),
+				attr(n.attr("font-family", "Ubuntu, Helvetica, Arial, sans-serif")), attr(n.attr("font-size", "13px")),
+				attr(n.attr("line-height", "1.5")), attr(n.attr("color", "#000000")), attr(n.attr("align", "left")),
+				c.inner(n))
+		case "mj-image":
+			img := fmt.Sprintf(`<img src="%s" alt="%s" width="%d" style="display:block;width:100%%;max-width:%dpx;height:auto;border:0;">`,
+				attr(n.attr("src", "")), attr(n.attr("alt", "")), pixels(n.attr("width", "600px")), pixels(n.attr("width", "600px")))
+			if href := n.attr("href", ""); href != "" {
+				img = fmt.Sprintf(`<a href="%s" target="_blank">%s</a>`, attr(href), img)
+			}
+
+			c.write(`<tr><td align="%s" style="padding:%s;">%s</td></tr>
+`, attr(n.attr("align", "center")), attr(n.attr("padding", "10px 25px")), img)
+		case "mj-button":
+			background := attr(n.attr("background-color", "#414141"))
+			c.write(`<tr><td align="%s" style="padding:%s;"><table role="presentation" cellpadding="0" cellspacing="0" border="0"><tr><td align="center" bgcolor="%s" style="background-color:%s;border-radius:%s;"><a href="%s" target="_blank" style="display:inline-block;padding:%s;font-family:%s;font-size:%s;color:%s;text-decoration:none;">%s</a></td></tr></table></td></tr>
+`, attr(n.attr("align", "center")), attr(n.attr("padding", "10px 25px")), background, background,
+				attr(n.attr("border-radius", "3px")), attr(n.attr("href", "#")), attr(n.attr("inner-padding", "10px 25px")),
+				attr(n.attr("font-family", "Ubuntu, Helvetica, Arial, sans-serif")), attr(n.attr("font-size", "13px")),
+				attr(n.attr("color", "#ffffff")), c.inner(n))
+		case "mj-divider":
+			c.write(`<tr><td style="padding:%s;"><p style="border-top:%s %s %s;font-size:1px;margin:0 auto;width:100%%;"></p></td></tr>
+`, attr(n.attr("padding", "10px 25px")), attr(n.attr("border-style", "solid")),
+				attr(n.attr("border-width", "4px")), attr(n.attr("border-color", "#000000")))
+		case "mj-spacer":
+			height := attr(n.attr("height", "20px"))
+			c.write(`<tr><td style="height:%s;line-height:%s;font-size:0;">&#8202;</td></tr>
+`, height, height)
+		case "mj-raw":
+			c.write("%s\n", c.inner(n))
+		default:
+			return fmt.Errorf("unsupported element <%s> in <mj-column>", n.name)
+		}
+	}
+
+	c.write("</table>\n</td>\n")
+
+	return nil
+}
+
+// attr escapes MJML attribute values before writing them as HTML attributes
+func attr(value string) string {
+	return html.EscapeString(value)
+}
+
+// pixels converts values like "600px" to 600. Invalid values return 600,
+// the default width of MJML documents.
+func pixels(value string) int {
+	px, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px"))
+	if err != nil || px <= 0 {
+		return 600
+	}
+
+	return px
+}
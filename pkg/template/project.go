@@ -24,9 +24,9 @@ func (pt *ProjectEmailTemplates) Get(id int64) (*EmailTemplate, error) {
 	return getEmailTemplateByID(id)
 }
 
-// GetActive returns this project's active EmailTemplate
+// GetActive returns this project's active EmailTemplate of new posts
 func (pt *ProjectEmailTemplates) GetActive() (*EmailTemplate, error) {
-	emailTemplate, err := getActiveEmailTemplateByProjectID(pt.projectID)
+	emailTemplate, err := pt.GetActiveOfKind(KindIssue)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,23 @@ func (pt *ProjectEmailTemplates) GetActive() (*EmailTemplate, error) {
 	return emailTemplate, nil
 }
 
+// GetActiveOfKind returns this project's active EmailTemplate of the
+// given kind, or nil if none is active
+func (pt *ProjectEmailTemplates) GetActiveOfKind(kind TemplateKind) (*EmailTemplate, error) {
+	return getActiveEmailTemplateByProjectID(pt.projectID, kind)
+}
+
+// Layout returns the EmailLayout of the project, which wraps its Markdown
+// EmailTemplates
+func (pt *ProjectEmailTemplates) Layout() (string, error) {
+	layout, err := getProjectEmailLayout(pt.projectID)
+	if err != nil {
+		return "", fmt.Errorf("unable to get email layout: %v", err)
+	}
+
+	return layout, nil
+}
+
 // Add creates a new entry in the project's email_templates 
 func (pt *ProjectEmailTemplates) Add(et *EmailTemplate) error {
 	// make sure the EmailTemplate has the corred ProjectID before creating
@@ -57,6 +74,11 @@ func (pt *ProjectEmailTemplates) CreateDefault() (*EmailTemplate, error) {
 		         <title>{{ .Title }}</title>
 		       </head>
 		       <body>
+			 {{ with .BrowserLink }}
+			 <p>
+			   <a href="{{ . }}">View in browser</a>
+			 </p>
+			 {{ end }}
 		         <h1>
 			   {{ .Title }}
 		         </h1>
@@ -73,6 +95,12 @@ func (pt *ProjectEmailTemplates) CreateDefault() (*EmailTemplate, error) {
 			   {{ .Content }}
 			 </p>
 			 {{ end }}
+			 {{ if .MoreItems }}
+			 <hr>
+			 <p>
+			   <a href="{{ .MoreLink }}">And {{ .MoreItems }} more posts</a>
+			 </p>
+			 {{ end }}
 			 <br>
 			 <br>
 			 <br>
@@ -80,7 +108,7 @@ func (pt *ProjectEmailTemplates) CreateDefault() (*EmailTemplate, error) {
 			   This newsletter is powered by <a href="https://statictask.io">statictask.io</a>.
 			 </p>
 			 <p>
-			   Don't want to receive this email anymore? <a href="{{ .UnsubscribeLink }}">Unsubscribe</a>.
+			   Don't want to receive this email anymore? <a href="{{ .UnsubscribeLink }}">Unsubscribe</a> or <a href="{{ .PreferencesLink }}">choose your topics</a>.
 			 </p>
 			 <br>
 		       </body>
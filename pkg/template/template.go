@@ -7,16 +7,104 @@ import (
 	tpl "html/template"
 )
 
+type TemplateKind string
+
+const (
+	// KindIssue templates build the emails of new posts
+	KindIssue TemplateKind = "issue"
+	// KindWelcome templates build the email sent to new subscribers
+	KindWelcome TemplateKind = "welcome"
+)
+
+var TemplateKinds = []TemplateKind{KindIssue, KindWelcome}
+
 type DataItem struct {
 	Title    string
 	Link     string
-	Content  string
+	// Content is sanitized when the feed is scraped, so it's
+	// rendered as HTML instead of being escaped
+	Content  tpl.HTML
+	// Categories come from the feed
+	Categories []string
 }
 
 type Data struct {
 	Title            string
 	UnsubscribeLink  string
+	// PreferencesLink points to the page where subscribers choose the
+	// topics they read
+	PreferencesLink  string
+	// BrowserLink points to the issue in the web archive of the project,
+	// it's empty when the archive is private and in other emails
+	BrowserLink      string
+	// InBrowser is set when the issue is rendered for the web archive,
+	// where the links of the subscriber are left out
+	InBrowser        bool
 	Items            []*DataItem
+	// MoreItems is the number of items left out of the issue because
+	// of the project's limit, MoreLink points to where they can be read
+	MoreItems        int
+	MoreLink         string
+	// SenderName is the name the project uses in the From header
+	SenderName       string
+	// RecentPosts are the last posts of the project, only filled in
+	// welcome emails
+	RecentPosts      []*DataPost
+	// Posts are the posts gathered in a digest, with the items the
+	// subscriber reads, only filled in digest emails. Items has all of
+	// them, so issue templates render digests as well.
+	Posts            []*DataPost
+	// Subscriber is the recipient of the email
+	Subscriber       *DataSubscriber
+}
+
+// DataSubscriber holds the fields of the subscriber, its methods return
+// fallbacks for the ones that are unknown, like in
+// {{ .Subscriber.FirstNameOr "there" }}
+type DataSubscriber struct {
+	Email       string
+	Name        string
+	FirstName   string
+	Locale      string
+	Timezone    string
+	Attributes  map[string]interface{}
+}
+
+// NameOr returns the Name of the subscriber or the fallback if it's empty
+func (s *DataSubscriber) NameOr(fallback string) string {
+	if s.Name == "" {
+		return fallback
+	}
+
+	return s.Name
+}
+
+// FirstNameOr returns the FirstName of the subscriber or the fallback if
+// it's empty
+func (s *DataSubscriber) FirstNameOr(fallback string) string {
+	if s.FirstName == "" {
+		return fallback
+	}
+
+	return s.FirstName
+}
+
+// AttributeOr returns the custom field of the subscriber or the fallback
+// if it's not set
+func (s *DataSubscriber) AttributeOr(key string, fallback interface{}) interface{} {
+	if v, ok := s.Attributes[key]; ok && v != nil && v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+// DataPost is a post already sent by the project, or one of the posts
+// of a digest
+type DataPost struct {
+	Title    string
+	Date     time.Time
+	Items    []*DataItem
 }
 
 type EmailTemplate struct {
@@ -24,6 +112,9 @@ type EmailTemplate struct {
 	ProjectID  int64
 	Name 	   string
 	IsActive   bool
+	Kind       TemplateKind
+	Format     TemplateFormat
+	Revision   int64
 	Subject    string
 	Content    string
 	CreatedAt  time.Time
@@ -36,6 +127,14 @@ func New() *EmailTemplate {
 
 // Create the EmailTemplate record in the database
 func (et *EmailTemplate) Create() error {
+	if et.Format == "" {
+		et.Format = FormatHTML
+	}
+
+	if et.Kind == "" {
+		et.Kind = KindIssue
+	}
+
 	if err := insertEmailTemplate(et); err != nil {
 		return fmt.Errorf("Failed creating EmailTemplate: %v", err)
 	}
@@ -81,9 +180,74 @@ func (et *EmailTemplate) Deactivate() error {
 	return nil
 }
 
-// RenderContent receives data to build the email content
-func (et *EmailTemplate) RenderContent(data *Data) (string, error) {
-	return render(et.Content, data)
+// Validate checks if the EmailTemplate format is supported and if both
+// subject and content can be compiled and parsed. Markdown is compiled
+// inside the given layout, the one of the project, as it's when sent.
+func (et *EmailTemplate) Validate(layout string) error {
+	if et.Format == "" {
+		et.Format = FormatHTML
+	}
+
+	if !et.Format.IsValid() {
+		return fmt.Errorf("Invalid EmailTemplate format '%s', use one of %v", et.Format, TemplateFormats)
+	}
+
+	if et.Kind == "" {
+		et.Kind = KindIssue
+	}
+
+	if !et.Kind.IsValid() {
+		return fmt.Errorf("Invalid EmailTemplate kind '%s', use one of %v", et.Kind, TemplateKinds)
+	}
+
+	if _, err := tpl.New("subject").Parse(et.Subject); err != nil {
+		return fmt.Errorf("Invalid EmailTemplate subject: %v", err)
+	}
+
+	content := et.Content
+	if et.Format != FormatHTML {
+		if layout == "" {
+			layout = DefaultLayout
+		}
+
+		// the compiled cache isn't used as the revision of the content
+		// being validated wasn't saved yet
+		compiled, err := compile(et.Format, et.Content, layout)
+		if err != nil {
+			return fmt.Errorf("Invalid EmailTemplate content: %v", err)
+		}
+
+		content = compiled
+	}
+
+	if _, err := tpl.New("content").Parse(content); err != nil {
+		return fmt.Errorf("Invalid EmailTemplate content: %v", err)
+	}
+
+	return nil
+}
+
+// IsValid says if the kind is one of the supported TemplateKinds
+func (k TemplateKind) IsValid() bool {
+	for _, tk := range TemplateKinds {
+		if k == tk {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RenderContent receives data to build the email content. The content
+// is compiled according to the template format before the data is
+// applied, using the given layout for Markdown templates.
+func (et *EmailTemplate) RenderContent(layout string, data *Data) (string, error) {
+	content, err := et.Compile(layout)
+	if err != nil {
+		return "", err
+	}
+
+	return render(content, data)
 }
 
 // RenderSubject receives data to build the email subject
@@ -0,0 +1,37 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  TemplateFormat
+		content string
+		layout  string
+		wantErr bool
+	}{
+		{"html", FormatHTML, "<p>{{ .Title }}</p>", "", false},
+		{"invalid html", FormatHTML, "<p>{{ .Title </p>", "", true},
+		{"markdown with the default layout", FormatMarkdown, "# {{ .Title }}", "", false},
+		{"markdown with the project layout", FormatMarkdown, "# {{ .Title }}", "<html><body>{{ yield }}</body></html>", false},
+		{"layout without yield", FormatMarkdown, "# {{ .Title }}", "<html><body></body></html>", true},
+		{"invalid layout", FormatMarkdown, "# {{ .Title }}", "<html>{{ .Title </html>{{ yield }}", true},
+		{"invalid markdown", FormatMarkdown, "# {{ .Title", "", true},
+		{"unknown format", TemplateFormat("txt"), "text", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			et := New()
+			et.Subject = "{{ .Title }}"
+			et.Format = tt.format
+			et.Content = tt.content
+
+			if err := et.Validate(tt.layout); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
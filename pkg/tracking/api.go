@@ -0,0 +1,162 @@
+package tracking
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/log"
+	"github.com/statictask/newsletter/internal/utils"
+)
+
+// pixel is a transparent 1x1 GIF image
+var pixel, _ = base64.StdEncoding.DecodeString("R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7")
+
+// GetOpenPixel records the open of a post and returns the tracking pixel.
+// The image is always returned, so broken tokens don't show in emails.
+func GetOpenPixel(w http.ResponseWriter, r *http.Request) {
+	defer writePixel(w)
+
+	token, err := DecodeToken(mux.Vars(r)["token"])
+	if err != nil {
+		log.L.Debug("Failed decoding tracking token.", zap.Error(err))
+		return
+	}
+
+	_log := log.L.With(
+		zap.Int64("post_id", token.PostID),
+		zap.Int64("subscription_id", token.SubscriptionID),
+	)
+
+	e := NewEvent(Open)
+	e.PostID = token.PostID
+	e.SubscriptionID = token.SubscriptionID
+	e.UserAgent = r.UserAgent()
+	e.IsBot = isBotRequest(r)
+
+	if err := e.Create(); err != nil {
+		_log.Error("Failed creating open event.", zap.Error(err))
+		return
+	}
+
+	_log.Debug("Open event created successfully.", zap.Bool("is_bot", e.IsBot))
+}
+
+// RedirectClick records the click on a link of a post and redirects the
+// subscriber to the original URL
+func RedirectClick(w http.ResponseWriter, r *http.Request) {
+	token, err := DecodeToken(mux.Vars(r)["token"])
+	if err != nil {
+		log.L.Debug("Failed decoding tracking token.", zap.Error(err))
+		writeNotFoundPage(w)
+		return
+	}
+
+	_log := log.L.With(
+		zap.Int64("post_id", token.PostID),
+		zap.Int64("subscription_id", token.SubscriptionID),
+		zap.Int64("tracking_link_id", token.LinkID),
+	)
+
+	l, err := getLinkByID(token.LinkID)
+	if err != nil {
+		_log.Error("Failed getting tracking link.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if l == nil || l.PostID != token.PostID {
+		_log.Debug("Tracking link not found.")
+		writeNotFoundPage(w)
+		return
+	}
+
+	// recording the event must never prevent the subscriber from
+	// reaching the link
+	defer http.Redirect(w, r, l.URL, http.StatusFound)
+
+	e := NewEvent(Click)
+	e.PostID = token.PostID
+	e.SubscriptionID = token.SubscriptionID
+	e.LinkID = l.ID
+	e.UserAgent = r.UserAgent()
+
+	if err := flagClick(r, e, countRecentClicks); err != nil {
+		_log.Error("Failed counting recent clicks.", zap.Error(err))
+	}
+
+	if err := e.Create(); err != nil {
+		_log.Error("Failed creating click event.", zap.Error(err))
+		return
+	}
+
+	_log.Debug("Click event created successfully.", zap.Bool("is_bot", e.IsBot))
+}
+
+// GetPostStats returns the aggregated tracking events of a post
+func GetPostStats(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	projectID, err := strconv.Atoi(params["project_id"])
+	if err != nil {
+		log.L.Error("Failed parsing project_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log := log.L.With(zap.Int64("project_id", int64(projectID)))
+
+	postID, err := strconv.Atoi(params["post_id"])
+	if err != nil {
+		_log.Error("Failed parsing post_id.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_log = _log.With(zap.Int64("post_id", int64(postID)))
+
+	found, err := isProjectPost(int64(projectID), int64(postID))
+	if err != nil {
+		_log.Error("Failed getting Post.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !found {
+		_log.Debug("Post not found.")
+		utils.WriteJSONResponseError(w, http.StatusNotFound, fmt.Errorf("post not found"))
+		return
+	}
+
+	stats, err := NewPostTracking(int64(postID)).Stats()
+	if err != nil {
+		_log.Error("Failed getting Post stats.", zap.Error(err))
+		utils.WriteJSONResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONResponseData(w, http.StatusOK, stats)
+}
+
+// writePixel writes the tracking pixel, preventing it from being cached
+func writePixel(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+	w.Write(pixel)
+}
+
+// writeNotFoundPage writes the static 404 page
+func writeNotFoundPage(w http.ResponseWriter) {
+	tmpl := template.Must(template.ParseFiles("static/404/index.html"))
+
+	w.WriteHeader(http.StatusNotFound)
+	if err := tmpl.Execute(w, nil); err != nil {
+		log.L.Error("Failed rendering 404 page.", zap.Error(err))
+	}
+}
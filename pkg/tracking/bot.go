@@ -0,0 +1,60 @@
+package tracking
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// botUserAgentRegexp matches crawlers, link scanners of email security
+// gateways and HTTP libraries, which open and click every link
+var botUserAgentRegexp = regexp.MustCompile(
+	`(?i)bot|crawl|spider|slurp|preview|prefetch|scanner|proofpoint|mimecast|barracuda|` +
+		`facebookexternalhit|curl|wget|python|go-http-client|java/|okhttp|headless`,
+)
+
+// clickBurstSeconds is the interval in which clicks on different links of
+// the same delivery, a post sent to a subscriber, are considered to be
+// made by link scanners
+const clickBurstSeconds = 2
+
+// isBotRequest applies heuristics to tell if the request was made by
+// a machine instead of the subscriber
+func isBotRequest(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return true
+	}
+
+	// security gateways often prefetch links using these headers
+	if r.Header.Get("Purpose") == "prefetch" || r.Header.Get("X-Purpose") == "preview" {
+		return true
+	}
+
+	ua := r.UserAgent()
+
+	return ua == "" || botUserAgentRegexp.MatchString(ua)
+}
+
+// clickCounter returns how many clicks the subscriber made in other links
+// of the post during the last seconds
+type clickCounter func(subscriptionID, postID, linkID int64, seconds int) (int64, error)
+
+// flagClick sets IsBot for clicks made by machines. Besides the request
+// heuristics, a click following a click on another link of the same
+// delivery within clickBurstSeconds is flagged, as scanners open every
+// link of the email at once. Clicks of other subscribers or posts are
+// never compared, so humans clicking at the same time aren't flagged.
+func flagClick(r *http.Request, e *Event, count clickCounter) error {
+	e.IsBot = isBotRequest(r)
+	if e.IsBot || e.SubscriptionID == 0 {
+		return nil
+	}
+
+	recent, err := count(e.SubscriptionID, e.PostID, e.LinkID, clickBurstSeconds)
+	if err != nil {
+		return err
+	}
+
+	e.IsBot = recent > 0
+
+	return nil
+}
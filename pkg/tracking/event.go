@@ -0,0 +1,68 @@
+package tracking
+
+import (
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	Open  EventType = "open"
+	Click EventType = "click"
+)
+
+// Event is an open or a click of a subscriber in a delivered post
+type Event struct {
+	ID             int64     `json:"tracking_event_id"`
+	Type           EventType `json:"event_type"`
+	PostID         int64     `json:"post_id"`
+	SubscriptionID int64     `json:"subscription_id"`
+	LinkID         int64     `json:"tracking_link_id"`
+	UserAgent      string    `json:"user_agent"`
+	IsBot          bool      `json:"is_bot"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NewEvent returns an Event of the given type
+func NewEvent(eventType EventType) *Event {
+	return &Event{Type: eventType}
+}
+
+// Create the Event in the database
+func (e *Event) Create() error {
+	if err := insertEvent(e); err != nil {
+		return fmt.Errorf("unable to create tracking event: %v", err)
+	}
+
+	return nil
+}
+
+// Link is a URL of a post whose clicks are tracked
+type Link struct {
+	ID        int64     `json:"tracking_link_id"`
+	PostID    int64     `json:"post_id"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LinkStats aggregates the clicks of a single link
+type LinkStats struct {
+	LinkID       int64  `json:"tracking_link_id"`
+	URL          string `json:"url"`
+	Clicks       int64  `json:"clicks"`
+	UniqueClicks int64  `json:"unique_clicks"`
+}
+
+// Stats aggregates the events of a post, ignoring the ones made by bots
+type Stats struct {
+	PostID       int64        `json:"post_id"`
+	Opens        int64        `json:"opens"`
+	UniqueOpens  int64        `json:"unique_opens"`
+	Clicks       int64        `json:"clicks"`
+	UniqueClicks int64        `json:"unique_clicks"`
+	BotEvents    int64        `json:"bot_events"`
+	Links        []*LinkStats `json:"links"`
+}
@@ -0,0 +1,284 @@
+package tracking
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/statictask/newsletter/internal/database"
+)
+
+// insertEvent inserts a tracking event in the database
+func insertEvent(e *Event) error {
+	query := `
+		INSERT INTO tracking_events (
+		  event_type,
+		  post_id,
+		  subscription_id,
+		  tracking_link_id,
+		  user_agent,
+		  is_bot
+		)
+		VALUES (
+		  $1,
+		  $2,
+		  $3,
+		  $4,
+		  $5,
+		  $6
+		)
+		RETURNING
+		  tracking_event_id,
+		  event_type,
+		  post_id,
+		  subscription_id,
+		  tracking_link_id,
+		  user_agent,
+		  is_bot,
+		  created_at,
+		  updated_at
+	`
+
+	savedEvent, err := scanEvent(
+		query,
+		e.Type,
+		e.PostID,
+		nullID(e.SubscriptionID),
+		nullID(e.LinkID),
+		e.UserAgent,
+		e.IsBot,
+	)
+	if err != nil {
+		return err
+	}
+
+	*e = *savedEvent
+
+	return nil
+}
+
+// countRecentClicks returns how many clicks the subscriber made in other
+// links of the post during the last seconds, which are the clicks of the
+// same delivery
+func countRecentClicks(subscriptionID, postID, linkID int64, seconds int) (int64, error) {
+	query := `
+		SELECT
+		  COUNT(*)
+		FROM
+		  tracking_events
+		WHERE
+		  event_type = 'click'
+		  AND subscription_id = $1
+		  AND post_id = $2
+		  AND tracking_link_id <> $3
+		  AND created_at > CURRENT_TIMESTAMP - make_interval(secs => $4)
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	var count int64
+	if err := db.QueryRow(query, subscriptionID, postID, linkID, seconds).Scan(&count); err != nil {
+		return 0, fmt.Errorf("unable to count recent clicks: %v", err)
+	}
+
+	return count, nil
+}
+
+// upsertLink returns the tracking link of a post for the given URL,
+// creating it if it doesn't exist
+func upsertLink(postID int64, url string) (*Link, error) {
+	query := `
+		INSERT INTO tracking_links (
+		  post_id,
+		  url
+		)
+		VALUES (
+		  $1,
+		  $2
+		)
+		ON CONFLICT (post_id, url) DO UPDATE SET
+		  url = EXCLUDED.url
+		RETURNING
+		  tracking_link_id,
+		  post_id,
+		  url,
+		  created_at,
+		  updated_at
+	`
+
+	return scanLink(query, postID, url)
+}
+
+// getLinkByID returns a single tracking link based on its ID
+func getLinkByID(linkID int64) (*Link, error) {
+	query := `
+		SELECT
+		  tracking_link_id,
+		  post_id,
+		  url,
+		  created_at,
+		  updated_at
+		FROM
+		  tracking_links
+		WHERE
+		  tracking_link_id = $1
+	`
+
+	return scanLink(query, linkID)
+}
+
+// isProjectPost says if the post was created by one of the project pipelines
+func isProjectPost(projectID, postID int64) (bool, error) {
+	query := `
+		SELECT
+		  COUNT(*)
+		FROM
+		  posts AS p
+		JOIN pipelines AS pl
+		  ON p.pipeline_id = pl.pipeline_id
+		WHERE
+		  pl.project_id = $1
+		  AND p.post_id = $2
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return false, err
+	}
+
+	defer db.Close()
+
+	var count int64
+	if err := db.QueryRow(query, projectID, postID).Scan(&count); err != nil {
+		return false, fmt.Errorf("unable to check post project: %v", err)
+	}
+
+	return count > 0, nil
+}
+
+// getPostStats aggregates the tracking events of a post. Clicks count as
+// opens too, since many clients block the tracking pixel.
+func getPostStats(postID int64) (*Stats, error) {
+	query := `
+		SELECT
+		  COUNT(*) FILTER (WHERE event_type = 'open' AND NOT is_bot),
+		  COUNT(DISTINCT subscription_id) FILTER (WHERE NOT is_bot),
+		  COUNT(*) FILTER (WHERE event_type = 'click' AND NOT is_bot),
+		  COUNT(DISTINCT subscription_id) FILTER (WHERE event_type = 'click' AND NOT is_bot),
+		  COUNT(*) FILTER (WHERE is_bot)
+		FROM
+		  tracking_events
+		WHERE
+		  post_id = $1
+	`
+
+	linksQuery := `
+		SELECT
+		  l.tracking_link_id,
+		  l.url,
+		  COUNT(e.tracking_event_id) FILTER (WHERE NOT e.is_bot),
+		  COUNT(DISTINCT e.subscription_id) FILTER (WHERE NOT e.is_bot)
+		FROM
+		  tracking_links AS l
+		LEFT JOIN tracking_events AS e
+		  ON e.tracking_link_id = l.tracking_link_id
+		WHERE
+		  l.post_id = $1
+		GROUP BY
+		  l.tracking_link_id,
+		  l.url
+		ORDER BY
+		  3 DESC
+	`
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	stats := &Stats{PostID: postID, Links: []*LinkStats{}}
+
+	row := db.QueryRow(query, postID)
+	if err := row.Scan(&stats.Opens, &stats.UniqueOpens, &stats.Clicks, &stats.UniqueClicks, &stats.BotEvents); err != nil {
+		return nil, fmt.Errorf("unable to scan tracking stats row: %v", err)
+	}
+
+	rows, err := db.Query(linksQuery, postID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute `%s`: %v", linksQuery, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		ls := &LinkStats{}
+		if err := rows.Scan(&ls.LinkID, &ls.URL, &ls.Clicks, &ls.UniqueClicks); err != nil {
+			return nil, fmt.Errorf("unable to scan link stats row: %v", err)
+		}
+
+		stats.Links = append(stats.Links, ls)
+	}
+
+	return stats, nil
+}
+
+// scanEvent returns a single tracking event based on the given query
+func scanEvent(query string, params ...interface{}) (*Event, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	e := &Event{}
+
+	var subscriptionID, linkID sql.NullInt64
+	if err := row.Scan(&e.ID, &e.Type, &e.PostID, &subscriptionID, &linkID, &e.UserAgent, &e.IsBot, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan tracking event row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	e.SubscriptionID = subscriptionID.Int64
+	e.LinkID = linkID.Int64
+
+	return e, nil
+}
+
+// scanLink returns a single tracking link based on the given query
+func scanLink(query string, params ...interface{}) (*Link, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	row := db.QueryRow(query, params...)
+	l := &Link{}
+
+	if err := row.Scan(&l.ID, &l.PostID, &l.URL, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("unable to scan tracking link row: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	return l, nil
+}
+
+// nullID converts optional IDs to NULL when they're not set
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
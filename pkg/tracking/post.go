@@ -0,0 +1,51 @@
+package tracking
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PostTracking is the entity used for lazy controlling interactions
+// with links and events of a single post
+type PostTracking struct {
+	postID int64
+
+	mu    sync.Mutex
+	links map[string]*Link
+}
+
+// NewPostTracking returns a PostTracking controller
+func NewPostTracking(postID int64) *PostTracking {
+	return &PostTracking{postID: postID, links: map[string]*Link{}}
+}
+
+// Link returns the tracked link of the post for the given URL, creating
+// it if it doesn't exist yet. Links are cached since the same URLs are
+// requested for every subscriber.
+func (pt *PostTracking) Link(url string) (*Link, error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if l, ok := pt.links[url]; ok {
+		return l, nil
+	}
+
+	l, err := upsertLink(pt.postID, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get tracking link: %v", err)
+	}
+
+	pt.links[url] = l
+
+	return l, nil
+}
+
+// Stats returns the aggregated events of the post
+func (pt *PostTracking) Stats() (*Stats, error) {
+	stats, err := getPostStats(pt.postID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get tracking stats: %v", err)
+	}
+
+	return stats, nil
+}
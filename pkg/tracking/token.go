@@ -0,0 +1,90 @@
+package tracking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// unsetSecret is the default of TRACKING_SECRET, which is public, so the
+// tokens signed with it could be forged
+const unsetSecret = "CHANGEME"
+
+// Enabled says if TRACKING_SECRET was changed from its default. Emails
+// aren't tracked and tokens are refused until it is.
+func Enabled() bool {
+	return config.C.TrackingSecret != "" && config.C.TrackingSecret != unsetSecret
+}
+
+// Token identifies the delivery of a post to a subscriber in tracking
+// URLs. Click tokens also carry the clicked link.
+type Token struct {
+	SubscriptionID int64
+	PostID         int64
+	LinkID         int64
+}
+
+// NewToken returns a Token for the given delivery and link, use 0 as
+// linkID for open tokens
+func NewToken(subscriptionID, postID, linkID int64) *Token {
+	return &Token{subscriptionID, postID, linkID}
+}
+
+// Encode returns the signed representation of the token, which is
+// safe to be used in URLs
+func (t *Token) Encode() string {
+	payload := strings.Join([]string{
+		strconv.FormatInt(t.SubscriptionID, 36),
+		strconv.FormatInt(t.PostID, 36),
+		strconv.FormatInt(t.LinkID, 36),
+	}, "-")
+
+	return payload + "." + sign(payload)
+}
+
+// DecodeToken verifies the token signature and returns its content
+func DecodeToken(token string) (*Token, error) {
+	if !Enabled() {
+		return nil, fmt.Errorf("tracking is disabled until TRACKING_SECRET is configured")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed tracking token")
+	}
+
+	if !hmac.Equal([]byte(sign(parts[0])), []byte(parts[1])) {
+		return nil, fmt.Errorf("invalid tracking token signature")
+	}
+
+	ids := strings.Split(parts[0], "-")
+	if len(ids) != 3 {
+		return nil, fmt.Errorf("malformed tracking token")
+	}
+
+	var values [3]int64
+	for i, id := range ids {
+		v, err := strconv.ParseInt(id, 36, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed tracking token: %v", err)
+		}
+
+		values[i] = v
+	}
+
+	return NewToken(values[0], values[1], values[2]), nil
+}
+
+// sign returns a truncated HMAC of the payload, enough to make
+// tokens impossible to forge while keeping URLs short
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.C.TrackingSecret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
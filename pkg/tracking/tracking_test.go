@@ -0,0 +1,185 @@
+package tracking
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+func TestMain(m *testing.M) {
+	config.Initialize()
+	config.C.TrackingSecret = "test-secret"
+
+	os.Exit(m.Run())
+}
+
+// withSecret runs the test with TRACKING_SECRET set to the secret
+func withSecret(t *testing.T, secret string) {
+	t.Helper()
+
+	previous := config.C.TrackingSecret
+	config.C.TrackingSecret = secret
+	t.Cleanup(func() { config.C.TrackingSecret = previous })
+}
+
+func TestToken(t *testing.T) {
+	for _, want := range []*Token{NewToken(1, 2, 3), NewToken(123456789, 42, 0), NewToken(0, 7, 0)} {
+		token, err := DecodeToken(want.Encode())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if *token != *want {
+			t.Fatalf("token = %+v, want %+v", token, want)
+		}
+	}
+
+	encoded := NewToken(10, 20, 30).Encode()
+	payload, signature, _ := strings.Cut(encoded, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"without signature", payload},
+		{"changed subscription", "b" + strings.TrimPrefix(payload, "a") + "." + signature},
+		{"changed signature", payload + "." + strings.Repeat("A", len(signature))},
+		{"extra part", encoded + ".x"},
+		{"missing id", "a-k." + sign("a-k")},
+		{"invalid id", "a-k-!." + sign("a-k-!")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeToken(tt.token); err == nil {
+				t.Fatalf("expected '%s' to be refused", tt.token)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		withSecret(t, "other-secret")
+
+		if _, err := DecodeToken(encoded); err == nil {
+			t.Fatal("expected the token of another secret to be refused")
+		}
+	})
+}
+
+func TestTokenUnsetSecret(t *testing.T) {
+	for _, secret := range []string{"", unsetSecret} {
+		t.Run(secret, func(t *testing.T) {
+			withSecret(t, secret)
+
+			if Enabled() {
+				t.Fatal("expected tracking to be disabled")
+			}
+
+			// tokens signed with the default secret could be forged
+			if _, err := DecodeToken(NewToken(1, 2, 3).Encode()); err == nil {
+				t.Fatal("expected the token to be refused")
+			}
+		})
+	}
+}
+
+func TestIsBotRequest(t *testing.T) {
+	const browser = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+
+	tests := []struct {
+		name    string
+		method  string
+		ua      string
+		headers map[string]string
+		want    bool
+	}{
+		{"browser", http.MethodGet, browser, nil, false},
+		{"head", http.MethodHead, browser, nil, true},
+		{"prefetch", http.MethodGet, browser, map[string]string{"Purpose": "prefetch"}, true},
+		{"preview", http.MethodGet, browser, map[string]string{"X-Purpose": "preview"}, true},
+		{"empty user agent", http.MethodGet, "", nil, true},
+		{"crawler", http.MethodGet, "Mozilla/5.0 (compatible; Googlebot/2.1)", nil, true},
+		{"security gateway", http.MethodGet, "Proofpoint URL Defense", nil, true},
+		{"http library", http.MethodGet, "curl/8.4.0", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/r/token", nil)
+			r.Header.Set("User-Agent", tt.ua)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			if got := isBotRequest(r); got != tt.want {
+				t.Fatalf("isBotRequest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagClick(t *testing.T) {
+	const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0"
+
+	tests := []struct {
+		name           string
+		ua             string
+		subscriptionID int64
+		recent         int64
+		err            error
+		want           bool
+		wantCount      bool
+	}{
+		{"first click", browser, 1, 0, nil, false, true},
+		{"burst", browser, 1, 2, nil, true, true},
+		{"bot request", "curl/8.4.0", 1, 0, nil, true, false},
+		{"without subscription", browser, 0, 5, nil, false, false},
+		{"count failure", browser, 1, 0, fmt.Errorf("db down"), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/r/token", nil)
+			r.Header.Set("User-Agent", tt.ua)
+
+			e := NewEvent(Click)
+			e.SubscriptionID = tt.subscriptionID
+			e.PostID = 2
+			e.LinkID = 3
+
+			counted := false
+			count := func(subscriptionID, postID, linkID int64, seconds int) (int64, error) {
+				counted = true
+
+				// only the other links of the same delivery are compared
+				if subscriptionID != e.SubscriptionID || postID != e.PostID || linkID != e.LinkID {
+					t.Fatalf("counted clicks of %d/%d/%d", subscriptionID, postID, linkID)
+				}
+
+				if seconds != clickBurstSeconds {
+					t.Fatalf("seconds = %d, want %d", seconds, clickBurstSeconds)
+				}
+
+				return tt.recent, tt.err
+			}
+
+			if err := flagClick(r, e, count); err != tt.err {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if e.IsBot != tt.want {
+				t.Fatalf("IsBot = %v, want %v", e.IsBot, tt.want)
+			}
+
+			if counted != tt.wantCount {
+				t.Fatalf("counted = %v, want %v", counted, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/statictask/newsletter/internal/config"
+	"github.com/statictask/newsletter/internal/log"
+)
+
+// bundledDisposableDomains is the list of disposable domains shipped with
+// the application
+//
+//go:embed disposable_domains.txt
+var bundledDisposableDomains string
+
+var (
+	disposableOnce    sync.Once
+	disposableMutex   sync.RWMutex
+	disposableDomains = map[string]bool{}
+)
+
+// roleLocalParts are the mailboxes of roles and systems instead of people
+var roleLocalParts = map[string]bool{
+	"abuse":         true,
+	"admin":         true,
+	"administrator": true,
+	"billing":       true,
+	"contact":       true,
+	"devnull":       true,
+	"do-not-reply":  true,
+	"donotreply":    true,
+	"help":          true,
+	"hostmaster":    true,
+	"info":          true,
+	"mailer-daemon": true,
+	"marketing":     true,
+	"no-reply":      true,
+	"noc":           true,
+	"noreply":       true,
+	"null":          true,
+	"postmaster":    true,
+	"root":          true,
+	"sales":         true,
+	"security":      true,
+	"support":       true,
+	"webmaster":     true,
+}
+
+// IsRole says if the local part of an address is the mailbox of a role,
+// like "abuse" or "noreply", ignoring the case and "+" suffixes
+func IsRole(local string) bool {
+	local = strings.ToLower(local)
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+
+	return roleLocalParts[local]
+}
+
+// IsDisposable says if the domain, or one of its parents, is of a
+// disposable email service
+func IsDisposable(domain string) bool {
+	disposableOnce.Do(loadDisposableDomains)
+
+	disposableMutex.RLock()
+	defer disposableMutex.RUnlock()
+
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	for domain != "" {
+		if disposableDomains[domain] {
+			return true
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+
+		domain = domain[dot+1:]
+	}
+
+	return false
+}
+
+// AddDisposableDomains adds the domains of a list, one per line with
+// comments starting with "#", to the disposable domains
+func AddDisposableDomains(r io.Reader) error {
+	disposableOnce.Do(loadDisposableDomains)
+
+	return addDisposableDomains(r)
+}
+
+// loadDisposableDomains loads the bundled list and the one of
+// DISPOSABLE_DOMAINS_FILE, when it's set
+func loadDisposableDomains() {
+	addDisposableDomains(strings.NewReader(bundledDisposableDomains))
+
+	path := config.C.DisposableDomainsFile
+	if path == "" {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.L.Error("failed opening disposable domains file", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	defer f.Close()
+
+	if err := addDisposableDomains(f); err != nil {
+		log.L.Error("failed reading disposable domains file", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// addDisposableDomains adds the domains of a list to the disposable ones
+func addDisposableDomains(r io.Reader) error {
+	disposableMutex.Lock()
+	defer disposableMutex.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		disposableDomains[line] = true
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// Resolver looks up the DNS records of domains, net.Resolver is one
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DefaultResolver is the resolver of the system
+var DefaultResolver Resolver = net.DefaultResolver
+
+// AcceptsEmail says if the domain has mail servers, either MX records or,
+// without them, an address record as RFC 5321 allows. It returns an error
+// when the lookups fail for reasons other than the domain not existing.
+func AcceptsEmail(ctx context.Context, r Resolver, domain string) (bool, error) {
+	mxs, err := r.LookupMX(ctx, domain)
+	if err == nil {
+		// a single "." MX is a null MX, the domain refuses email
+		for _, mx := range mxs {
+			if mx.Host != "." && mx.Host != "" {
+				return true, nil
+			}
+		}
+
+		if len(mxs) > 0 {
+			return false, nil
+		}
+	} else if !isNotFound(err) {
+		return false, err
+	}
+
+	hosts, err := r.LookupHost(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return len(hosts) > 0, nil
+}
+
+// isNotFound says if the lookup failed because the domain or the records
+// don't exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// FakeResolver answers lookups from its maps instead of the DNS, for
+// tests and development. Domains missing from both maps don't exist.
+type FakeResolver struct {
+	// MX are the mail servers and Hosts the addresses of each domain
+	MX    map[string][]string
+	Hosts map[string][]string
+}
+
+// LookupMX returns the mail servers of the domain in MX
+func (f *FakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	hosts, ok := f.MX[strings.ToLower(name)]
+	if !ok {
+		return nil, notFound(name)
+	}
+
+	mxs := make([]*net.MX, len(hosts))
+	for i, h := range hosts {
+		mxs[i] = &net.MX{Host: h, Pref: uint16(10 * (i + 1))}
+	}
+
+	return mxs, nil
+}
+
+// LookupHost returns the addresses of the domain in Hosts
+func (f *FakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := f.Hosts[strings.ToLower(host)]
+	if !ok {
+		return nil, notFound(host)
+	}
+
+	return addrs, nil
+}
+
+// notFound is the error of lookups of domains that don't exist
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
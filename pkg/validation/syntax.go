@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxEmailLength     = 254
+	maxLocalPartLength = 64
+)
+
+// CheckSyntax checks if the email is a bare RFC 5322 address, like
+// "jane@example.com", whose domain is a valid host name. Internationalized
+// domains are accepted in Unicode or in punycode.
+func CheckSyntax(email string) error {
+	if email == "" {
+		return fmt.Errorf("email is missing")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email '%s'", email)
+	}
+
+	if len(email) > maxEmailLength {
+		return fmt.Errorf("email can't be longer than %d characters", maxEmailLength)
+	}
+
+	local, domain := split(email)
+	if len(local) > maxLocalPartLength {
+		return fmt.Errorf("invalid email '%s', the part before @ can't be longer than %d characters", email, maxLocalPartLength)
+	}
+
+	// addresses must be deliverable on the internet, so domains need a
+	// dot and can't be literals like [127.0.0.1]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, "[") {
+		return fmt.Errorf("invalid email '%s', the domain must be a host name", email)
+	}
+
+	if _, err := idna.Lookup.ToASCII(domain); err != nil {
+		return fmt.Errorf("invalid email '%s', the domain isn't a valid host name", email)
+	}
+
+	return nil
+}
+
+// split returns the local part and the domain of an address, the domain
+// in lowercase and in punycode when it's valid
+func split(email string) (string, string) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, ""
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+
+	return email[:at], domain
+}
@@ -0,0 +1,222 @@
+package validation
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+// Policy says what happens to the addresses that fail a check
+type Policy string
+
+const (
+	// Allow ignores the check
+	Allow Policy = "allow"
+	// Flag accepts the address, recording the check in its Result
+	Flag Policy = "flag"
+	// Reject refuses the address
+	Reject Policy = "reject"
+)
+
+var Policies = []Policy{Allow, Flag, Reject}
+
+// IsValid says if the policy is one of the supported Policies
+func (p Policy) IsValid() bool {
+	for _, policy := range Policies {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks that can flag or reject an address besides its syntax, which is
+// always required
+const (
+	CheckDisposable = "disposable"
+	CheckRole       = "role"
+	CheckNoMX       = "no_mx"
+)
+
+// Settings are the policies of a project for each check. Domains are only
+// looked up in the DNS when NoMX isn't Allow.
+type Settings struct {
+	Disposable Policy `json:"disposable"`
+	Role       Policy `json:"role"`
+	NoMX       Policy `json:"no_mx"`
+}
+
+// DefaultSettings returns the Settings of projects that didn't customize
+// them, which flag suspicious addresses without refusing them
+func DefaultSettings() Settings {
+	return Settings{
+		Disposable: Flag,
+		Role:       Flag,
+		NoMX:       Allow,
+	}
+}
+
+// Validate checks if the policies are valid
+func (s *Settings) Validate() error {
+	for _, p := range []Policy{s.Disposable, s.Role, s.NoMX} {
+		if !p.IsValid() {
+			return fmt.Errorf("invalid validation policy '%s', use one of %v", p, Policies)
+		}
+	}
+
+	return nil
+}
+
+// Scan loads the Settings from the JSON stored in the database, the
+// missing policies keep their default values
+func (s *Settings) Scan(src interface{}) error {
+	*s = DefaultSettings()
+	return scanJSON(src, s)
+}
+
+// Result is the outcome of the checks of an address, stored with its
+// subscription
+type Result struct {
+	// Disposable and Role say if the address is of a disposable email
+	// service or of a role like "abuse@" instead of a person
+	Disposable bool `json:"disposable"`
+	Role       bool `json:"role"`
+	// MX says if the domain accepts email, it's nil when the domain
+	// wasn't looked up or the lookup failed
+	MX *bool `json:"mx"`
+	// Flags are the checks that failed with the Flag policy
+	Flags     []string  `json:"flags"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Flagged says if any check failed with the Flag policy
+func (r *Result) Flagged() bool {
+	return len(r.Flags) > 0
+}
+
+// Scan loads the Result from the JSON stored in the database
+func (r *Result) Scan(src interface{}) error {
+	return scanJSON(src, r)
+}
+
+// Value converts the Result to JSON before storing it in the database
+func (r *Result) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(r)
+}
+
+// RejectedError is returned for the addresses refused by a policy
+type RejectedError struct {
+	Email string
+	Check string
+}
+
+func (e *RejectedError) Error() string {
+	switch e.Check {
+	case CheckDisposable:
+		return fmt.Sprintf("'%s' is a disposable address, use a permanent one", e.Email)
+	case CheckRole:
+		return fmt.Sprintf("'%s' is a role address, use a personal one", e.Email)
+	case CheckNoMX:
+		return fmt.Sprintf("the domain of '%s' doesn't receive emails", e.Email)
+	}
+
+	return fmt.Sprintf("'%s' was rejected by the %s check", e.Email, e.Check)
+}
+
+// Validator checks addresses with the policies of a project
+type Validator struct {
+	settings Settings
+	resolver Resolver
+}
+
+// NewValidator returns a Validator of the settings that looks domains up
+// with the resolver, DefaultResolver when it's nil
+func NewValidator(settings Settings, resolver Resolver) *Validator {
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+
+	return &Validator{settings, resolver}
+}
+
+// Check validates the syntax of the address and runs the checks of the
+// policies. It returns a RejectedError for the addresses refused by a
+// policy, and the Result of the checks otherwise. Failed DNS lookups
+// don't reject addresses.
+func (v *Validator) Check(ctx context.Context, email string) (*Result, error) {
+	if err := CheckSyntax(email); err != nil {
+		return nil, err
+	}
+
+	local, domain := split(email)
+	r := &Result{
+		Disposable: IsDisposable(domain),
+		Role:       IsRole(local),
+		Flags:      []string{},
+		CheckedAt:  time.Now().UTC(),
+	}
+
+	if v.settings.NoMX != Allow {
+		ctx, cancel := context.WithTimeout(ctx, config.C.EmailLookupTimeout)
+		defer cancel()
+
+		if accepts, err := AcceptsEmail(ctx, v.resolver, domain); err == nil {
+			r.MX = &accepts
+		}
+	}
+
+	checks := []struct {
+		name   string
+		failed bool
+		policy Policy
+	}{
+		{CheckDisposable, r.Disposable, v.settings.Disposable},
+		{CheckRole, r.Role, v.settings.Role},
+		{CheckNoMX, r.MX != nil && !*r.MX, v.settings.NoMX},
+	}
+
+	for _, c := range checks {
+		if !c.failed {
+			continue
+		}
+
+		switch c.policy {
+		case Reject:
+			return r, &RejectedError{Email: email, Check: c.name}
+		case Flag:
+			r.Flags = append(r.Flags, c.name)
+		}
+	}
+
+	return r, nil
+}
+
+// scanJSON loads a value from the JSON stored in the database
+func scanJSON(src interface{}, v interface{}) error {
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		return fmt.Errorf("unable to scan %T from %T", v, src)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to parse %T: %v", v, err)
+	}
+
+	return nil
+}
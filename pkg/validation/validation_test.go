@@ -0,0 +1,170 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/statictask/newsletter/internal/config"
+)
+
+func TestMain(m *testing.M) {
+	config.Initialize()
+	os.Exit(m.Run())
+}
+
+// testResolver knows a domain with MX records, one with only an address,
+// one with a null MX and one that doesn't receive email
+var testResolver = &FakeResolver{
+	MX: map[string][]string{
+		"example.com":      {"mx1.example.com", "mx2.example.com"},
+		"null.example.com": {"."},
+		"xn--bcher-kva.ch": {"mx.xn--bcher-kva.ch"},
+	},
+	Hosts: map[string][]string{
+		"a.example.com":   {"192.0.2.1"},
+		"web.example.org": {},
+	},
+}
+
+func TestCheckSyntax(t *testing.T) {
+	tests := []struct {
+		email   string
+		wantErr bool
+	}{
+		{"jane@example.com", false},
+		{"jane.doe+news@example.com", false},
+		{"jane@bücher.ch", false},
+		{"jane@xn--bcher-kva.ch", false},
+		{"", true},
+		{"jane", true},
+		{"jane@", true},
+		{"Jane <jane@example.com>", true},
+		{"jane@localhost", true},
+		{"jane@[127.0.0.1]", true},
+		{" jane@example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			err := CheckSyntax(tt.email)
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected '%s' to be invalid", tt.email)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAcceptsEmail(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com", true},
+		{"xn--bcher-kva.ch", true},
+		// without MX records the address record is the mail server
+		{"a.example.com", true},
+		{"web.example.org", false},
+		{"null.example.com", false},
+		{"missing.example.net", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := AcceptsEmail(context.Background(), testResolver, tt.domain)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("AcceptsEmail(%s) = %v, want %v", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatorCheck(t *testing.T) {
+	flagAll := Settings{Disposable: Flag, Role: Flag, NoMX: Flag}
+	rejectAll := Settings{Disposable: Reject, Role: Reject, NoMX: Reject}
+	allowAll := Settings{Disposable: Allow, Role: Allow, NoMX: Allow}
+
+	tests := []struct {
+		name       string
+		settings   Settings
+		email      string
+		wantErr    bool
+		wantReject string
+		wantFlags  []string
+	}{
+		{"valid address", rejectAll, "jane@example.com", false, "", nil},
+		{"invalid syntax", allowAll, "jane@", true, "", nil},
+		{"address record fallback", rejectAll, "jane@a.example.com", false, "", nil},
+		{"unicode domain", rejectAll, "jane@bücher.ch", false, "", nil},
+		{"flagged disposable", flagAll, "jane@guerrillamail.com", false, "", []string{CheckDisposable, CheckNoMX}},
+		{"rejected disposable", rejectAll, "jane@mail.guerrillamail.com", true, CheckDisposable, nil},
+		{"allowed disposable", allowAll, "jane@guerrillamail.com", false, "", nil},
+		{"flagged role", flagAll, "Support+news@example.com", false, "", []string{CheckRole}},
+		{"rejected role", rejectAll, "noreply@example.com", true, CheckRole, nil},
+		{"flagged no mx", flagAll, "jane@null.example.com", false, "", []string{CheckNoMX}},
+		{"rejected no mx", rejectAll, "jane@missing.example.net", true, CheckNoMX, nil},
+		{"allowed no mx", allowAll, "jane@missing.example.net", false, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewValidator(tt.settings, testResolver).Check(context.Background(), tt.email)
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if len(r.Flags) != len(tt.wantFlags) {
+					t.Fatalf("flags = %v, want %v", r.Flags, tt.wantFlags)
+				}
+
+				for i := range tt.wantFlags {
+					if r.Flags[i] != tt.wantFlags[i] {
+						t.Fatalf("flags = %v, want %v", r.Flags, tt.wantFlags)
+					}
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected '%s' to be refused", tt.email)
+			}
+
+			var rejected *RejectedError
+			isRejected := errors.As(err, &rejected)
+
+			if tt.wantReject == "" && isRejected {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+
+			if tt.wantReject != "" && (!isRejected || rejected.Check != tt.wantReject) {
+				t.Fatalf("expected the %s check to reject, got %v", tt.wantReject, err)
+			}
+		})
+	}
+}
+
+func TestValidatorSkipsLookupsWhenAllowed(t *testing.T) {
+	settings := Settings{Disposable: Flag, Role: Flag, NoMX: Allow}
+
+	r, err := NewValidator(settings, &FakeResolver{}).Check(context.Background(), "jane@missing.example.net")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.MX != nil {
+		t.Fatalf("expected the domain not to be looked up, got MX %v", *r.MX)
+	}
+}